@@ -0,0 +1,101 @@
+package jsondiff
+
+// arrayAligner associates a pattern of pointers with the
+// function that aligns the elements of the arrays located
+// at the matching pointers.
+type arrayAligner struct {
+	pattern pattern
+	align   func(src, tgt []interface{}) [][2]int
+}
+
+// findAligner returns the alignment function of
+// the first pattern that matches the pointer.
+func findAligner(aligners []arrayAligner, ptr string) func(src, tgt []interface{}) [][2]int {
+	for i := range aligners {
+		if aligners[i].pattern.match(ptr) {
+			return aligners[i].align
+		}
+	}
+	return nil
+}
+
+// isValidAlignment returns whether the index pairs are in
+// range and strictly increasing in both arrays, such that
+// they can be applied in order without moving elements.
+func isValidAlignment(pairs [][2]int, ns, nt int) bool {
+	ps, pt := -1, -1
+	for _, p := range pairs {
+		if p[0] <= ps || p[1] <= pt || p[0] >= ns || p[1] >= nt {
+			return false
+		}
+		ps, pt = p[0], p[1]
+	}
+	return true
+}
+
+// compareAligned compares two arrays located at a pointer
+// that matches one of the patterns of the WithArrayAlignment
+// option, using the alignment of the elements returned by the
+// user-provided function. The matched elements are compared
+// to each other, while the others are removed or added. It
+// returns false if the alignment is invalid, and the arrays
+// must be compared normally.
+func (d *Differ) compareAligned(ptr pointer, src, tgt []interface{}, doc string) bool {
+	align := findAligner(d.opts.aligners, ptr.string())
+	if align == nil {
+		return false
+	}
+	pairs := align(src, tgt)
+	if !isValidAlignment(pairs, len(src), len(tgt)) {
+		return false
+	}
+	ptr.snapshot()
+	d.snapshotPatchLen = len(d.patch)
+	defer d.restoreToken(d.token)
+
+	var ai, bi int // src && tgt arrows
+	var add, remove int
+
+	// flush removes the unmatched elements of the source
+	// array up to index ma, then adds the unmatched elements
+	// of the target array up to index mb. The elements that
+	// precede the arrows are those of the target array, such
+	// that the index of the next element is always bi.
+	flush := func(ma, mb int) {
+		for ; ai < ma; ai++ {
+			d.track(ptr.string(), ai, -1)
+			d.appendIndex(&ptr, bi, len(src)+add-remove)
+			if !d.isIgnored(ptr) {
+				d.remove(ptr.copy(), src[ai])
+			}
+			ptr.rewind()
+			remove++
+		}
+		for ; bi < mb; bi++ {
+			d.track(ptr.string(), -1, bi)
+			d.appendIndex(&ptr, bi, len(src)+add-remove)
+			if !d.isIgnored(ptr) {
+				d.add(ptr.copy(), tgt[bi], doc, true)
+			}
+			ptr.rewind()
+			add++
+		}
+	}
+	for _, p := range pairs {
+		flush(p[0], p[1])
+
+		d.track(ptr.string(), ai, bi)
+		d.appendIndex(&ptr, bi, len(src)+add-remove)
+		if d.opts.rationalize {
+			d.diff(ptr, src[ai], tgt[bi], findIndex(doc, ptr.base.idx))
+		} else {
+			d.diff(ptr, src[ai], tgt[bi], doc)
+		}
+		ptr.rewind()
+		ai++
+		bi++
+	}
+	flush(len(src), len(tgt))
+
+	return true
+}
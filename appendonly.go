@@ -0,0 +1,48 @@
+package jsondiff
+
+import "fmt"
+
+// compareAppendOnly compares two arrays located at a pointer
+// that matches one of the patterns of the AppendOnly option,
+// under the assumption that the target array is the source
+// array followed by new elements. The elements of the common
+// prefix are only verified to be equal, and the new elements
+// are appended. It returns false if the assumption does not
+// hold, and the arrays must be compared normally.
+func (d *Differ) compareAppendOnly(ptr pointer, src, tgt []interface{}, doc string) bool {
+	if !matchAny(d.opts.appendOnly, ptr.string()) {
+		return false
+	}
+	valid := len(tgt) >= len(src)
+	for i := 0; valid && i < len(src); i++ {
+		valid = deepEqual(src[i], tgt[i])
+		if !valid && d.normalizes() {
+			p := ptr.clone()
+			p.appendIndex(i)
+			valid = d.equal(p.string(), src[i], tgt[i])
+		}
+	}
+	if !valid {
+		if d.opts.strictLogs {
+			d.err = fmt.Errorf("%w: %s", ErrAppendOnly, ptr.string())
+			return true
+		}
+		return false
+	}
+	ptr.snapshot()
+	np := ptr.clone()
+	np.appendKey("-") // "append" path
+	p := np.copy()
+
+	defer d.restoreToken(d.token)
+	for i, n := len(src), len(src); i < len(tgt); i++ {
+		d.track(ptr.string(), -1, i)
+		ptr.appendIndex(i)
+		if !d.isIgnored(ptr) {
+			d.add(d.appendPath(p, n), tgt[i], doc, false)
+			n++
+		}
+		ptr.rewind()
+	}
+	return true
+}
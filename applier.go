@@ -0,0 +1,410 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ErrTestFailed is the error returned when the value
+// located at the path of a test operation is not equal
+// to the value of the operation.
+var ErrTestFailed = errors.New("jsondiff: test operation failed")
+
+// ErrChecksumMismatch is the error returned when the
+// checksum of the patched document differs from the
+// value of a checksum operation.
+var ErrChecksumMismatch = errors.New("jsondiff: checksum mismatch")
+
+// Apply applies the operations of the patch, in order, to
+// the JSON document, and returns the JSON representation of
+// the patched document. The document is decoded and the result
+// encoded with the functions of the UnmarshalFunc and MarshalFunc
+// options, if any, or those of the standard library otherwise.
+// The other options are ignored.
+//
+// The operations are applied with the semantics of RFC 6902.
+// The application stops at the first operation that cannot be
+// applied, such as the removal of a value that does not exist,
+// or the move of a value into one of its own children, and the
+// error identifies the operation. A failed test operation returns
+// an error that wraps ErrTestFailed. The checksum operations verify
+// the checksum of the document patched by the preceding operations,
+// and the increment operations add their value to the number
+// located at their path. The remove operations that have a size
+// field, added by the WithRemoveSizeGuards option, fail if the
+// removed value is not an array or object of that size.
+func (p Patch) Apply(doc []byte, opts ...Option) ([]byte, error) {
+	var d Differ
+	d.applyOpts(opts...)
+	d.opts.setDefaultCodec()
+
+	var root interface{}
+	if err := d.opts.unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	root, err := p.applyTo(root, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	return d.opts.marshal(root)
+}
+
+// applyTo is similar to Apply, but applies the operations
+// to a decoded document, and returns the new root of the
+// document, which is modified in place.
+func (p Patch) applyTo(root interface{}, opts options) (interface{}, error) {
+	for i, op := range p {
+		var err error
+		if root, err = applyOperation(root, op, opts); err != nil {
+			return nil, fmt.Errorf("jsondiff: operation #%d (%s %q): %w", i, op.Type, op.Path, err)
+		}
+	}
+	return root, nil
+}
+
+// applyOperation applies a single operation to the
+// document, and returns the new root of the document.
+func applyOperation(root interface{}, op Operation, opts options) (interface{}, error) {
+	path, err := decodePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Type {
+	case OperationAdd, OperationReplace, OperationTest:
+		// Decode the value with the codec of the
+		// document, which also makes a deep copy.
+		v, _, err := marshalUnmarshal(op.Value, opts)
+		if err != nil {
+			return nil, err
+		}
+		switch op.Type {
+		case OperationAdd:
+			return addValue(root, path, v)
+		case OperationReplace:
+			return replaceValue(root, path, v)
+		default:
+			cur, err := getValue(root, path)
+			if err != nil {
+				return nil, err
+			}
+			if !deepEqualNumbers(cur, v) {
+				exp, _ := opts.marshal(v)
+				act, _ := opts.marshal(cur)
+				return nil, fmt.Errorf("%w at %q: expected %s, got %s", ErrTestFailed, op.Path, exp, act)
+			}
+			return root, nil
+		}
+	case OperationRemove:
+		if op.Size != nil {
+			cur, err := getValue(root, path)
+			if err != nil {
+				return nil, err
+			}
+			n := containerSize(cur)
+			if n == nil {
+				return nil, fmt.Errorf("size guard of %s value", jsonTypeSwitch(cur))
+			}
+			if *n != *op.Size {
+				return nil, fmt.Errorf("size guard mismatch: expected %d, got %d", *op.Size, *n)
+			}
+		}
+		return removeValue(root, path)
+	case OperationMove, OperationCopy:
+		from, err := decodePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := getValue(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Type == OperationCopy {
+			return addValue(root, path, deepCopy(v))
+		}
+		if op.From == op.Path {
+			return root, nil
+		}
+		// https://tools.ietf.org/html/rfc6902#section-4.4
+		// The "from" location MUST NOT be a proper prefix
+		// of the "path" location.
+		if strings.HasPrefix(op.Path, op.From+string(separator)) {
+			return nil, fmt.Errorf("cannot move %q into one of its children", op.From)
+		}
+		if root, err = removeValue(root, from); err != nil {
+			return nil, err
+		}
+		return addValue(root, path, v)
+	case OperationIncrement:
+		delta, _, err := marshalUnmarshal(op.Value, opts)
+		if err != nil {
+			return nil, err
+		}
+		cur, err := getValue(root, path)
+		if err != nil {
+			return nil, err
+		}
+		v, err := incrementNumber(cur, delta)
+		if err != nil {
+			return nil, err
+		}
+		return replaceValue(root, path, v)
+	case OperationChecksum:
+		sum, err := checksum(root)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := op.Value.(string); !ok || s != sum {
+			return nil, fmt.Errorf("%w: expected %v, got %s", ErrChecksumMismatch, op.Value, sum)
+		}
+		return root, nil
+	default:
+		return nil, fmt.Errorf("unsupported operation type %q", op.Type)
+	}
+}
+
+// decodePointer returns the unescaped
+// reference tokens of the JSON pointer.
+func decodePointer(s string) ([]string, error) {
+	tokens, err := parsePointer(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pointer %q: %w", s, err)
+	}
+	for i, t := range tokens {
+		tokens[i] = rfc6901Unescaper.Replace(t)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses the reference token of an element of
+// an array of length n. The index n, which designates the
+// end of the array, is only valid if end is true.
+func arrayIndex(token string, n int, end bool) (int, error) {
+	if token == "-" && end {
+		return n, nil
+	}
+	i, ok := parseIndex(token)
+	if !ok || len(token) > 1 && token[0] == '0' {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > n || i == n && !end {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+// getValue returns the value located at the path.
+func getValue(root interface{}, path []string) (interface{}, error) {
+	v := root
+	for _, t := range path {
+		switch c := v.(type) {
+		case map[string]interface{}:
+			e, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("missing key %q", t)
+			}
+			v = e
+		case []interface{}:
+			i, err := arrayIndex(t, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			v = c[i]
+		default:
+			return nil, fmt.Errorf("cannot traverse %s value with token %q", jsonTypeSwitch(v), t)
+		}
+	}
+	return v, nil
+}
+
+// updateParent calls fn with the parent container of the
+// value located at the path, and the last reference token
+// of the path, and returns the new root of the document.
+// The function returns the new value of the parent, since
+// the modification of an array may reallocate it.
+func updateParent(root interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(root, path[0])
+	}
+	child, err := getValue(root, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	nc, err := updateParent(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+	switch c := root.(type) {
+	case map[string]interface{}:
+		c[path[0]] = nc
+	case []interface{}:
+		i, _ := arrayIndex(path[0], len(c), false)
+		c[i] = nc
+	}
+	return root, nil
+}
+
+func addValue(root interface{}, path []string, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	return updateParent(root, path, func(parent interface{}, t string) (interface{}, error) {
+		switch c := parent.(type) {
+		case map[string]interface{}:
+			c[t] = v
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(t, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = v
+			return c, nil
+		default:
+			return nil, fmt.Errorf("cannot add to %s value", jsonTypeSwitch(parent))
+		}
+	})
+}
+
+func replaceValue(root interface{}, path []string, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	return updateParent(root, path, func(parent interface{}, t string) (interface{}, error) {
+		switch c := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := c[t]; !ok {
+				return nil, fmt.Errorf("missing key %q", t)
+			}
+			c[t] = v
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(t, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			c[i] = v
+			return c, nil
+		default:
+			return nil, fmt.Errorf("cannot replace in %s value", jsonTypeSwitch(parent))
+		}
+	})
+}
+
+func removeValue(root interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the root document")
+	}
+	return updateParent(root, path, func(parent interface{}, t string) (interface{}, error) {
+		switch c := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := c[t]; !ok {
+				return nil, fmt.Errorf("missing key %q", t)
+			}
+			delete(c, t)
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(t, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:i], c[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot remove from %s value", jsonTypeSwitch(parent))
+		}
+	})
+}
+
+// deepCopy returns a copy of the value that
+// doesn't share any container with it.
+func deepCopy(v interface{}) interface{} {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(c))
+		for k, e := range c {
+			m[k] = deepCopy(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(c))
+		for i, e := range c {
+			a[i] = deepCopy(e)
+		}
+		return a
+	default:
+		return v
+	}
+}
+
+// deepEqualNumbers is like deepEqual, but compares the
+// numbers by value, as required by the test operation.
+func deepEqualNumbers(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, e := range av {
+			f, ok := bv[k]
+			if !ok || !deepEqualNumbers(e, f) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !deepEqualNumbers(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		if deepEqual(a, b) {
+			return true
+		}
+		an, ok := numberString(a)
+		if !ok {
+			return false
+		}
+		bn, ok := numberString(b)
+		if !ok {
+			return false
+		}
+		ac, ok := normalizeNumber(an)
+		if !ok {
+			return false
+		}
+		bc, ok := normalizeNumber(bn)
+
+		return ok && ac == bc
+	}
+}
+
+// numberString returns the decimal
+// representation of a JSON number.
+func numberString(v interface{}) (string, bool) {
+	switch n := v.(type) {
+	case float64:
+		return formatFloat(n), true
+	case json.Number:
+		return string(n), true
+	case *big.Int:
+		return n.String(), true
+	case *big.Float:
+		if n.IsInf() {
+			return "", false
+		}
+		return n.Text('g', -1), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,238 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPatch_Apply(t *testing.T) {
+	one, two := 1, 2
+
+	for _, tc := range []struct {
+		name  string
+		doc   string
+		patch Patch
+		want  string
+		err   string
+	}{
+		{
+			name: "add members and elements",
+			doc:  `{"a":[1,2],"b":{}}`,
+			patch: Patch{
+				{Type: OperationAdd, Path: "/a/1", Value: 9},
+				{Type: OperationAdd, Path: "/a/-", Value: 3},
+				{Type: OperationAdd, Path: "/a/4", Value: 4},
+				{Type: OperationAdd, Path: "/b/c~1d", Value: map[string]interface{}{"e": nil}},
+			},
+			want: `{"a":[1,9,2,3,4],"b":{"c/d":{"e":null}}}`,
+		},
+		{
+			name:  "add to the root document",
+			doc:   `{"a":1}`,
+			patch: Patch{{Type: OperationAdd, Path: "", Value: []interface{}{"x"}}},
+			want:  `["x"]`,
+		},
+		{
+			name: "remove, replace, move and copy",
+			doc:  `{"a":[1,2,3],"b":{"c":1},"d":"x"}`,
+			patch: Patch{
+				{Type: OperationRemove, Path: "/a/0"},
+				{Type: OperationReplace, Path: "/d", Value: "y"},
+				{Type: OperationMove, From: "/b/c", Path: "/a/0"},
+				{Type: OperationCopy, From: "/a", Path: "/e"},
+				{Type: OperationAdd, Path: "/e/-", Value: 4},
+				{Type: OperationMove, From: "/d", Path: "/d"},
+			},
+			want: `{"a":[1,2,3],"b":{},"d":"y","e":[1,2,3,4]}`,
+		},
+		{
+			name: "test operations compare numbers by value",
+			doc:  `{"a":[1,{"b":2.50}]}`,
+			patch: Patch{
+				{Type: OperationTest, Path: "/a", Value: []interface{}{1, map[string]interface{}{"b": 2.5}}},
+				{Type: OperationTest, Path: "/a/1/b", Value: json.Number("25e-1")},
+			},
+			want: `{"a":[1,{"b":2.5}]}`,
+		},
+		{
+			name: "increment numbers",
+			doc:  `{"a":1,"b":[0.5]}`,
+			patch: Patch{
+				{Type: OperationIncrement, Path: "/a", Value: 2},
+				{Type: OperationIncrement, Path: "/b/0", Value: json.Number("-1.5")},
+			},
+			want: `{"a":3,"b":[-1]}`,
+		},
+		{
+			name:  "increment a string",
+			doc:   `{"a":"1"}`,
+			patch: Patch{{Type: OperationIncrement, Path: "/a", Value: 1}},
+			err:   "cannot increment String value",
+		},
+		{
+			name:  "increment by a string",
+			doc:   `{"a":1}`,
+			patch: Patch{{Type: OperationIncrement, Path: "/a", Value: "1"}},
+			err:   "cannot increment by String value",
+		},
+		{
+			name:  "failed test",
+			doc:   `{"a":{"b":1}}`,
+			patch: Patch{{Type: OperationTest, Path: "/a/b", Value: "1"}},
+			err:   `test operation failed at "/a/b": expected "1", got 1`,
+		},
+		{
+			name:  "remove nonexistent key",
+			doc:   `{"a":1}`,
+			patch: Patch{{Type: OperationRemove, Path: "/b"}},
+			err:   `missing key "b"`,
+		},
+		{
+			name: "remove with size guards",
+			doc:  `{"a":[1,2],"b":{"c":1}}`,
+			patch: Patch{
+				{Type: OperationRemove, Path: "/a", Size: &two},
+				{Type: OperationRemove, Path: "/b", Size: &one},
+			},
+			want: `{}`,
+		},
+		{
+			name:  "size guard mismatch",
+			doc:   `{"a":[1,2,3]}`,
+			patch: Patch{{Type: OperationRemove, Path: "/a", Size: &two}},
+			err:   "size guard mismatch: expected 2, got 3",
+		},
+		{
+			name:  "size guard of a scalar",
+			doc:   `{"a":"x"}`,
+			patch: Patch{{Type: OperationRemove, Path: "/a", Size: new(int)}},
+			err:   "size guard of String value",
+		},
+		{
+			name:  "replace nonexistent key",
+			doc:   `{"a":1}`,
+			patch: Patch{{Type: OperationReplace, Path: "/b", Value: 1}},
+			err:   `missing key "b"`,
+		},
+		{
+			name:  "move into a child",
+			doc:   `{"a":{"b":{}}}`,
+			patch: Patch{{Type: OperationMove, From: "/a", Path: "/a/b/c"}},
+			err:   `cannot move "/a" into one of its children`,
+		},
+		{
+			name:  "array index out of bounds",
+			doc:   `[1]`,
+			patch: Patch{{Type: OperationAdd, Path: "/2", Value: 1}},
+			err:   "out of bounds",
+		},
+		{
+			name:  "array index with leading zero",
+			doc:   `[1,2]`,
+			patch: Patch{{Type: OperationRemove, Path: "/01"}},
+			err:   `invalid array index "01"`,
+		},
+		{
+			name:  "add to a missing parent",
+			doc:   `{}`,
+			patch: Patch{{Type: OperationAdd, Path: "/a/b", Value: 1}},
+			err:   `missing key "a"`,
+		},
+		{
+			name:  "remove the root document",
+			doc:   `{}`,
+			patch: Patch{{Type: OperationRemove, Path: ""}},
+			err:   "cannot remove the root document",
+		},
+		{
+			name:  "unsupported operation",
+			doc:   `{}`,
+			patch: Patch{{Type: "patch", Path: "/a"}},
+			err:   `unsupported operation type "patch"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.patch.Apply([]byte(tc.doc))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("got error %v, want error containing %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tc.want {
+				t.Errorf("got %s, want %s", b, tc.want)
+			}
+		})
+	}
+}
+
+func TestPatch_Apply_errors(t *testing.T) {
+	patch := Patch{{Type: OperationTest, Path: "/a", Value: 2}}
+	if _, err := patch.Apply([]byte(`{"a":1}`)); !errors.Is(err, ErrTestFailed) {
+		t.Errorf("got error %v, want %v", err, ErrTestFailed)
+	}
+	patch = Patch{{Type: OperationChecksum, Value: "fnv64a:0"}}
+	if _, err := patch.Apply([]byte(`{"a":1}`)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("got error %v, want %v", err, ErrChecksumMismatch)
+	}
+	if _, err := patch.Apply([]byte(`{`)); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+func TestPatch_Apply_roundTrip(t *testing.T) {
+	for _, opts := range [][]Option{
+		nil,
+		{Factorize()},
+		{Factorize(), Rationalize()},
+		{LCS()},
+		{Factorize(), Rationalize(), Invertible(), LCS(), WithResultChecksum()},
+		{Factorize(), WithRemoveSizeGuards()},
+	} {
+		for _, f := range []string{
+			"testdata/tests/rfc.json",
+			"testdata/tests/array.json",
+			"testdata/tests/object.json",
+			"testdata/tests/root.json",
+			"testdata/tests/options/all.json",
+		} {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cases []testcase
+			if err := json.Unmarshal(b, &cases); err != nil {
+				t.Fatal(err)
+			}
+			for _, tc := range cases {
+				patch, err := Compare(tc.Before, tc.After, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				src, err := json.Marshal(tc.Before)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res, err := patch.Apply(src)
+				if err != nil {
+					t.Errorf("%s: %s: %s\n%s", f, tc.Name, err, patch)
+					continue
+				}
+				var got interface{}
+				if err := json.Unmarshal(res, &got); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, tc.After) {
+					t.Errorf("%s: %s: got %s\n%s", f, tc.Name, res, patch)
+				}
+			}
+		}
+	}
+}
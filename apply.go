@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"strconv"
 	"strings"
 	"unicode"
 
@@ -144,31 +143,27 @@ func toDotPath(path string, src []byte) (string, error) {
 	for i, f := range fragments {
 		var key string
 		switch {
-		case len(f) != 0 && unicode.IsDigit(rune(f[0])):
-			// The fragment starts with a digit, which
-			// indicate that it might be a number.
-			if _, err := strconv.ParseInt(f, 10, 64); err == nil {
-				// The number is valid, but it could either be an
-				// array indices or an object key.
-				// Since the JSON Pointer RFC does not differentiate
-				// between the two, we have to look up the value to
-				// know what we're dealing with.
-				p := sb.String()
-				if p == "" {
-					p = "@this"
-				}
-				r := gjson.GetBytes(src, p)
-				switch {
-				case r.IsArray():
-					// Write array indices as-is.
-					key = f
-				case r.IsObject():
-					// Force the number as an object key, by
-					// preceding it with a colon character.
-					key = ":" + f
-				default:
-					return "", fmt.Errorf("unexpected value type at path: %s", sb.String())
-				}
+		case f != "" && isDigits(f):
+			// The fragment is a number, but it could either be
+			// an array indices or an object key.
+			// Since the JSON Pointer RFC does not differentiate
+			// between the two, we have to look up the value to
+			// know what we're dealing with.
+			p := sb.String()
+			if p == "" {
+				p = "@this"
+			}
+			r := gjson.GetBytes(src, p)
+			switch {
+			case r.IsArray():
+				// Write array indices as-is.
+				key = f
+			case r.IsObject():
+				// Force the number as an object key, by
+				// preceding it with a colon character.
+				key = ":" + f
+			default:
+				return "", fmt.Errorf("unexpected value type at path: %s", sb.String())
 			}
 		case f == "-" && i == len(fragments)-1:
 			// If the last fragment is the "-" character,
@@ -176,8 +171,9 @@ func toDotPath(path string, src []byte) (string, error) {
 			// element to append to the array.
 			key = "-1"
 		default:
-			key = rfc6901Unescaper.Replace(f)
-			key = strings.ReplaceAll(key, ".", `\.`)
+			// Escape the characters of the key that
+			// have a special meaning in a dot-path.
+			key = gjson.Escape(rfc6901Unescaper.Replace(f))
 		}
 		if i != 0 {
 			// Add separator character
@@ -45,6 +45,21 @@ func Test_toDotPath(t *testing.T) {
 			`{"a":[1,2,3]}`,
 			"a.-1",
 		},
+		{
+			"/a~1b/c~0d",
+			`{"a/b":{"c~d":1}}`,
+			`a\/b.c\~d`,
+		},
+		{
+			"/a.b/*/c?|#/@d",
+			`{"a.b":{"*":{"c?|#":{"@d":1}}}}`,
+			`a\.b.\*.c\?\|\#.\@d`,
+		},
+		{
+			"/1a/b",
+			`{"1a":{"b":1}}`,
+			"1a.b",
+		},
 	} {
 		s, err := toDotPath(tc.ptr, []byte(tc.json))
 		if err != nil {
@@ -0,0 +1,72 @@
+package jsondiff
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// base64Segment is the pointer segment that marks the
+// boundary between a base64-encoded JSON string and its
+// decoded value. It cannot collide with the segment of
+// an actual key, since a tilde is always escaped as "~0".
+const base64Segment = "~b64"
+
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.URLEncoding,
+	base64.RawStdEncoding,
+	base64.RawURLEncoding,
+}
+
+// diffBase64 compares the decoded values of two strings
+// holding base64-encoded JSON documents, if the pointer
+// matches one of the patterns of the DecodeBase64JSON
+// option. It returns false if the values could not be
+// compared, and must be compared as strings.
+func (d *Differ) diffBase64(ptr pointer, src, tgt interface{}) bool {
+	ss, ok := src.(string)
+	if !ok {
+		return false
+	}
+	ts, ok := tgt.(string)
+	if !ok || !matchAny(d.opts.base64, ptr.string()) {
+		return false
+	}
+	si, _, ok := d.decodeBase64JSON(ss)
+	if !ok {
+		return false
+	}
+	ti, tb, ok := d.decodeBase64JSON(ts)
+	if !ok {
+		return false
+	}
+	var doc string
+	if d.opts.rationalize {
+		doc = b2s(compact(tb))
+	}
+	ptr.appendRaw(base64Segment)
+	d.diff(ptr, si, ti, doc)
+
+	return true
+}
+
+// decodeBase64JSON decodes the base64-encoded JSON document
+// held by the string, and returns its value and bytes.
+func (d *Differ) decodeBase64JSON(s string) (interface{}, []byte, bool) {
+	unmarshal := d.opts.unmarshal
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	for _, enc := range base64Encodings {
+		b, err := enc.DecodeString(s)
+		if err != nil {
+			continue
+		}
+		var i interface{}
+		if err := unmarshal(b, &i); err != nil {
+			return nil, nil, false
+		}
+		return i, b, true
+	}
+	return nil, nil, false
+}
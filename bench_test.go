@@ -44,9 +44,12 @@ func subBenchmarks(b *testing.B, src, tgt, tgtUnordered []byte) {
 		{"default-unordered", nil, tgtUnordered},
 		{"invertible", makeopts(Invertible()), tgt},
 		{"factorize", makeopts(Factorize()), tgt},
+		{"factorize-equal", makeopts(Factorize()), src},
 		{"rationalize", makeopts(Rationalize()), tgt},
 		{"equivalent", makeopts(Equivalent()), tgt},
 		{"equivalent-unordered", makeopts(Equivalent()), tgtUnordered},
+		{"lcs", makeopts(LCS()), tgt},
+		{"lcs-unordered", makeopts(LCS()), tgtUnordered},
 		{"factor+ratio", makeopts(Factorize(), Rationalize()), tgt},
 		{"all", makeopts(Factorize(), Rationalize(), Invertible(), Equivalent()), tgt},
 		{"all-unordered", makeopts(Factorize(), Rationalize(), Invertible(), Equivalent()), tgtUnordered},
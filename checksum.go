@@ -0,0 +1,73 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+)
+
+// OperationChecksum is the type of the operation appended
+// to the patch when the WithResultChecksum option is enabled.
+// It is not part of RFC 6902.
+const OperationChecksum = "checksum"
+
+// checksumPrefix identifies the hash function
+// used to compute the checksum of a document.
+const checksumPrefix = "fnv64a:"
+
+// Checksum returns the checksum of the JSON representation
+// of the given value, in the format used by the value of
+// the checksum operations. It is computed with the 64-bit
+// FNV-1a hash function over the canonical (compact, with
+// sorted object keys) JSON representation of the value, such
+// that it does not depend on the formatting of a document.
+func Checksum(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var i interface{}
+	if err := json.Unmarshal(b, &i); err != nil {
+		return "", err
+	}
+	return checksum(i)
+}
+
+// checksum returns the checksum of a value that holds
+// only values of the types returned by json.Unmarshal.
+func checksum(i interface{}) (string, error) {
+	// Objects are represented by Go maps, which
+	// are marshaled with their keys sorted.
+	b, err := json.Marshal(i)
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+
+	return checksumPrefix + strconv.FormatUint(h.Sum64(), 16), nil
+}
+
+// appendChecksum appends a checksum operation
+// of the target value to the patch.
+func (d *Differ) appendChecksum(tgt interface{}) {
+	sum, err := checksum(tgt)
+	if err != nil {
+		d.err = err
+		return
+	}
+	d.emit(OperationChecksum, emptyPointer, d.ptr.copy(), nil, sum, len(sum)+2)
+}
+
+// hashStates records the checksums of the source and
+// target values, for the state hashes of the envelope.
+func (d *Differ) hashStates(src, tgt interface{}) {
+	var err error
+	if d.preHash, err = checksum(src); err != nil {
+		d.err = err
+		return
+	}
+	if d.postHash, err = checksum(tgt); err != nil {
+		d.err = err
+	}
+}
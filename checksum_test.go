@@ -0,0 +1,70 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWithResultChecksum(t *testing.T) {
+	src := `{"a":"1","b":[1,2]}`
+	tgt := `{
+		"b": [1, 2, 3],
+		"a": "2"
+	}`
+	patch, err := CompareJSON([]byte(src), []byte(tgt), WithResultChecksum())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 3 {
+		t.Fatalf("got %d operations, want 3", len(patch))
+	}
+	op := patch[len(patch)-1]
+	if op.Type != OperationChecksum || op.Path != "" {
+		t.Fatalf("unexpected last operation: %s", op)
+	}
+	sum, ok := op.Value.(string)
+	if !ok || !strings.HasPrefix(sum, checksumPrefix) {
+		t.Fatalf("unexpected checksum value: %v", op.Value)
+	}
+	// Apply the standard operations, and verify the
+	// checksum of the result, as a consumer would.
+	b, err := patch[:len(patch)-1].apply([]byte(src), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Checksum(json.RawMessage(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sum {
+		t.Errorf("checksum mismatch: got %s, want %s", got, sum)
+	}
+	other, err := Checksum(json.RawMessage(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == sum {
+		t.Errorf("expected checksum of source document to differ")
+	}
+}
+
+func TestChecksum_formatting(t *testing.T) {
+	a, err := Checksum(json.RawMessage(`{"a": 1, "b": {"c": [true, null]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Checksum(map[string]interface{}{
+		"b": map[string]interface{}{"c": []interface{}{true, nil}},
+		"a": 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("expected equal checksums: %s != %s", a, b)
+	}
+	if _, err := Checksum(func() {}); err == nil {
+		t.Errorf("expected non-nil error")
+	}
+}
@@ -0,0 +1,119 @@
+package jsondiff
+
+// coalesceTests replaces the test operations of the runs of
+// consecutive test and replace operation pairs of the members
+// of the same object by a single test operation of the object,
+// followed by the replace operations, if the patch becomes
+// smaller. The object is tested with its value in the source
+// document, and a run is therefore coalesced only if none of
+// the previous operations modifies the object, or shifts its
+// index in one of the arrays that contain it.
+func coalesceTests(p Patch, src interface{}) Patch {
+	out := make(Patch, 0, len(p))
+
+	for i := 0; i < len(p); {
+		parent, n := testReplaceRun(p[i:])
+		if n < 2 {
+			out = append(out, p[i])
+			i++
+			continue
+		}
+		run := p[i : i+2*n]
+		i += 2 * n
+
+		test, ok := parentTest(out, parent, src)
+		if !ok || !smallerTest(test, run) {
+			out = append(out, run...)
+			continue
+		}
+		out = append(out, test)
+		for j := 1; j < len(run); j += 2 {
+			out = append(out, run[j])
+		}
+	}
+	return append(p[:0], out...)
+}
+
+// testReplaceRun returns the location of the object whose
+// members are tested and replaced by the consecutive pairs
+// of test and replace operations at the start of the patch,
+// and the number of pairs.
+func testReplaceRun(p Patch) (string, int) {
+	var (
+		parent string
+		n      int
+	)
+	for j := 0; j+1 < len(p); j += 2 {
+		t, r := p[j], p[j+1]
+		if t.Type != OperationTest || r.Type != OperationReplace || t.Path != r.Path || r.Path == emptyPointer {
+			break
+		}
+		pp := parentPointer(r.Path)
+		if n != 0 && pp != parent {
+			break
+		}
+		parent = pp
+		n++
+	}
+	return parent, n
+}
+
+// parentTest returns the test operation of the object located
+// at the given pointer of the source document. It returns false
+// if the location isn't an object, or if one of the previous
+// operations of the patch modifies it, or shifts the indices of
+// the elements of an array that contains it, such that the
+// pointer no longer locates the object of the source document.
+func parentTest(prev Patch, parent string, src interface{}) (Operation, bool) {
+	for _, op := range prev {
+		switch op.Type {
+		case OperationTest, OperationChecksum:
+			continue
+		case OperationMove:
+			if isPointerPrefix(op.From, parent) || isPointerPrefix(parent, op.From) || shiftsIndexOf(op.From, parent) {
+				return Operation{}, false
+			}
+		}
+		if isPointerPrefix(op.Path, parent) || isPointerPrefix(parent, op.Path) {
+			return Operation{}, false
+		}
+		if op.Type != OperationReplace && shiftsIndexOf(op.Path, parent) {
+			return Operation{}, false
+		}
+	}
+	tokens, err := decodePointer(parent)
+	if err != nil {
+		return Operation{}, false
+	}
+	v, err := getValue(src, tokens)
+	if err != nil || !isObject(v) {
+		return Operation{}, false
+	}
+	return Operation{
+		Type:     OperationTest,
+		Path:     parent,
+		Value:    v,
+		valueLen: valueLength(v),
+	}, true
+}
+
+// shiftsIndexOf returns whether the insertion or the removal
+// of the value located at ptr may shift the index of an element
+// of an array that contains the value located at parent. The
+// locations whose last token is an index or "-" are assumed to
+// be array elements.
+func shiftsIndexOf(ptr, parent string) bool {
+	return isPointerPrefix(parentPointer(ptr), parent) && isElementPointer(ptr)
+}
+
+// smallerTest returns whether the test operation is smaller
+// than the test operations of the pairs of the run it replaces.
+func smallerTest(test Operation, run Patch) bool {
+	size := len(run)/2 - 1 // comma separators
+	for j := 0; j < len(run); j += 2 {
+		t := run[j]
+		t.valueLen = valueLength(t.Value)
+		size += t.jsonLength()
+	}
+	return test.jsonLength() < size
+}
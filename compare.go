@@ -1,10 +1,77 @@
 package jsondiff
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 )
 
+// ErrMaxDepth is the error returned when the nesting depth
+// of a compared value exceeds the maximum set with the
+// MaxDepth option.
+var ErrMaxDepth = errors.New("jsondiff: exceeded max nesting depth")
+
+// ErrAppendOnly is the error returned when an array that
+// matches one of the patterns of the AppendOnly option has
+// been modified otherwise than by appending new elements,
+// and the StrictAppendOnly option is enabled.
+var ErrAppendOnly = errors.New("jsondiff: append-only array modified")
+
+// ErrNonFinite is the error returned when an operation
+// would carry a number that is NaN or infinite, such as
+// a value produced by a custom decoder, which cannot be
+// represented in JSON. The error reports the pointer of
+// the number.
+var ErrNonFinite = errors.New("jsondiff: non-finite number")
+
+// ErrHandlerOptions is the error returned when the
+// WithOperationHandler option is combined with one of
+// the options that rewrite the generated operations.
+var ErrHandlerOptions = errors.New("jsondiff: operation handler incompatible with patch rewriting options")
+
+// ErrTimeout is the error returned when a comparison
+// exceeds the duration set with the WithTimeout option.
+var ErrTimeout = errors.New("jsondiff: comparison timed out")
+
+// DocumentSide identifies the source or the
+// target document of a comparison.
+type DocumentSide string
+
+const (
+	// SourceDocument denotes the document
+	// the differences are relative to.
+	SourceDocument DocumentSide = "source"
+
+	// TargetDocument denotes the document
+	// the source document is compared with.
+	TargetDocument DocumentSide = "target"
+)
+
+// DecodeError is the error returned by the functions that
+// compare JSON documents, such as CompareJSON, CompareReaders
+// and CompareFiles, when one of the documents cannot be read
+// or decoded. Side identifies the document, and File its path,
+// if it was read from a file. The decoding error, such as a
+// *json.SyntaxError, is available with errors.As.
+type DecodeError struct {
+	Side DocumentSide
+	File string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	if e.File != "" {
+		return "jsondiff: failed to decode " + e.File + ": " + e.Err.Error()
+	}
+	return "jsondiff: failed to decode " + string(e.Side) + " document: " + e.Err.Error()
+}
+
+// Unwrap returns the decoding error.
+func (e *DecodeError) Unwrap() error { return e.Err }
+
 // Compare compares the JSON representations of the
 // given values and returns the differences relative
 // to the former as a list of JSON Patch operations.
@@ -15,9 +82,23 @@ func Compare(source, target interface{}, opts ...Option) (Patch, error) {
 	return compare(&d, source, target)
 }
 
+// CompareTyped is similar to Compare, but requires the
+// values to be of the same type, such as two versions of
+// a configuration structure, which is enforced at compile
+// time. The values are marshaled and unmarshaled with the
+// functions of the MarshalFunc and UnmarshalFunc options,
+// if any, and compared like with Compare.
+func CompareTyped[T any](source, target T, opts ...Option) (Patch, error) {
+	var d Differ
+	d.applyOpts(opts...)
+
+	return compare(&d, source, target)
+}
+
 // CompareJSON compares the given JSON documents and
 // returns the differences relative to the former as
-// a list of JSON Patch operations.
+// a list of JSON Patch operations. If one of them cannot
+// be decoded, the error is a *DecodeError.
 func CompareJSON(source, target []byte, opts ...Option) (Patch, error) {
 	var d Differ
 	d.applyOpts(opts...)
@@ -25,10 +106,48 @@ func CompareJSON(source, target []byte, opts ...Option) (Patch, error) {
 	return compareJSON(&d, source, target, d.opts.unmarshal)
 }
 
+// Equal returns whether the JSON representations of the
+// given values are equal, according to the given options.
+// It performs the same comparison as Compare, but returns
+// at the first difference found, without generating any
+// operation. For example, the locations ignored with the
+// Ignores option don't count as differences, and arrays
+// are compared regardless of the order of their elements
+// with the Equivalent option. It returns false if the values
+// cannot be compared, such as when one of them cannot be
+// marshaled, or when the MaxDepth option aborts the comparison.
+func Equal(source, target interface{}, opts ...Option) bool {
+	var d Differ
+	d.applyOpts(opts...)
+	d.opts.equalOnly = true
+
+	if _, err := compare(&d, source, target); err != nil {
+		return false
+	}
+	return !d.differs
+}
+
+// EqualJSON is similar to Equal, but compares the given
+// JSON documents, and returns an error if they cannot be
+// unmarshaled, which is a *DecodeError, or compared.
+func EqualJSON(source, target []byte, opts ...Option) (bool, error) {
+	var d Differ
+	d.applyOpts(opts...)
+	d.opts.equalOnly = true
+
+	if _, err := compareJSON(&d, source, target, d.opts.unmarshal); err != nil {
+		return false, err
+	}
+	return !d.differs, nil
+}
+
 // CompareWithoutMarshal is similar to Compare, but it assumes
 // that the given interface values consists only of primitives
 // Go types that are recognized by the json.Unmarshal function,
 // and therefore does not marshal/unmarshal before comparison.
+// The numbers may also be *big.Int and *big.Float values, such
+// as those of a custom decoder, which are compared and hashed
+// by their exact value, including with the other numbers.
 func CompareWithoutMarshal(source, target interface{}, opts ...Option) (patch Patch, err error) {
 	var d Differ
 
@@ -41,18 +160,17 @@ func CompareWithoutMarshal(source, target interface{}, opts ...Option) (patch Pa
 	}()
 	d.applyOpts(opts...)
 	d.Compare(source, target)
+	if d.err != nil {
+		return nil, d.err
+	}
 	patch = d.patch
 
 	return patch, err
 }
 
 func compare(d *Differ, src, tgt interface{}) (Patch, error) {
-	if d.opts.marshal == nil {
-		d.opts.marshal = json.Marshal
-	}
-	if d.opts.unmarshal == nil {
-		d.opts.unmarshal = json.Unmarshal
-	}
+	d.opts.setDefaultCodec()
+
 	si, _, err := marshalUnmarshal(src, d.opts)
 	if err != nil {
 		return nil, err
@@ -64,6 +182,9 @@ func compare(d *Differ, src, tgt interface{}) (Patch, error) {
 	d.targetBytes = tb
 
 	d.Compare(si, ti)
+	if d.err != nil {
+		return nil, d.err
+	}
 	return d.patch, nil
 }
 
@@ -73,17 +194,193 @@ func compareJSON(d *Differ, src, tgt []byte, unmarshal unmarshalFunc) (Patch, er
 	}
 	var si, ti interface{}
 	if err := unmarshal(src, &si); err != nil {
-		return nil, err
+		return nil, &DecodeError{Side: SourceDocument, Err: err}
 	}
 	if err := unmarshal(tgt, &ti); err != nil {
-		return nil, err
+		return nil, &DecodeError{Side: TargetDocument, Err: err}
 	}
 	d.targetBytes = tgt
+	d.rawSource, d.rawTarget = src, tgt
+
+	d.Compare(si, ti)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.patch, nil
+}
+
+// CompareReaders is similar to CompareJSON, but decodes the
+// JSON documents read from the given readers, without reading
+// them in memory first, unless a function is set with the
+// UnmarshalFunc option. See Differ.CompareReaders.
+func CompareReaders(source, target io.Reader, opts ...Option) (Patch, error) {
+	var d Differ
+	d.applyOpts(opts...)
+
+	return d.CompareReaders(source, target)
+}
+
+// CompareReaders decodes the JSON documents read from the
+// given readers, and compares them. The errors that occur
+// while reading or decoding a document are returned as a
+// *DecodeError that identifies the document.
+// Since the Rationalize option requires the JSON representation
+// of the target document, it is marshaled again once decoded,
+// which has a cost that CompareJSON doesn't have.
+func (d *Differ) CompareReaders(source, target io.Reader) (Patch, error) {
+	si, err := d.opts.decodeReader(source)
+	if err != nil {
+		return nil, &DecodeError{Side: SourceDocument, Err: err}
+	}
+	ti, err := d.opts.decodeReader(target)
+	if err != nil {
+		return nil, &DecodeError{Side: TargetDocument, Err: err}
+	}
+	d.targetBytes = nil
+	if d.opts.rationalize {
+		d.opts.setDefaultCodec()
+		if d.targetBytes, err = d.opts.marshal(ti); err != nil {
+			return nil, err
+		}
+	}
+	d.Compare(si, ti)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.patch, nil
+}
+
+// CompareFiles is similar to CompareJSON, but reads the JSON
+// documents from the files located at the given paths. The
+// errors that occur while reading or decoding a document
+// identify the file, and those of the decoding are returned
+// as a *DecodeError.
+func CompareFiles(srcPath, tgtPath string, opts ...Option) (Patch, error) {
+	var d Differ
+	d.applyOpts(opts...)
+	d.opts.setDefaultCodec()
+
+	si, sb, err := d.opts.readFile(srcPath, SourceDocument)
+	if err != nil {
+		return nil, err
+	}
+	ti, tb, err := d.opts.readFile(tgtPath, TargetDocument)
+	if err != nil {
+		return nil, err
+	}
+	d.targetBytes = tb
+	d.rawSource, d.rawTarget = sb, tb
+
+	d.Compare(si, ti)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.patch, nil
+}
+
+// readFile reads and decodes the JSON document of the
+// file located at the given path, on the given side.
+func (o *options) readFile(path string, side DocumentSide) (interface{}, []byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("jsondiff: failed to read %s: %w", path, err)
+	}
+	var v interface{}
+	if err := o.unmarshal(b, &v); err != nil {
+		return nil, nil, &DecodeError{Side: side, File: path, Err: err}
+	}
+	return v, b, nil
+}
+
+// CompareToExpected applies the expected patch to a copy of
+// the JSON representation of src, and compares the result
+// with actual. The returned patch represents the changes that
+// are not accounted for by the expected patch, such that an
+// empty patch indicates that the actual value matches the
+// expected state. The expected patch is applied like with
+// Patch.Apply, and the error of an operation that cannot be
+// applied, such as a failed test operation, which wraps
+// ErrTestFailed, is returned.
+func (d *Differ) CompareToExpected(src interface{}, expected Patch, actual interface{}) (Patch, error) {
+	d.opts.setDefaultCodec()
+
+	si, _, err := marshalUnmarshal(src, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	si, err = expected.applyTo(si, d.opts)
+	if err != nil {
+		return nil, fmt.Errorf("jsondiff: failed to apply expected patch: %w", err)
+	}
+	ti, tb, err := marshalUnmarshal(actual, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	d.targetBytes = tb
 
 	d.Compare(si, ti)
+	if d.err != nil {
+		return nil, d.err
+	}
 	return d.patch, nil
 }
 
+// setDefaultCodec sets the marshal and unmarshal functions
+// of the standard library for those that are not configured.
+func (o *options) setDefaultCodec() {
+	if o.marshal == nil {
+		o.marshal = json.Marshal
+	}
+	if o.unmarshal == nil {
+		o.unmarshal = json.Unmarshal
+	}
+}
+
+// unmarshalUseNumber is similar to json.Unmarshal, but
+// decodes the JSON numbers as json.Number values.
+func unmarshalUseNumber(b []byte, v any) error {
+	return decode(bytes.NewReader(b), v, true)
+}
+
+// decode decodes the single JSON value read from r
+// into v, and returns an error if it is followed by
+// other data.
+func decode(r io.Reader, v any, useNumber bool) error {
+	dec := json.NewDecoder(r)
+	if useNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("jsondiff: invalid data after top-level value")
+	}
+	return nil
+}
+
+// decodeReader decodes the JSON document read from r. The
+// document is decoded as it is read by a json.Decoder, unless
+// a function is set with the UnmarshalFunc option, in which
+// case the document is entirely read first.
+func (o *options) decodeReader(r io.Reader) (interface{}, error) {
+	var v interface{}
+	if o.unmarshal != nil && !o.useNumber {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := o.unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	if err := decode(r, &v, o.useNumber); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
 // marshalUnmarshal returns the result of unmarshaling
 // the JSON representation of the given interface value.
 func marshalUnmarshal(v any, opts options) (interface{}, []byte, error) {
@@ -97,3 +394,63 @@ func marshalUnmarshal(v any, opts options) (interface{}, []byte, error) {
 	}
 	return i, b, nil
 }
+
+// CompareDocuments compares two collections of JSON documents
+// indexed by identifier, and returns the differences as a single
+// list of JSON Patch operations, where the path of each operation
+// is prefixed by the identifier of the document it applies to.
+// Documents that are absent from the target collection are removed
+// as a whole, and those absent from the source collection are added.
+func CompareDocuments(source, target map[string]interface{}, opts ...Option) (Patch, error) {
+	ids := make([]string, 0, max(len(source), len(target)))
+
+	for id := range source {
+		ids = append(ids, id)
+	}
+	for id := range target {
+		if _, ok := source[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sortStrings(ids)
+
+	var patch Patch
+	for _, id := range ids {
+		var d Differ
+		d.applyOpts(opts...)
+		d.opts.setDefaultCodec()
+
+		prefix := string(separator) + rfc6901Escaper.Replace(id)
+		src, inSrc := source[id]
+		tgt, inTgt := target[id]
+
+		switch {
+		case inSrc && inTgt:
+			p, err := compare(&d, src, tgt)
+			if err != nil {
+				return nil, fmt.Errorf("jsondiff: document %q: %w", id, err)
+			}
+			for _, op := range p {
+				op.Path = prefix + op.Path
+				if op.hasFrom() {
+					op.From = prefix + op.From
+				}
+				patch = append(patch, op)
+			}
+		case inSrc:
+			v, _, err := marshalUnmarshal(src, d.opts)
+			if err != nil {
+				return nil, fmt.Errorf("jsondiff: document %q: %w", id, err)
+			}
+			d.remove(prefix, v)
+			patch = append(patch, d.patch...)
+		default:
+			v, b, err := marshalUnmarshal(tgt, d.opts)
+			if err != nil {
+				return nil, fmt.Errorf("jsondiff: document %q: %w", id, err)
+			}
+			patch = patch.append(OperationAdd, emptyPointer, prefix, nil, v, len(b))
+		}
+	}
+	return patch, nil
+}
@@ -4,7 +4,20 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func Test_marshalUnmarshal_invalid_JSON(t *testing.T) {
@@ -89,3 +102,797 @@ func Test_compareJSON_marshaling_error(t *testing.T) {
 		t.Errorf("expected non-nil error")
 	}
 }
+
+func TestCompareDocuments(t *testing.T) {
+	source := map[string]interface{}{
+		"a":   map[string]interface{}{"name": "foo", "n": 1},
+		"b/c": []string{"x", "y"},
+		"d":   "removed",
+	}
+	target := map[string]interface{}{
+		"a":   map[string]interface{}{"name": "bar", "n": 1},
+		"b/c": []string{"x", "y"},
+		"e":   true,
+	}
+	patch, err := CompareDocuments(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Patch{
+		{Type: OperationReplace, Path: "/a/name", Value: "bar"},
+		{Type: OperationRemove, Path: "/d"},
+		{Type: OperationAdd, Path: "/e", Value: true},
+	}
+	if len(patch) != len(want) {
+		t.Fatalf("got %d operations, want %d", len(patch), len(want))
+	}
+	for i, op := range patch {
+		if op.Type != want[i].Type || op.Path != want[i].Path || op.Value != want[i].Value {
+			t.Errorf("op #%d mismatch: got %s, want %s", i, op, want[i])
+		}
+	}
+	src, err := json.Marshal(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := patch.apply(src, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt, err := json.Marshal(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unmarshalMarshal(t, b); !bytes.Equal(got, tgt) {
+		t.Errorf("patch does not produce the expected changes")
+		t.Logf("got: %s", got)
+		t.Logf("want: %s", tgt)
+	}
+}
+
+func TestCompareDocuments_error(t *testing.T) {
+	e := errors.New("")
+
+	_, err := CompareDocuments(
+		map[string]interface{}{"a": 1},
+		map[string]interface{}{"a": 2},
+		MarshalFunc(func(any) ([]byte, error) { return nil, e }),
+	)
+	if !errors.Is(err, e) {
+		t.Errorf("expected non-nil error")
+	}
+}
+
+func TestCompare_codecFallback(t *testing.T) {
+	var marshals, unmarshals int
+	marshal := MarshalFunc(func(v any) ([]byte, error) {
+		marshals++
+		return json.Marshal(v)
+	})
+	unmarshal := UnmarshalFunc(func(b []byte, v any) error {
+		unmarshals++
+		return json.Unmarshal(b, v)
+	})
+	src := map[string]interface{}{"a": []interface{}{1, 2}, "b": "foo"}
+	tgt := map[string]interface{}{"a": []interface{}{3, 4}, "b": "bar"}
+
+	// The function of the option that is not set
+	// falls back to the encoding/json package.
+	if _, err := Compare(src, tgt, marshal, Rationalize()); err != nil {
+		t.Fatal(err)
+	}
+	if marshals != 2 || unmarshals != 0 {
+		t.Errorf("got %d marshal and %d unmarshal calls, want 2 and 0", marshals, unmarshals)
+	}
+	marshals = 0
+
+	if _, err := Compare(src, tgt, unmarshal); err != nil {
+		t.Fatal(err)
+	}
+	if marshals != 0 || unmarshals != 2 {
+		t.Errorf("got %d marshal and %d unmarshal calls, want 0 and 2", marshals, unmarshals)
+	}
+	unmarshals = 0
+
+	if _, err := CompareJSON([]byte(`{"a":1}`), []byte(`{"a":2}`), marshal, unmarshal, Rationalize()); err != nil {
+		t.Fatal(err)
+	}
+	if marshals != 0 || unmarshals != 2 {
+		t.Errorf("got %d marshal and %d unmarshal calls, want 0 and 2", marshals, unmarshals)
+	}
+}
+
+func TestCompareWithoutMarshal_nonFinite(t *testing.T) {
+	for _, tc := range []struct {
+		src, tgt interface{}
+		opts     []Option
+		ptr      string
+	}{
+		{
+			map[string]interface{}{"a": 1.0},
+			map[string]interface{}{"a": math.NaN()},
+			nil,
+			"/a",
+		},
+		{
+			map[string]interface{}{},
+			map[string]interface{}{"a": map[string]interface{}{"b~c": []interface{}{1.0, math.Inf(-1)}}},
+			nil,
+			"/a/b~0c/1",
+		},
+		{
+			// The unchanged infinite number becomes part
+			// of the replacement of its parent object.
+			map[string]interface{}{"a": map[string]interface{}{"b": math.Inf(1), "c": 1.0, "d": 2.0, "e": 3.0}},
+			map[string]interface{}{"a": map[string]interface{}{"b": math.Inf(1), "c": 4.0, "d": 5.0, "e": 6.0}},
+			[]Option{Rationalize()},
+			"/a/b",
+		},
+		{
+			// The same NaN value is never equal to itself.
+			[]interface{}{math.NaN()},
+			[]interface{}{math.NaN()},
+			[]Option{Invertible()},
+			"/0",
+		},
+	} {
+		d := (&Differ{}).WithOpts(tc.opts...)
+		if d.opts.rationalize {
+			b, err := json.Marshal(map[string]interface{}{"a": map[string]interface{}{"b": 0, "c": 4, "d": 5, "e": 6}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.targetBytes = b
+		}
+		d.Compare(tc.src, tc.tgt)
+
+		if !errors.Is(d.err, ErrNonFinite) {
+			t.Errorf("got error %v, want %v", d.err, ErrNonFinite)
+			continue
+		}
+		if !strings.Contains(d.err.Error(), strconv.Quote(tc.ptr)) {
+			t.Errorf("error %q does not report pointer %q", d.err, tc.ptr)
+		}
+		if len(d.Patch()) != 0 {
+			t.Errorf("expected empty patch, got:\n%s", d.patch.String())
+		}
+	}
+	// The unchanged non-finite numbers
+	// don't produce any operation.
+	patch, err := CompareWithoutMarshal(
+		map[string]interface{}{"a": math.Inf(1), "b": 1.0},
+		map[string]interface{}{"a": math.Inf(1), "b": 2.0},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 {
+		t.Errorf("expected single operation, got:\n%s", patch.String())
+	}
+}
+
+func TestCompareWithoutMarshal_maxDepth(t *testing.T) {
+	nest := func(depth int, leaf interface{}) interface{} {
+		v := leaf
+		for i := 0; i < depth; i++ {
+			if i%2 == 0 {
+				v = map[string]interface{}{"a": v}
+			} else {
+				v = []interface{}{v}
+			}
+		}
+		return v
+	}
+	src, tgt := nest(100000, "foo"), nest(100000, "bar")
+
+	_, err := CompareWithoutMarshal(src, tgt, MaxDepth(10000))
+	if !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("got error %v, want %v", err, ErrMaxDepth)
+	}
+	// Values within the limit are compared as usual.
+	patch, err := CompareWithoutMarshal(nest(50, "foo"), nest(50, "bar"), MaxDepth(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 || patch[0].Type != OperationReplace {
+		t.Errorf("expected a single replace operation, got %s", &patch)
+	}
+	if _, err := CompareWithoutMarshal(nest(51, "foo"), nest(50, "bar"), MaxDepth(50)); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("got error %v, want %v", err, ErrMaxDepth)
+	}
+}
+
+func TestCompareWithoutMarshal_replaceBeyondMaxDepth(t *testing.T) {
+	nest := func(depth int, leaf interface{}) interface{} {
+		v := leaf
+		for i := 0; i < depth; i++ {
+			v = map[string]interface{}{"a": v, "b": float64(i)}
+		}
+		return v
+	}
+	for _, tc := range []struct {
+		depth    int
+		src, tgt interface{}
+		path     string
+	}{
+		{3, nest(2, "foo"), nest(2, "bar"), "/a/a"},
+		{3, nest(5, "foo"), nest(5, "bar"), "/a/a/a"},
+		{1, nest(2, "foo"), nest(2, "bar"), "/a"},
+	} {
+		patch, err := CompareWithoutMarshal(tc.src, tc.tgt, MaxDepth(tc.depth), ReplaceBeyondMaxDepth())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(patch) != 1 || patch[0].Type != OperationReplace || patch[0].Path != tc.path {
+			t.Errorf("expected a single replace operation of %q, got %s", tc.path, &patch)
+		}
+	}
+	patch, err := CompareWithoutMarshal(nest(5, "foo"), nest(5, "foo"), MaxDepth(1), ReplaceBeyondMaxDepth())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("expected empty patch, got %s", &patch)
+	}
+	// The deepest values outside the paths of
+	// the OnlyPaths option are not replaced.
+	patch, err = CompareWithoutMarshal(nest(3, "foo"), nest(3, "bar"), MaxDepth(1), ReplaceBeyondMaxDepth(), OnlyPaths("/a/a/a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("expected empty patch, got %s", &patch)
+	}
+}
+
+func TestDiffer_CompareToExpected(t *testing.T) {
+	src := map[string]interface{}{"a": 1, "b": []string{"x"}}
+	expected := Patch{
+		{Type: OperationReplace, Path: "/a", Value: 2},
+		{Type: OperationAdd, Path: "/b/-", Value: "y"},
+	}
+	for _, tc := range []struct {
+		actual interface{}
+		want   Patch
+	}{
+		{
+			map[string]interface{}{"a": 2, "b": []string{"x", "y"}},
+			nil,
+		},
+		{
+			map[string]interface{}{"a": 3, "b": []string{"x", "y"}, "c": true},
+			Patch{
+				{Type: OperationReplace, Path: "/a", Value: 3.0},
+				{Type: OperationAdd, Path: "/c", Value: true},
+			},
+		},
+	} {
+		var d Differ
+		patch, err := d.CompareToExpected(src, expected, tc.actual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(patch) != len(tc.want) {
+			t.Errorf("got %d operations, want %d", len(patch), len(tc.want))
+			continue
+		}
+		for i, op := range patch {
+			if op.Type != tc.want[i].Type || op.Path != tc.want[i].Path || op.Value != tc.want[i].Value {
+				t.Errorf("op #%d mismatch: got %s, want %s", i, op, tc.want[i])
+			}
+		}
+	}
+	// The keys that contain the escaped characters of the
+	// JSON Pointer and dot-path notations are matched as-is.
+	src = map[string]interface{}{"a/b": 1, "c~d": 1, "e.f*": 1, "1g": 1}
+	expected = Patch{
+		{Type: OperationReplace, Path: "/a~1b", Value: 2},
+		{Type: OperationReplace, Path: "/c~0d", Value: 2},
+		{Type: OperationReplace, Path: "/e.f*", Value: 2},
+		{Type: OperationReplace, Path: "/1g", Value: 2},
+	}
+	var d Differ
+	actual := map[string]interface{}{"a/b": 2, "c~d": 2, "e.f*": 2, "1g": 2}
+	if patch, err := d.CompareToExpected(src, expected, actual); err != nil {
+		t.Error(err)
+	} else if len(patch) != 0 {
+		t.Errorf("expected empty patch, got %s", &patch)
+	}
+	invalid := Patch{{Type: OperationTest, Path: "/z"}}
+	if _, err := d.CompareToExpected(src, invalid, src); err == nil {
+		t.Errorf("expected non-nil error")
+	}
+	// The test operations of the expected patch
+	// detect the drift of the source document.
+	drifted := Patch{
+		{Type: OperationTest, Path: "/1g", Value: 2},
+		{Type: OperationReplace, Path: "/1g", Value: 3},
+	}
+	if _, err := d.CompareToExpected(src, drifted, actual); !errors.Is(err, ErrTestFailed) {
+		t.Errorf("got error %v, want %v", err, ErrTestFailed)
+	}
+}
+
+type celsius float64
+
+func (c celsius) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%.1f°C"`, float64(c))), nil
+}
+
+func TestCompare_jsonMarshaler(t *testing.T) {
+	type doc struct {
+		Time time.Time `json:"time"`
+		IP   net.IP    `json:"ip"`
+		Temp celsius   `json:"temp"`
+	}
+	now := time.Now() // holds a monotonic clock reading
+	src := doc{
+		Time: now,
+		IP:   net.IPv4(192, 168, 0, 1),
+		Temp: 21.5,
+	}
+	tgt := doc{
+		Time: now.Round(0), // strip monotonic clock reading
+		IP:   net.ParseIP("192.168.0.1"),
+		Temp: 21.54,
+	}
+	patch, err := Compare(src, tgt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("expected empty patch, got %s", &patch)
+	}
+	tgt.Time = now.Add(time.Second)
+	tgt.IP = net.IPv4(10, 0, 0, 1)
+	tgt.Temp = 22
+
+	patch, err = Compare(src, tgt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Patch{
+		{Type: OperationReplace, Path: "/ip", Value: "10.0.0.1"},
+		{Type: OperationReplace, Path: "/temp", Value: "22.0°C"},
+		{Type: OperationReplace, Path: "/time", Value: now.Add(time.Second).Format(time.RFC3339Nano)},
+	}
+	if len(patch) != len(want) {
+		t.Fatalf("got %d operations, want %d", len(patch), len(want))
+	}
+	for i, op := range patch {
+		if op.Type != want[i].Type || op.Path != want[i].Path || op.Value != want[i].Value {
+			t.Errorf("op #%d mismatch: got %s, want %s", i, op, want[i])
+		}
+	}
+}
+
+func TestCompareJSON_integerFloatStrict(t *testing.T) {
+	src := `{"a":1,"b":1.0,"c":2.50,"d":[1,2],"e":3}`
+	tgt := `{"a":1.0,"b":1,"c":2.50,"d":[1.0,2],"e":3}`
+
+	for _, opts := range [][]Option{
+		{UseNumber(), IntegerFloatStrict()},
+		{UseNumber(), IntegerFloatStrict(), Factorize()},
+		{UseNumber(), IntegerFloatStrict(), LCS()},
+		// The numbers are compared by value, and only
+		// the option keeps their forms different.
+		{UseNumber(), NumericValueEquality(), IntegerFloatStrict()},
+		{UseNumber(), NumericValueEquality(), IntegerFloatStrict(), Factorize()},
+		{UseNumber(), NumericValueEquality(), IntegerFloatStrict(), Equivalent()},
+		{UseNumber(), Epsilon(1e-9), IntegerFloatStrict()},
+	} {
+		patch, err := CompareJSON([]byte(src), []byte(tgt), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Patch{
+			{Type: OperationReplace, Path: "/a", Value: json.Number("1.0")},
+			{Type: OperationReplace, Path: "/b", Value: json.Number("1")},
+			{Type: OperationReplace, Path: "/d/0", Value: json.Number("1.0")},
+		}
+		if len(patch) != len(want) {
+			t.Fatalf("got %d operations, want %d:\n%s", len(patch), len(want), patch)
+		}
+		for i, op := range patch {
+			if op.Type != want[i].Type || op.Path != want[i].Path || op.Value != want[i].Value {
+				t.Errorf("op #%d mismatch: got %s, want %s", i, op, want[i])
+			}
+		}
+	}
+	// Without json.Number decoding, the
+	// integer and decimal forms are equal.
+	patch, err := CompareJSON([]byte(src), []byte(tgt), IntegerFloatStrict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("expected empty patch, got:\n%s", patch)
+	}
+}
+
+func TestCompareJSON_numericValueEquality(t *testing.T) {
+	src := `{"a":1.50,"b":1e2,"c":-0,"d":[1.50,2,[1e2]],"e":3,"f":[2,0.5]}`
+	tgt := `{"a":1.5,"b":100,"c":0,"d":[1.5,2,[100]],"e":4,"f":[2,5e-1]}`
+
+	for _, opts := range [][]Option{
+		{UseNumber(), NumericValueEquality()},
+		{UseNumber(), NumericValueEquality(), Factorize()},
+		{UseNumber(), NumericValueEquality(), LCS()},
+		{UseNumber(), NumericValueEquality(), Equivalent()},
+	} {
+		patch, err := CompareJSON([]byte(src), []byte(tgt), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(patch) != 1 || patch[0].Path != "/e" || patch[0].Value != json.Number("4") {
+			t.Errorf("expected a single replace operation of /e, got:\n%s", patch)
+		}
+	}
+	// Combined with IntegerFloatStrict, the integer
+	// and decimal forms of a number remain different.
+	patch, err := CompareJSON(
+		[]byte(`{"a":1.50,"b":1,"c":1e2}`),
+		[]byte(`{"a":1.5,"b":1.0,"c":100}`),
+		UseNumber(), NumericValueEquality(), IntegerFloatStrict(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Patch{
+		{Type: OperationReplace, Path: "/b", Value: json.Number("1.0")},
+		{Type: OperationReplace, Path: "/c", Value: json.Number("100")},
+	}
+	if len(patch) != len(want) {
+		t.Fatalf("got %d operations, want %d:\n%s", len(patch), len(want), patch)
+	}
+	for i, op := range patch {
+		if op.Type != want[i].Type || op.Path != want[i].Path || op.Value != want[i].Value {
+			t.Errorf("op #%d mismatch: got %s, want %s", i, op, want[i])
+		}
+	}
+}
+
+func TestCompareJSON_useNumber(t *testing.T) {
+	src := `{"id":9007199254740993,"a":1,"b":[1.5,2]}`
+	tgt := `{"id":9007199254740992,"a":1,"b":[1.5,2]}`
+
+	// The identifiers are equal once decoded as float64.
+	patch, err := CompareJSON([]byte(src), []byte(tgt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("expected empty patch, got:\n%s", patch)
+	}
+	patch, err = CompareJSON([]byte(src), []byte(tgt), UseNumber())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 || patch[0].Path != "/id" || patch[0].Value != json.Number("9007199254740992") {
+		t.Errorf("expected a single replace operation of /id, got:\n%s", patch)
+	}
+	for _, b := range []string{`{"a":1`, `{"a":1} {}`, `{"a":1}}`} {
+		if _, err := CompareJSON([]byte(b), []byte(tgt), UseNumber()); err == nil {
+			t.Errorf("expected non-nil error for %s", b)
+		}
+	}
+}
+
+func TestDiffer_mixedNumbers(t *testing.T) {
+	src := map[string]interface{}{
+		"a": 1.0,
+		"b": []interface{}{json.Number("2"), 3.5},
+		"c": json.Number("1.0"),
+		"d": 4.0,
+	}
+	tgt := map[string]interface{}{
+		"a": json.Number("1"),
+		"b": []interface{}{2.0, json.Number("3.5")},
+		"c": 1.0,
+		"d": json.Number("5"),
+	}
+	for _, tc := range []struct {
+		opts  []Option
+		paths []string
+	}{
+		{nil, []string{"/c", "/d"}},
+		{[]Option{LCS()}, []string{"/c", "/d"}},
+		{[]Option{NumericValueEquality()}, []string{"/d"}},
+	} {
+		d := new(Differ).WithOpts(tc.opts...)
+		d.Compare(src, tgt)
+
+		var paths []string
+		for _, op := range d.Patch() {
+			if op.Type != OperationReplace {
+				t.Errorf("unexpected operation %s", op)
+			}
+			paths = append(paths, op.Path)
+		}
+		sort.Strings(paths)
+		if !reflect.DeepEqual(paths, tc.paths) {
+			t.Errorf("got replaced paths %v, want %v", paths, tc.paths)
+		}
+	}
+}
+
+func TestCompareReaders(t *testing.T) {
+	src := `{"a":[1,2,3],"b":{"c":"foo"},"id":9007199254740993}`
+	tgt := `{"a":[1,3],"b":{"c":"bar","d":true},"id":9007199254740992}`
+
+	var calls int
+	unmarshal := UnmarshalFunc(func(b []byte, v any) error {
+		calls++
+		return json.Unmarshal(b, v)
+	})
+	for _, opts := range [][]Option{
+		nil,
+		{Factorize(), Rationalize()},
+		{UseNumber()},
+		{UseNumber(), Rationalize()},
+		{unmarshal},
+		{UseNumber(), unmarshal},
+	} {
+		want, err := CompareJSON([]byte(src), []byte(tgt), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls = 0
+
+		patch, err := CompareReaders(strings.NewReader(src), strings.NewReader(tgt), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if patch.String() != want.String() {
+			t.Errorf("got patch:\n%s\nwant:\n%s", patch, want)
+		}
+	}
+	// The last option of the last comparison
+	// replaces the decoding of UseNumber.
+	if calls != 2 {
+		t.Errorf("got %d calls of the unmarshal function, want 2", calls)
+	}
+	for _, tc := range []struct {
+		src, tgt string
+		err      string
+	}{
+		{`{"a":`, tgt, "failed to decode source document"},
+		{src, `{"a":1}}`, "failed to decode target document"},
+	} {
+		_, err := CompareReaders(strings.NewReader(tc.src), strings.NewReader(tc.tgt))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("got error %v, want %q", err, tc.err)
+		}
+	}
+	rerr := errors.New("read error")
+	_, err := CompareReaders(strings.NewReader(src), iotest.ErrReader(rerr), unmarshal)
+	if !errors.Is(err, rerr) {
+		t.Errorf("got error %v, want %v", err, rerr)
+	}
+}
+
+func TestCompareFiles(t *testing.T) {
+	src := `{"a":[1,2,3],"b":{"c":"foo"},"id":9007199254740993}`
+	tgt := `{"a":[1,3],"b":{"c":"bar","d":true},"id":9007199254740992}`
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	srcPath := write("src.json", src)
+	tgtPath := write("tgt.json", tgt)
+	badPath := write("bad.json", `{"a":`)
+
+	for _, opts := range [][]Option{
+		nil,
+		{Factorize(), Rationalize()},
+		{UseNumber()},
+	} {
+		want, err := CompareJSON([]byte(src), []byte(tgt), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patch, err := CompareFiles(srcPath, tgtPath, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if patch.String() != want.String() {
+			t.Errorf("got patch:\n%s\nwant:\n%s", patch, want)
+		}
+	}
+	missing := filepath.Join(dir, "missing.json")
+
+	for _, tc := range []struct {
+		src, tgt string
+		err      string
+	}{
+		{missing, tgtPath, "failed to read " + missing},
+		{srcPath, missing, "failed to read " + missing},
+		{badPath, tgtPath, "failed to decode " + badPath},
+		{srcPath, badPath, "failed to decode " + badPath},
+	} {
+		_, err := CompareFiles(tc.src, tc.tgt)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("got error %v, want %q", err, tc.err)
+		}
+	}
+	if _, err := CompareFiles(missing, tgtPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	valid, invalid := `{"a":1}`, `{"a":`
+
+	path := filepath.Join(t.TempDir(), "doc.json")
+	if err := os.WriteFile(path, []byte(invalid), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	validPath := filepath.Join(t.TempDir(), "valid.json")
+	if err := os.WriteFile(validPath, []byte(valid), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name    string
+		compare func() error
+		side    DocumentSide
+		file    string
+	}{
+		{"json source", func() error {
+			_, err := CompareJSON([]byte(invalid), []byte(valid))
+			return err
+		}, SourceDocument, ""},
+		{"json target", func() error {
+			_, err := CompareJSON([]byte(valid), []byte(invalid))
+			return err
+		}, TargetDocument, ""},
+		{"equal json", func() error {
+			_, err := EqualJSON([]byte(valid), []byte(invalid))
+			return err
+		}, TargetDocument, ""},
+		{"readers", func() error {
+			_, err := CompareReaders(strings.NewReader(invalid), strings.NewReader(valid))
+			return err
+		}, SourceDocument, ""},
+		{"files", func() error {
+			_, err := CompareFiles(validPath, path)
+			return err
+		}, TargetDocument, path},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.compare()
+
+			var de *DecodeError
+			if !errors.As(err, &de) {
+				t.Fatalf("got error %v, want a *DecodeError", err)
+			}
+			if de.Side != tc.side || de.File != tc.file {
+				t.Errorf("got side %q and file %q, want %q and %q", de.Side, de.File, tc.side, tc.file)
+			}
+			if want := "failed to decode " + string(tc.side) + " document"; tc.file == "" && !strings.Contains(err.Error(), want) {
+				t.Errorf("got error %q, want it to contain %q", err, want)
+			}
+			var se *json.SyntaxError
+			if !errors.As(err, &se) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("got error %v, want it to wrap the decoding error", err)
+			}
+		})
+	}
+}
+
+func TestCompareTyped(t *testing.T) {
+	type config struct {
+		Name    string            `json:"name"`
+		Replica int               `json:"replica"`
+		Labels  map[string]string `json:"labels,omitempty"`
+		Ports   []int             `json:"ports"`
+	}
+	src := &config{Name: "api", Replica: 1, Ports: []int{80, 443}}
+	tgt := &config{Name: "api", Replica: 3, Labels: map[string]string{"env": "prod"}, Ports: []int{443}}
+
+	patch, err := CompareTyped(src, tgt, Ignores("/labels"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"op":"remove","path":"/ports/1"}`,
+		`{"value":3,"op":"replace","path":"/replica"}`,
+		`{"value":443,"op":"replace","path":"/ports/0"}`,
+	}
+	var got []string
+	for _, op := range patch {
+		got = append(got, op.String())
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := CompareTyped(func() {}, func() {}); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		tgt  string
+		opts []Option
+		want bool
+	}{
+		{"equal documents", `{"a":[1,{"b":2}]}`, `{"a":[1,{"b":2}]}`, nil, true},
+		{"changed value", `{"a":[1,{"b":2}]}`, `{"a":[1,{"b":3}]}`, nil, false},
+		{"added key", `{"a":1}`, `{"a":1,"b":2}`, nil, false},
+		{"removed element", `[1,2]`, `[1]`, nil, false},
+		{"root type change", `{}`, `[]`, nil, false},
+		{"ignored change", `{"a":1,"b":2}`, `{"a":1,"b":3}`, []Option{Ignores("/b")}, true},
+		{"unignored change", `{"a":1,"b":2}`, `{"a":2,"b":3}`, []Option{Ignores("/b")}, false},
+		{"reordered elements", `{"a":[1,2,3]}`, `{"a":[3,1,2]}`, nil, false},
+		{"equivalent elements", `{"a":[1,2,3]}`, `{"a":[3,1,2]}`, []Option{Equivalent()}, true},
+		{"with factorization", `{"a":[1,2]}`, `{"b":[1,2]}`, []Option{Factorize(), Rationalize()}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := EqualJSON([]byte(tc.src), []byte(tc.tgt), tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("EqualJSON: got %t, want %t", got, tc.want)
+			}
+			var src, tgt interface{}
+			if err := json.Unmarshal([]byte(tc.src), &src); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.tgt), &tgt); err != nil {
+				t.Fatal(err)
+			}
+			if got := Equal(src, tgt, tc.opts...); got != tc.want {
+				t.Errorf("Equal: got %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEqual_errors(t *testing.T) {
+	if Equal(func() {}, nil) {
+		t.Error("expected values that cannot be marshaled to be unequal")
+	}
+	if _, err := EqualJSON([]byte(`{`), []byte(`{}`)); err == nil {
+		t.Error("expected non-nil error")
+	}
+	if Equal([]interface{}{[]interface{}{}}, []interface{}{}, MaxDepth(1)) {
+		t.Error("expected values exceeding max depth to be unequal")
+	}
+}
+
+func TestEqual_testcases(t *testing.T) {
+	for _, f := range []string{
+		"testdata/tests/array.json",
+		"testdata/tests/object.json",
+		"testdata/tests/root.json",
+	} {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cases []testcase
+		if err := json.Unmarshal(b, &cases); err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range cases {
+			for _, opts := range [][]Option{nil, {Equivalent()}, {Ignores(tc.Ignores...)}} {
+				patch, err := Compare(tc.Before, tc.After, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, want := Equal(tc.Before, tc.After, opts...), len(patch) == 0; got != want {
+					t.Errorf("%s: %s: got %t, want %t", f, tc.Name, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// OperationIncrement is the type of the operations generated
+// for the numeric values located at pointers that match one of
+// the patterns of the DeltaFields option, whose value is the
+// difference between the target and source numbers. It is not
+// part of RFC 6902, and Patch.Apply adds the value to the number
+// located at the path of the operation.
+const OperationIncrement = "increment"
+
+// maxDeltaScale is the maximum number of decimal digits
+// of the numbers whose difference is computed exactly,
+// which bounds the size of the arbitrary-precision values.
+const maxDeltaScale = 1000
+
+// diffDelta generates an increment operation for two numbers
+// located at a pointer that matches one of the patterns of the
+// DeltaFields option. It returns false if the values are not
+// numbers, or if their difference cannot be computed.
+func (d *Differ) diffDelta(ptr pointer, src, tgt interface{}) bool {
+	if !matchAny(d.opts.deltas, ptr.string()) {
+		return false
+	}
+	var delta interface{}
+	switch sv := src.(type) {
+	case float64:
+		tv, ok := tgt.(float64)
+		if !ok {
+			return false
+		}
+		s, ok := numberDelta(formatFloat(sv), formatFloat(tv))
+		if !ok {
+			return false
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return false
+		}
+		delta = f
+	case json.Number:
+		tv, ok := tgt.(json.Number)
+		if !ok {
+			return false
+		}
+		s, ok := numberDelta(string(sv), string(tv))
+		if !ok {
+			return false
+		}
+		delta = json.Number(s)
+	default:
+		return false
+	}
+	d.emit(OperationIncrement, emptyPointer, ptr.copy(), src, delta, 0)
+
+	return true
+}
+
+// formatFloat returns the shortest decimal
+// representation of the number.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// numberDelta returns the exact decimal representation of
+// the difference between the JSON numbers tgt and src, such
+// that adding it to src yields tgt, whatever the precision.
+func numberDelta(src, tgt string) (string, bool) {
+	return decimalOp(tgt, src, (*big.Rat).Sub)
+}
+
+// numberSum returns the exact decimal representation
+// of the sum of the JSON numbers a and b.
+func numberSum(a, b string) (string, bool) {
+	return decimalOp(a, b, (*big.Rat).Add)
+}
+
+// decimalOp returns the exact decimal representation of the
+// result of the arithmetic operation on the JSON numbers a and
+// b, without the trailing zeros of its fractional part.
+func decimalOp(a, b string, op func(z, x, y *big.Rat) *big.Rat) (string, bool) {
+	scale := 0
+	for _, s := range []string{a, b} {
+		c, ok := normalizeNumber(s)
+		if !ok {
+			return "", false
+		}
+		// The canonical representation ends
+		// with the exponent of the number.
+		exp, err := strconv.Atoi(c[strings.LastIndexByte(c, 'e')+1:])
+		if c == "0" {
+			exp, err = 0, nil
+		}
+		if err != nil || exp > maxDeltaScale || exp < -maxDeltaScale {
+			return "", false
+		}
+		scale = max(scale, -exp)
+	}
+	var x, y big.Rat
+	if _, ok := x.SetString(a); !ok {
+		return "", false
+	}
+	if _, ok := y.SetString(b); !ok {
+		return "", false
+	}
+	s := op(&x, &x, &y).FloatString(scale)
+	if strings.IndexByte(s, '.') != -1 {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s, true
+}
+
+// incrementNumber returns the sum of the number v and the
+// delta of an increment operation, of the type of v. The sum
+// of json.Number values is exact, whereas the sum of float64
+// values is rounded to the nearest float.
+func incrementNumber(v, delta interface{}) (interface{}, error) {
+	var ds string
+	switch n := delta.(type) {
+	case float64:
+		ds = formatFloat(n)
+	case json.Number:
+		ds = string(n)
+	default:
+		return nil, fmt.Errorf("cannot increment by %s value", jsonTypeSwitch(delta))
+	}
+	switch n := v.(type) {
+	case float64:
+		f, err := strconv.ParseFloat(ds, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delta %s: %w", ds, err)
+		}
+		return n + f, nil
+	case json.Number:
+		s, ok := numberSum(string(n), ds)
+		if !ok {
+			return nil, fmt.Errorf("cannot add delta %s to %s", ds, n)
+		}
+		return json.Number(s), nil
+	default:
+		return nil, fmt.Errorf("cannot increment %s value", jsonTypeSwitch(v))
+	}
+}
@@ -0,0 +1,155 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDiffer_deltaFields(t *testing.T) {
+	src := map[string]interface{}{
+		"views":  float64(10),
+		"rating": 0.1,
+		"stats":  map[string]interface{}{"hits": float64(7), "name": "a"},
+		"other":  float64(1),
+	}
+	tgt := map[string]interface{}{
+		"views":  float64(15),
+		"rating": 0.3,
+		"stats":  map[string]interface{}{"hits": float64(2), "name": "b"},
+		"other":  float64(2),
+	}
+	d := new(Differ).WithOpts(DeltaFields("/views", "/rating", "/stats/*"))
+	d.Compare(src, tgt)
+
+	want := Patch{
+		{Type: OperationReplace, Path: "/other", Value: float64(2)},
+		{Type: OperationIncrement, Path: "/rating", Value: 0.2},
+		{Type: OperationIncrement, Path: "/stats/hits", Value: float64(-5)},
+		{Type: OperationReplace, Path: "/stats/name", Value: "b"},
+		{Type: OperationIncrement, Path: "/views", Value: float64(5)},
+	}
+	patch := d.Patch()
+	if len(patch) != len(want) {
+		t.Fatalf("got %d operations, want %d:\n%s", len(patch), len(want), patch)
+	}
+	for i, op := range patch {
+		if op.Type != want[i].Type || op.Path != want[i].Path || op.Value != want[i].Value {
+			t.Errorf("op #%d mismatch: got %s, want %s", i, op, want[i])
+		}
+	}
+}
+
+func TestCompareJSON_deltaFields(t *testing.T) {
+	useNumber := UnmarshalFunc(func(b []byte, v any) error {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		return dec.Decode(v)
+	})
+	patch, err := CompareJSON(
+		[]byte(`{"a":9007199254740993,"b":1.25,"c":[1,2.5]}`),
+		[]byte(`{"a":9007199254740995,"b":1.5e1,"c":[3,2]}`),
+		useNumber, DeltaFields("/a", "/b", "/c/*"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"value":2,"op":"increment","path":"/a"}`,
+		`{"value":13.75,"op":"increment","path":"/b"}`,
+		`{"value":2,"op":"increment","path":"/c/0"}`,
+		`{"value":-0.5,"op":"increment","path":"/c/1"}`,
+	}
+	if len(patch) != len(want) {
+		t.Fatalf("got %d operations, want %d:\n%s", len(patch), len(want), patch)
+	}
+	for i, op := range patch {
+		if s := op.String(); s != want[i] {
+			t.Errorf("op #%d mismatch: got %s, want %s", i, s, want[i])
+		}
+	}
+}
+
+func TestPatch_Apply_deltaFields(t *testing.T) {
+	useNumber := UnmarshalFunc(func(b []byte, v any) error {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		return dec.Decode(v)
+	})
+	src := `{"a":9007199254740993,"b":0.1,"c":[1,2.5]}`
+	tgt := `{"a":9007199254740995,"b":0.3,"c":[3,2]}`
+
+	patch, err := CompareJSON([]byte(src), []byte(tgt), useNumber, DeltaFields("/a", "/b", "/c/*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := patch.Apply([]byte(src), useNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != tgt {
+		t.Errorf("got %s, want %s", b, tgt)
+	}
+	var d Differ
+	d.WithOpts(useNumber, DeltaFields("/b"))
+	var sv, tv interface{}
+	if err := d.opts.unmarshal([]byte(src), &sv); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.opts.unmarshal([]byte(tgt), &tv); err != nil {
+		t.Fatal(err)
+	}
+	if drift, err := d.CompareToExpected(sv, patch, tv); err != nil || len(drift) != 0 {
+		t.Errorf("got drift %v and error %v, want none", drift, err)
+	}
+	// The sum of floats is rounded.
+	patch = Patch{{Type: OperationIncrement, Path: "/b", Value: 0.2}}
+	if b, err = patch.Apply([]byte(`{"b":0.1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"b":0.30000000000000004}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func Test_numberSum(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want string
+		ok   bool
+	}{
+		{"1", "2", "3", true},
+		{"9007199254740993", "2", "9007199254740995", true},
+		{"0.1", "0.2", "0.3", true},
+		{"1.5", "-1.5", "0", true},
+		{"2.5", "-0.5", "2", true},
+		{"1e2", "-0.5", "99.5", true},
+		{"abc", "1", "", false},
+	} {
+		got, ok := numberSum(tc.a, tc.b)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("numberSum(%q, %q): got (%q, %t), want (%q, %t)", tc.a, tc.b, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func Test_numberDelta(t *testing.T) {
+	for _, tc := range []struct {
+		src, tgt string
+		want     string
+		ok       bool
+	}{
+		{"1", "3", "2", true},
+		{"3", "1", "-2", true},
+		{"0.1", "0.3", "0.2", true},
+		{"1e2", "1.5", "-98.5", true},
+		{"-0", "0.001", "0.001", true},
+		{"1e-2000", "1", "", false},
+		{"abc", "1", "", false},
+	} {
+		got, ok := numberDelta(tc.src, tc.tgt)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("numberDelta(%q, %q): got (%q, %t), want (%q, %t)", tc.src, tc.tgt, got, ok, tc.want, tc.ok)
+		}
+	}
+}
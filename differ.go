@@ -1,8 +1,13 @@
 package jsondiff
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"unsafe"
 )
 
@@ -10,14 +15,34 @@ import (
 // The zero value is an empty generator ready to use.
 type Differ struct {
 	hashmap          map[uint64]jsonNode
+	cache            *nodeCache
 	opts             options
 	patch            Patch
 	snapshotPatchLen int
 	targetBytes      []byte
+	rawSource        []byte
+	rawTarget        []byte
 	ptr              pointer
 	hasher           hasher
+	stats            PatchStats
+	stack            []interface{}
+	err              error
+	preHash          string
+	postHash         string
+	idents           []ElementIdentity
+	ambiguous        []string
+	dropped          []string
+	ruleHits         []uint64
+	token            int
+	deadline         time.Time
+	ctx              context.Context
+	ticks            int
+	differs          bool
 	isCompact        bool
 	compactInPlace   bool
+	index            *sourceIndex
+	indexed          bool
+	prepared         bool
 }
 
 type (
@@ -27,6 +52,8 @@ type (
 
 type options struct {
 	ignores     map[string]struct{}
+	ignoreGlob  []pattern
+	maxDepth    int
 	marshal     marshalFunc
 	unmarshal   unmarshalFunc
 	hasIgnore   bool
@@ -35,6 +62,63 @@ type options struct {
 	invertible  bool
 	equivalent  bool
 	lcs         bool
+	pruneNulls  bool
+	pruneElems  bool
+	strict      bool
+	dedup       bool
+	sameParent  bool
+	dryRun      bool
+	tailIndices int
+	base64      []pattern
+	checksum    bool
+	intFloat    bool
+	sizeGuards  bool
+	groupArrays bool
+	maxRatio    float64
+	appendOnly  []pattern
+	sets        []pattern
+	strictLogs  bool
+	scalars     []scalarDecoder
+	stateHashes bool
+	ignoreKeys  map[string]struct{}
+	costModel   func(Operation) float64
+	partial     bool
+	identities  bool
+	numbers     bool
+	cacheSize   int
+	timeout     time.Duration
+	sizeMetric  bool
+	aligners    []arrayAligner
+	convergent  bool
+	deltas      []pattern
+	ordered     bool
+	equalOnly   bool
+	moves       bool
+	keys        []keyMatcher
+	epsilon     float64
+	relEpsilon  float64
+	useNumber   bool
+	only        []string
+	handler     func(Operation) error
+	maxOps      int
+	truncDepth  bool
+	noCopy      bool
+	nullMissing bool
+	foldKeys    bool
+	explicitIdx bool
+	coalesce    bool
+	equalFuncs  []equalFunc
+	parallel    int
+	sortPaths   bool
+	rawNumbers  bool
+	noRemove    bool
+	trackRules  bool
+	metadata    bool
+	notation    PathNotation
+	maxValue    int
+	elisionSums bool
+	ignoreRules []ignoreRule
+	onlyRules   []ignoreRule
 }
 
 type jsonNode struct {
@@ -47,11 +131,23 @@ type jsonNode struct {
 func (d *Differ) Reset() {
 	d.patch = d.patch[:0]
 	d.ptr.reset()
+	d.stats = PatchStats{}
+	d.err = nil
+	d.preHash, d.postHash = "", ""
+	d.idents = d.idents[:0]
+	d.ambiguous = d.ambiguous[:0]
+	d.dropped = d.dropped[:0]
+	clear(d.ruleHits)
+	d.token = 0
+	d.differs = false
 
 	// Optimized map clear.
 	for k := range d.hashmap {
 		delete(d.hashmap, k)
 	}
+	if d.cache != nil {
+		d.cache.reset()
+	}
 }
 
 // WithOpts applies the given options to the Differ
@@ -70,23 +166,356 @@ func (d *Differ) Patch() Patch {
 	return d.patch
 }
 
+// Operations calls yield for each operation generated by
+// the Differ instance, in order, until yield returns false.
+// Unlike Patch, it does not expose the underlying storage
+// of the patch. Its signature allows to use it as a range
+// over function iterator.
+func (d *Differ) Operations(yield func(Operation) bool) {
+	for _, op := range d.patch {
+		if !yield(op) {
+			return
+		}
+	}
+}
+
+// Stats returns the statistics of the JSON patch operations
+// generated by the Differ instance. When the DryRun option
+// is enabled, they represent the operations that would have
+// been generated.
+func (d *Differ) Stats() PatchStats {
+	if d.opts.dryRun || d.opts.handler != nil {
+		return d.stats
+	}
+	return d.patch.Stats()
+}
+
+// SizeMetrics returns the sizes of the values changed by
+// the JSON patch operations generated by the Differ instance,
+// in the same order as the operations of the patch. It returns
+// nil unless the WithSizeMetrics option is enabled.
+func (d *Differ) SizeMetrics() []OperationSize {
+	if !d.opts.sizeMetric {
+		return nil
+	}
+	sizes := make([]OperationSize, len(d.patch))
+	for i, op := range d.patch {
+		sizes[i] = operationSize(op)
+	}
+	return sizes
+}
+
 // Compare computes the differences between src and tgt
 // as a series of JSON Patch operations.
+// If the MaxDepth option is set and one of the values
+// exceeds the maximum nesting depth, the comparison is
+// aborted and no operations are generated, as well as
+// when the duration set with the WithTimeout option is
+// exceeded, or when an operation would carry a number that
+// is NaN or infinite (see ErrNonFinite). The error of an
+// aborted comparison is returned by CompareWithError.
 func (d *Differ) Compare(src, tgt interface{}) {
-	if d.opts.factorize {
-		d.prepare(d.ptr, src, tgt)
-		d.ptr.reset()
-	}
-	if d.opts.rationalize {
-		if !d.isCompact {
-			if d.compactInPlace {
-				d.targetBytes = compactInPlace(d.targetBytes)
-			} else {
-				d.targetBytes = compact(d.targetBytes)
+	_, _ = d.CompareWithError(src, tgt)
+}
+
+// CompareWithError is similar to Compare, but returns the
+// patch, or the error that aborted the comparison, such as
+// ErrMaxDepth, ErrTimeout, or the error of the function of
+// the WithOperationHandler option. If the Rationalize option
+// is enabled, and the JSON representation of the target isn't
+// known, as is the case when the comparison isn't initiated by
+// one of the package functions, the target is marshaled with
+// the function of the MarshalFunc option, if any, whose error
+// is returned. The patch is valid for usage until the next
+// comparison or reset.
+func (d *Differ) CompareWithError(src, tgt interface{}) (Patch, error) {
+	if d.opts.rationalize && d.targetBytes == nil {
+		d.opts.setDefaultCodec()
+		b, err := d.opts.marshal(tgt)
+		if err != nil {
+			d.err = err
+			return nil, err
+		}
+		d.targetBytes = b
+		defer func() { d.targetBytes = nil }()
+	}
+	d.compare(src, tgt)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.patch, nil
+}
+
+// CompareAt is similar to Compare, but src and tgt are the
+// values located at the base pointer of larger documents, and
+// the paths of the operations are prefixed by base, such that
+// the patches of several parts of a document can be assembled.
+// Unlike the root of a document, the values that are replaced
+// as a whole at base are replaced with a replace operation,
+// since the location must exist. The pointers of the options
+// that filter the locations, such as Ignores and OnlyPaths,
+// are those of the larger documents.
+func (d *Differ) CompareAt(base string, src, tgt interface{}) {
+	_, _ = d.CompareAtWithError(base, src, tgt)
+}
+
+// CompareAtWithError is similar to CompareAt, but returns the
+// patch, or the error that aborted the comparison, like with
+// CompareWithError, including when base isn't a valid pointer.
+func (d *Differ) CompareAtWithError(base string, src, tgt interface{}) (Patch, error) {
+	if _, err := parsePointer(base); err != nil {
+		d.err = fmt.Errorf("jsondiff: invalid base pointer %q: %w", base, err)
+		return nil, d.err
+	}
+	d.ptr.reset()
+	d.ptr.buf = append(d.ptr.buf, base...)
+	defer d.ptr.reset()
+
+	return d.CompareWithError(src, tgt)
+}
+
+// CompareBoth is similar to CompareWithError, but returns both
+// the forward patch, from src to tgt, and the reverse patch,
+// from tgt to src, computed with the same options. The unchanged
+// values indexed by the Factorize option, which are equal in both
+// documents, are indexed once for the two comparisons, unless the
+// PruneNulls option alters the target of each of them. Unlike the
+// reverse patch, the forward patch remains valid after the next
+// comparison or reset, and the other results of the Differ, such
+// as its statistics, are those of the reverse comparison.
+func (d *Differ) CompareBoth(src, tgt interface{}) (forward, reverse Patch, err error) {
+	forward, err = d.CompareWithError(src, tgt)
+	if err != nil {
+		return nil, nil, err
+	}
+	reuse := d.opts.factorize && !d.opts.noCopy && !d.opts.pruneNulls
+
+	hashmap, cache := d.hashmap, d.cache
+	if reuse {
+		d.hashmap, d.cache = nil, nil
+	}
+	// The storage of the patch now belongs
+	// to the forward patch.
+	d.patch = nil
+	d.Reset()
+
+	if reuse {
+		d.hashmap, d.cache = hashmap, cache
+		d.prepared = true
+		defer func() { d.prepared = false }()
+	}
+	reverse, err = d.CompareWithError(tgt, src)
+	if err != nil {
+		return nil, nil, err
+	}
+	return forward, reverse, nil
+}
+
+func (d *Differ) compare(src, tgt interface{}) {
+	d.err = nil
+	d.startDeadline()
+	if d.opts.maxDepth > 0 && !d.opts.truncDepth {
+		if d.exceedsMaxDepth(src) || d.exceedsMaxDepth(tgt) {
+			d.err = ErrMaxDepth
+			return
+		}
+	}
+	if d.opts.dryRun {
+		// Both factorization and rationalization
+		// requires the operations to be generated.
+		d.opts.factorize = false
+		d.opts.rationalize = false
+	}
+	if d.opts.metadata {
+		// The values of the operations, that are
+		// discarded, are required to find the moved
+		// and copied values, and to compare the costs.
+		d.opts.factorize = false
+		d.opts.rationalize = false
+	}
+	if d.opts.equalOnly {
+		// Only the existence of a difference
+		// matters, not the operations.
+		d.differs = false
+		d.opts.factorize = false
+		d.opts.rationalize = false
+	}
+	if d.opts.convergent {
+		// The move and copy operations depend on the
+		// source values, and the tests on their state,
+		// while the replacement of an object discards
+		// the members added to the drifted source.
+		d.opts.factorize = false
+		d.opts.invertible = false
+		d.opts.rationalize = false
+	}
+	if d.opts.partial || d.opts.noRemove {
+		// A replacement of an object by its partial
+		// target would remove the keys not fetched.
+		d.opts.rationalize = false
+	}
+	if d.opts.handler != nil && (d.opts.factorize || d.opts.rationalize || d.opts.groupArrays || d.opts.coalesce || d.opts.sortPaths || d.opts.rawNumbers || d.opts.notation != JSONPointer || d.opts.maxValue > 0 || d.opts.maxRatio > 0 || d.opts.maxOps > 0) {
+		d.err = ErrHandlerOptions
+		return
+	}
+	if d.opts.strict {
+		d.opts.nullMissing = false
+	}
+	d.hasher.nullMissing = d.opts.nullMissing
+	d.hasher.ignoreKeys = d.opts.ignoreKeys
+	d.hasher.foldKeys = d.opts.foldKeys
+
+	if d.opts.pruneNulls && !d.opts.strict {
+		tgt = pruneNulls(tgt, d.opts.pruneElems)
+	}
+	prepares := d.opts.factorize && !d.opts.noCopy && !d.prepared || d.opts.rationalize && !d.isCompact
+
+	// The comparison of equal documents stops at the root,
+	// but the preparation of the target would walk them
+	// first, and is skipped along with the comparison.
+	// The root must be visited to track the rules of the
+	// ignored paths, though.
+	if !prepares || d.opts.trackRules || !deepEqual(src, tgt) {
+		if d.opts.factorize && !d.opts.noCopy && !d.prepared {
+			// Index the unchanged values, which are
+			// the sources of the copy operations.
+			d.prepare(d.ptr, src, tgt)
+		}
+		if d.opts.rationalize {
+			if !d.isCompact {
+				if d.compactInPlace {
+					d.targetBytes = compactInPlace(d.targetBytes)
+				} else {
+					d.targetBytes = compact(d.targetBytes)
+				}
 			}
 		}
+		d.diff(d.ptr, src, tgt, b2s(d.targetBytes))
+	}
+
+	if d.interrupted() || errors.Is(d.err, ErrNonFinite) {
+		d.abort()
+		return
+	}
+	if d.err != nil || d.opts.equalOnly {
+		return
+	}
+	if d.opts.maxRatio > 0 && !d.opts.dryRun && !d.opts.metadata && d.replacesWhole() {
+		d.limitPatchRatio(src, tgt)
+	}
+	if d.opts.coalesce && d.opts.invertible && !d.opts.metadata && !d.opts.hasIgnore && d.opts.ignoreKeys == nil {
+		d.patch = coalesceTests(d.patch, src)
+	}
+	if d.opts.sortPaths {
+		d.patch = sortOperationsByPath(d.patch)
+	}
+	if d.opts.groupArrays {
+		d.patch = groupArrayOperations(d.patch)
+	}
+	if d.opts.rawNumbers && d.rawSource != nil && !d.opts.dryRun {
+		d.preserveNumbers()
+	}
+	if d.opts.maxValue > 0 && !d.opts.dryRun {
+		d.elideValues()
+	}
+	if d.opts.checksum {
+		d.appendChecksum(tgt)
+	}
+	if d.opts.stateHashes {
+		d.hashStates(src, tgt)
+	}
+	if d.opts.notation == DotBracket {
+		base, _ := decodePointer(d.ptr.string())
+		renderDotBracket(d.patch, len(base), src, tgt)
 	}
-	d.diff(d.ptr, src, tgt, b2s(d.targetBytes))
+}
+
+// replacesWhole returns whether the options allow the
+// operations of a value to be replaced by a replacement of
+// the value as a whole, by the MaxPatchRatio and MaxOps
+// options. The replacement would overwrite the ignored
+// values, including the keys of the IgnoreKeysAnywhere option,
+// and remove the values absent from the target, which the
+// NoRemove and PartialMerge options preserve.
+func (d *Differ) replacesWhole() bool {
+	return !d.opts.hasIgnore && d.opts.ignoreKeys == nil && !d.opts.noRemove && !d.opts.partial
+}
+
+// movesInto returns whether one of the operations of the patch
+// that follow the given index moves a value located outside of
+// ptr, whose removal has been replaced by the move operation,
+// such that the operations cannot be discarded.
+func (d *Differ) movesInto(ptr string, from int) bool {
+	for _, op := range d.patch[min(from, len(d.patch)):] {
+		if op.Type == OperationMove && !isPointerPrefix(ptr, op.From) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitPatchRatio replaces the patch with a single
+// replace operation of the root document if its estimated
+// size exceeds the configured ratio of the size of the
+// target document.
+func (d *Differ) limitPatchRatio(src, tgt interface{}) {
+	if len(d.patch) == 0 {
+		return
+	}
+	size := valueLength(tgt)
+	if float64(d.Stats().Size) <= d.opts.maxRatio*float64(size) {
+		return
+	}
+	d.patch = d.patch[:0]
+	d.replace(d.ptr.copy(), src, tgt, "")
+	d.patch[len(d.patch)-1].valueLen = size
+}
+
+// exceedsMaxDepth returns whether the nesting depth of
+// the value exceeds the configured maximum. The value is
+// walked iteratively so that arbitrarily deep values are
+// safe to inspect.
+func (d *Differ) exceedsMaxDepth(v interface{}) bool {
+	if !isContainer(v) {
+		return false
+	}
+	// The stack holds the containers to visit, each
+	// level being terminated by a nil marker, such that
+	// the length of the current path is known.
+	stack := append(d.stack[:0], v)
+	depth := 0
+
+	for len(stack) != 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if e == nil {
+			depth--
+			continue
+		}
+		if depth++; depth > d.opts.maxDepth {
+			d.stack = stack[:0]
+			return true
+		}
+		stack = append(stack, nil)
+
+		switch t := e.(type) {
+		case []interface{}:
+			for _, c := range t {
+				if isContainer(c) {
+					stack = append(stack, c)
+				}
+			}
+		case map[string]interface{}:
+			for _, c := range t {
+				if isContainer(c) {
+					stack = append(stack, c)
+				}
+			}
+		}
+	}
+	d.stack = stack
+	return false
 }
 
 func (d *Differ) isIgnored(ptr pointer) bool {
@@ -100,28 +529,85 @@ func (d *Differ) isIgnored(ptr pointer) bool {
 }
 
 func (d *Differ) findIgnored(ptr pointer) bool {
+	if d.opts.trackRules {
+		d.trackRules(ptr.string())
+	}
 	_, found := d.opts.ignores[ptr.string()]
+	if !found && d.opts.ignoreGlob != nil {
+		found = matchAny(d.opts.ignoreGlob, ptr.string())
+	}
+	if !found && d.opts.only != nil {
+		within, ancestor := d.scope(ptr.string())
+		return !within && !ancestor
+	}
 	return found
 }
 
+// scope returns whether the pointer is located within one
+// of the paths of the OnlyPaths option, or is an ancestor of
+// one of them, in which case the values must be compared only
+// to reach the paths.
+func (d *Differ) scope(ptr string) (within, ancestor bool) {
+	for _, p := range d.opts.only {
+		switch {
+		case isPointerPrefix(p, ptr):
+			return true, false
+		case isPointerPrefix(ptr, p):
+			ancestor = true
+		}
+	}
+	return false, ancestor
+}
+
 func (d *Differ) diff(ptr pointer, src, tgt interface{}, doc string) {
+	if d.differs || d.err != nil {
+		// A difference has already been found,
+		// or the comparison has been aborted.
+		return
+	}
 	if d.isIgnored(ptr) {
 		return
 	}
+	var ancestor bool
+	if d.opts.only != nil {
+		if _, ancestor = d.scope(ptr.string()); ancestor && (!isContainer(src) || !areComparable(src, tgt)) {
+			// The replacement of the value would
+			// change the values outside the paths.
+			return
+		}
+	}
+	if d.opts.equalFuncs != nil && d.customEqual(ptr.string(), src, tgt) {
+		return
+	}
 	if !areComparable(src, tgt) {
 		if ptr.isRoot() {
 			// If incomparable values are located at the root
 			// of the document, use an add operation to replace
 			// the entire content of the document.
 			// https://tools.ietf.org/html/rfc6902#section-4.1
-			d.patch = d.patch.append(OperationAdd, emptyPointer, ptr.copy(), src, tgt, 0)
+			if d.opts.invertible {
+				d.emit(OperationTest, emptyPointer, ptr.copy(), nil, src, 0)
+			}
+			d.emit(OperationAdd, emptyPointer, ptr.copy(), src, tgt, 0)
 		} else {
 			// Values are incomparable, generate a replacement.
 			d.replace(ptr.copy(), src, tgt, doc)
 		}
 		return
 	}
-	if deepEqual(src, tgt) {
+	if deepEqual(src, tgt) || d.normalizes() && d.equal(ptr.string(), src, tgt) {
+		return
+	}
+	if (d.opts.timeout > 0 || d.ctx != nil) && isContainer(src) && d.expired() {
+		return
+	}
+	if d.opts.truncDepth && d.opts.maxDepth > 0 && isContainer(src) && ptr.depth() >= d.opts.maxDepth {
+		// The values are nested deeper than the
+		// limit, and are replaced without being
+		// compared any further.
+		if !ancestor {
+			d.replace(ptr.copy(), src, tgt, doc)
+		}
 		return
 	}
 	// Save the current size of the patch to detect later
@@ -132,6 +618,42 @@ func (d *Differ) diff(ptr pointer, src, tgt interface{}, doc string) {
 	// equivalent.
 	switch val := src.(type) {
 	case []interface{}:
+		if d.opts.convergent {
+			// The indices of the elements of an
+			// array depend on its source value.
+			d.replace(ptr.copy(), src, tgt, doc)
+			return
+		}
+		if d.opts.noRemove {
+			// The removal of an element would shift the
+			// indices of the following ones, that the other
+			// strategies rely on, and only the trailing
+			// elements are left in place.
+			d.compareArrays(ptr, val, tgt.([]interface{}), doc)
+			break
+		}
+		if d.opts.sets != nil && matchAny(d.opts.sets, ptr.string()) && d.unorderedDeepEqualSlice(ptr, val, tgt.([]interface{})) {
+			// The array is a set, whose
+			// elements are only reordered.
+			if d.opts.foldKeys {
+				ptr.snapshot()
+				d.renameMatchedKeys(ptr, val, tgt.([]interface{}), d.matchPermutation(ptr, val, tgt.([]interface{})), false, doc)
+				ptr.rewind()
+			}
+			break
+		}
+		if d.opts.appendOnly != nil && d.compareAppendOnly(ptr, val, tgt.([]interface{}), doc) {
+			break
+		}
+		if d.opts.aligners != nil && d.compareAligned(ptr, val, tgt.([]interface{}), doc) {
+			break
+		}
+		if d.opts.keys != nil && d.compareByKey(ptr, val, tgt.([]interface{}), doc) {
+			break
+		}
+		if d.opts.moves && d.compareMoved(ptr, val, tgt.([]interface{})) {
+			break
+		}
 		if d.opts.lcs {
 			d.compareArraysLCS(ptr, val, tgt.([]interface{}), doc)
 		} else {
@@ -140,6 +662,18 @@ func (d *Differ) diff(ptr pointer, src, tgt interface{}, doc string) {
 	case map[string]interface{}:
 		d.compareObjects(ptr, val, tgt.(map[string]interface{}), doc)
 	default:
+		if d.opts.scalars != nil && d.equalScalars(ptr.string(), src, tgt) {
+			break
+		}
+		if d.opts.numbers && d.equalNumbers(src, tgt) {
+			break
+		}
+		if d.opts.base64 != nil && d.diffBase64(ptr, src, tgt) {
+			break
+		}
+		if d.opts.deltas != nil && d.diffDelta(ptr, src, tgt) {
+			break
+		}
 		// Generate a replace operation for
 		// scalar types.
 		if !deepEqual(src, tgt) {
@@ -148,9 +682,16 @@ func (d *Differ) diff(ptr pointer, src, tgt interface{}, doc string) {
 		}
 	}
 	// Rationalize new operations, if any.
-	if d.opts.rationalize && len(d.patch) > size {
+	if d.opts.rationalize && len(d.patch) > size && !ancestor {
 		d.rationalize(ptr, src, tgt, size, doc)
 	}
+	if d.opts.maxOps > 0 && len(d.patch)-size > d.opts.maxOps && !ancestor && d.replacesWhole() && !d.movesInto(ptr.string(), size) {
+		// Replace the value as a whole if its
+		// operations exceed the budget.
+		d.patch = d.patch[:size]
+		d.replace(ptr.copy(), src, tgt, doc)
+		d.patch[len(d.patch)-1].valueLen = valueLength(tgt)
+	}
 }
 
 func (d *Differ) prepare(ptr pointer, src, tgt interface{}) {
@@ -159,14 +700,28 @@ func (d *Differ) prepare(ptr pointer, src, tgt interface{}) {
 	if !areComparable(src, tgt) {
 		return
 	} else if deepEqual(src, tgt) {
-		k := d.hasher.digest(tgt)
-		if d.hashmap == nil {
-			d.hashmap = make(map[uint64]jsonNode)
+		k, ok := d.indexedDigest(ptr.string())
+		if !ok {
+			k = d.hasher.digestAt(ptr.string(), tgt)
 		}
-		d.hashmap[k] = jsonNode{
+		node := jsonNode{
 			ptr: ptr.copy(),
 			val: tgt,
 		}
+		if d.opts.cacheSize > 0 {
+			if d.cache == nil || d.cache.max != d.opts.cacheSize {
+				d.cache = newNodeCache(d.opts.cacheSize)
+			}
+			d.cache.add(k, node)
+			return
+		}
+		if d.hashmap == nil {
+			d.hashmap = make(map[uint64]jsonNode)
+		}
+		d.hashmap[k] = node
+		return
+	}
+	if (d.opts.timeout > 0 || d.ctx != nil) && isContainer(src) && d.expired() {
 		return
 	}
 	// At this point, the source and target values
@@ -185,6 +740,26 @@ func (d *Differ) prepare(ptr pointer, src, tgt interface{}) {
 		oobj := vsrc
 		nobj := tgt.(map[string]interface{})
 
+		if d.opts.ordered {
+			// Visit the keys in lexicographical order, such
+			// that the location of the values that appear at
+			// several places doesn't depend on the iteration
+			// order of the map.
+			keys := make([]string, 0, len(oobj))
+			for k := range oobj {
+				keys = append(keys, k)
+			}
+			sortStrings(keys)
+
+			for _, k := range keys {
+				if v2, ok := nobj[k]; ok {
+					p := ptr.clone()
+					p.appendKey(k)
+					d.prepare(p, oobj[k], v2)
+				}
+			}
+			break
+		}
 		for k, v1 := range oobj {
 			if v2, ok := nobj[k]; ok {
 				p := ptr.clone()
@@ -206,21 +781,29 @@ func (d *Differ) rationalize(ptr pointer, src, tgt interface{}, lastOpIdx int, d
 		OldValue: src,
 		Value:    tgt,
 		valueLen: len(doc),
+		token:    d.token,
 	}
 	curOps := d.patch[lastOpIdx:]
-	curLen := curOps.jsonLength()
 
 	// If one operation is cheaper than many small
 	// operations that represents the changes between
 	// the two objects, replace the last operations.
-	if curLen > replaceOp.jsonLength() {
+	if d.patchCost(curOps) > d.cost(replaceOp) {
 		d.patch = d.patch[:lastOpIdx]
 
 		// Allocate a new string for the operation's path.
 		replaceOp.Path = ptr.copy()
 
+		// The unchanged values of the target, which
+		// have not been verified, are now part of
+		// the operation.
+		if err := nonFiniteError(replaceOp.Path, tgt); err != nil {
+			d.err = err
+			return
+		}
+
 		if d.opts.invertible {
-			d.patch = d.patch.append(OperationTest, emptyPointer, replaceOp.Path, nil, src, len(doc))
+			d.emit(OperationTest, emptyPointer, replaceOp.Path, nil, src, len(doc))
 		}
 		d.patch = append(d.patch, replaceOp)
 	}
@@ -237,6 +820,10 @@ func (d *Differ) compareObjects(ptr pointer, src, tgt map[string]interface{}, do
 	for k := range tgt {
 		cmpSet[k] |= 1 << 1
 	}
+	var aliases map[string]string
+	if d.opts.foldKeys {
+		aliases = d.foldKeys(ptr, src, tgt, cmpSet)
+	}
 	keys := make([]string, 0, len(cmpSet))
 
 	for k := range cmpSet {
@@ -244,8 +831,21 @@ func (d *Differ) compareObjects(ptr pointer, src, tgt map[string]interface{}, do
 	}
 	sortStrings(keys)
 
+	// The keys of the source object whose value
+	// has been moved to another key of the target.
+	var renamed map[string]struct{}
+
+	var results []keyResult
+	if d.parallelizes(len(keys)) {
+		results = d.diffParallel(ptr, keys, cmpSet, aliases, src, tgt, doc)
+	}
 	ptr.snapshot()
-	for _, k := range keys {
+	for i, k := range keys {
+		if d.opts.ignoreKeys != nil {
+			if _, ok := d.opts.ignoreKeys[k]; ok {
+				continue
+			}
+		}
 		v := cmpSet[k]
 		inOld := v&(1<<0) != 0
 		inNew := v&(1<<1) != 0
@@ -254,30 +854,113 @@ func (d *Differ) compareObjects(ptr pointer, src, tgt map[string]interface{}, do
 
 		switch {
 		case inOld && inNew:
+			if sk, ok := aliases[k]; ok {
+				d.renameFoldedKey(ptr, sk, src[sk])
+			}
+			if results != nil {
+				d.mergeKey(results[i])
+				break
+			}
+			sv := src[k]
+			if sk, ok := aliases[k]; ok {
+				sv = src[sk]
+			}
 			if d.opts.rationalize {
-				d.diff(ptr, src[k], tgt[k], findKey(doc, ptr.base.key))
+				d.diff(ptr, sv, tgt[k], findKey(doc, ptr.base.key))
 			} else {
-				d.diff(ptr, src[k], tgt[k], doc)
+				d.diff(ptr, sv, tgt[k], doc)
 			}
 		case inOld && !inNew:
-			if !d.isIgnored(ptr) {
+			if _, ok := renamed[k]; ok {
+				break
+			}
+			if d.opts.nullMissing && src[k] == nil {
+				break
+			}
+			if !d.opts.partial && !d.isIgnored(ptr) {
 				d.remove(ptr.copy(), src[k])
 			}
 		case !inOld && inNew:
-			if !d.isIgnored(ptr) {
-				d.add(ptr.copy(), tgt[k], doc, false)
+			if d.isIgnored(ptr) || d.opts.nullMissing && tgt[k] == nil {
+				break
+			}
+			if d.opts.factorize && !d.opts.partial && !d.opts.noRemove {
+				if rk, ok := d.renameKey(ptr, keys[i+1:], cmpSet, renamed, src, tgt[k], doc); ok {
+					if renamed == nil {
+						renamed = make(map[string]struct{})
+					}
+					renamed[rk] = struct{}{}
+					break
+				}
 			}
+			d.add(ptr.copy(), tgt[k], doc, false)
 		}
 		ptr.rewind()
 	}
 }
 
+// renameKey generates a move operation of the value of a
+// key of the source object that is removed from the target,
+// to the location of an added key, when their values are
+// equal, such as when the key is renamed. The removed keys
+// that precede the added key are found by the add method
+// among the remove operations already generated, and only
+// the following keys are searched, except those whose value
+// has already been moved to another key. It returns the key
+// whose value has been moved, if any.
+func (d *Differ) renameKey(ptr pointer, keys []string, cmpSet map[string]uint8, renamed map[string]struct{}, src map[string]interface{}, v interface{}, doc string) (string, bool) {
+	path := ptr.copy()
+	if d.findRemoved(path, v) != -1 {
+		return "", false
+	}
+	for _, k := range keys {
+		if cmpSet[k] != 1<<0 || !deepEqual(src[k], v) {
+			continue
+		}
+		if _, ok := renamed[k]; ok {
+			// The value can only be moved once,
+			// and is copied or added otherwise.
+			continue
+		}
+		if d.opts.ignoreKeys != nil {
+			if _, ok := d.opts.ignoreKeys[k]; ok {
+				continue
+			}
+		}
+		from := pointer{buf: append([]byte(nil), ptr.buf[:ptr.sep]...)}
+		from.appendKey(k)
+		if d.isIgnored(from) {
+			continue
+		}
+		fp := from.string()
+
+		// https://tools.ietf.org/html/rfc6902#section-4.4
+		// The "from" location MUST NOT be a proper prefix
+		// of the "path" location.
+		if isPointerPrefix(fp, path) {
+			continue
+		}
+		remove := Operation{Type: OperationRemove, Path: fp, OldValue: v}
+		if !d.prefersMove(remove, path, v, doc) {
+			return "", false
+		}
+		if d.opts.invertible {
+			d.emit(OperationTest, emptyPointer, fp, nil, v, 0)
+		}
+		d.emit(OperationMove, fp, path, v, v, 0)
+
+		return k, true
+	}
+	return "", false
+}
+
 // compareArrays generates the patch operations that
 // represents the differences between two JSON arrays.
 func (d *Differ) compareArrays(ptr pointer, src, tgt []interface{}, doc string) {
 	ptr.snapshot()
 	sl, tl := len(src), len(tgt)
 	ml := min(sl, tl)
+	defer d.restoreToken(d.token)
 
 	// When the source array contains more elements
 	// than the target, entries are being removed
@@ -287,24 +970,38 @@ func (d *Differ) compareArrays(ptr pointer, src, tgt []interface{}, doc string)
 		np := ptr.clone()
 		np.appendIndex(ml) // "removal" path
 		p := np.copy()
-		for i := ml; i < sl; i++ {
+		for i, n := ml, sl; i < sl; i++ {
+			d.track(ptr.string(), i, -1)
 			ptr.appendIndex(i)
 
 			if !d.isIgnored(ptr) {
-				d.remove(p, src[i])
+				if d.opts.noRemove {
+					d.dropped = append(d.dropped, ptr.copy())
+				} else if d.isTailIndex(ml, n) {
+					ptr.rewind()
+					ptr.appendTailIndex(ml, n)
+					d.remove(ptr.copy(), src[i])
+				} else {
+					d.remove(p, src[i])
+				}
+				n--
 			}
 			ptr.rewind()
 		}
 		goto comparisons // skip equivalence test since arrays are different
 	}
-	if d.opts.equivalent && d.unorderedDeepEqualSlice(src, tgt) {
+	if d.opts.equivalent && d.unorderedDeepEqualSlice(ptr, src, tgt) {
+		if d.opts.foldKeys {
+			d.renameMatchedKeys(ptr, src, tgt, d.matchPermutation(ptr, src, tgt), false, doc)
+		}
 		return
 	}
 comparisons:
 	// Compare the elements at each index present in
 	// both the source and destination arrays.
 	for i := 0; i < ml; i++ {
-		ptr.appendIndex(i)
+		d.track(ptr.string(), i, i)
+		d.appendIndex(&ptr, i, ml)
 		if d.opts.rationalize {
 			d.diff(ptr, src[i], tgt[i], findIndex(doc, ptr.base.idx))
 		} else {
@@ -319,20 +1016,47 @@ comparisons:
 		np := ptr.clone()
 		np.appendKey("-") // "append" path
 		p := np.copy()
-		for i := ml; i < tl; i++ {
+		for i, n := ml, ml; i < tl; i++ {
+			d.track(ptr.string(), -1, i)
 			ptr.appendIndex(i)
 			if !d.isIgnored(ptr) {
-				d.add(p, tgt[i], doc, false)
+				d.add(d.appendPath(p, n), tgt[i], doc, false)
+				n++
 			}
 			ptr.rewind()
 		}
 	}
 }
 
+// lcsDigestThreshold is the size of the LCS table of two
+// arrays above which their elements are compared by digest,
+// since hashing the elements costs more than comparing them
+// for small arrays.
+const lcsDigestThreshold = 256
+
 func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc string) {
 	ptr.snapshot()
-	pairs := lcs(src, tgt)
+	var pairs [][2]int
+	if d.normalizes() {
+		pairs = lcsFunc(src, tgt, func(i, j int) bool {
+			p := ptr.clone()
+			p.appendIndex(i)
+			return d.matches(p.string(), src[i], tgt[j])
+		})
+	} else if len(src)*len(tgt) > lcsDigestThreshold {
+		// Compare the digests of the elements first, which
+		// is cheaper than comparing nested values, and only
+		// confirm the equality of the elements whose digests
+		// are equal.
+		hs, ht := d.sourceDigests(ptr, src), d.digestElems(tgt)
+		pairs = lcsFunc(src, tgt, func(i, j int) bool {
+			return hs[i] == ht[j] && deepEqual(src[i], tgt[j])
+		})
+	} else {
+		pairs = lcs(src, tgt)
+	}
 	d.snapshotPatchLen = len(d.patch)
+	defer d.restoreToken(d.token)
 
 	var ai, bi int // src && tgt arrows
 	var add, remove int
@@ -342,6 +1066,11 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 		// operations that precede.
 		return i + add - remove
 	}
+	length := func() int {
+		// Length of the array at the time the
+		// current operation is applied.
+		return len(src) + add - remove
+	}
 
 	// Iterate over all the indices of the LCS, which
 	// represent the position of items that are present
@@ -357,7 +1086,8 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 				// Both arrows points to an item before the
 				// current match indice, which indicate an
 				// equal amount of different items.
-				ptr.appendIndex(adjust(ai))
+				d.track(ptr.string(), ai, bi)
+				d.appendIndex(&ptr, adjust(ai), length())
 				if d.opts.rationalize {
 					d.diff(ptr, src[ai], tgt[bi], findIndex(doc, ptr.base.idx))
 				} else {
@@ -370,7 +1100,8 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 				// The left arrow representing the source slice
 				// is lower than the current match indice, which
 				// indicate that a preceding item has been removed.
-				ptr.appendIndex(adjust(ai))
+				d.track(ptr.string(), ai, -1)
+				d.appendIndex(&ptr, adjust(ai), length())
 
 				if !d.isIgnored(ptr) {
 					d.remove(ptr.copy(), src[ai])
@@ -380,7 +1111,8 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 				remove++
 			default: // bi < mb
 				// Opposite case of the previous condition.
-				ptr.appendIndex(bi)
+				d.track(ptr.string(), -1, bi)
+				d.appendIndex(&ptr, bi, length())
 				if !d.isIgnored(ptr) {
 					d.add(ptr.copy(), tgt[bi], doc, true)
 				}
@@ -392,6 +1124,13 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 		// Both arrows reached the current match indice
 		// where the elements of the source and target
 		// slice are equal, i.e. `src[ai] == tgt[bi]`.
+		d.track(ptr.string(), ma, mb)
+		if d.opts.foldKeys && !deepEqual(src[ma], tgt[mb]) {
+			// The keys of the elements differ by case.
+			d.appendIndex(&ptr, adjust(ma), length())
+			d.diff(ptr, src[ma], tgt[mb], doc)
+			ptr.rewind()
+		}
 		ai++
 		bi++
 	}
@@ -402,7 +1141,8 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 	for ai < len(src) || bi < len(tgt) {
 		switch {
 		case ai < len(src) && bi < len(tgt):
-			ptr.appendIndex(adjust(ai))
+			d.track(ptr.string(), ai, bi)
+			d.appendIndex(&ptr, adjust(ai), length())
 			if d.opts.rationalize {
 				d.diff(ptr, src[ai], tgt[bi], findIndex(doc, ptr.base.idx))
 			} else {
@@ -412,7 +1152,8 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 			ai++
 			bi++
 		case ai < len(src):
-			ptr.appendIndex(adjust(ai))
+			d.track(ptr.string(), ai, -1)
+			d.appendIndex(&ptr, adjust(ai), length())
 
 			if !d.isIgnored(ptr) {
 				d.remove(ptr.copy(), src[ai])
@@ -421,7 +1162,8 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 			ai++
 			remove++
 		default: // bi < len(tgt)
-			ptr.appendIndex(bi)
+			d.track(ptr.string(), -1, bi)
+			d.appendIndex(&ptr, bi, length())
 			if !d.isIgnored(ptr) {
 				d.add(ptr.copy(), tgt[bi], doc, true)
 			}
@@ -432,46 +1174,216 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 	}
 }
 
-func (d *Differ) unorderedDeepEqualSlice(src, tgt []interface{}) bool {
+// appendPath returns the path of an element appended to
+// an array of length n, given the path p that appends to
+// the array with the "-" reference token. The path holds
+// the index of the element instead if the Invertible option
+// is enabled, such that the operation can be inverted, or
+// if the ExplicitArrayIndex option is enabled.
+func (d *Differ) appendPath(p string, n int) string {
+	if !d.opts.invertible && !d.opts.explicitIdx {
+		return p
+	}
+	return p[:len(p)-1] + strconv.Itoa(n)
+}
+
+// isTailIndex returns whether the element at index idx
+// of an array of length n is referenced relatively to the
+// end of the array.
+func (d *Differ) isTailIndex(idx, n int) bool {
+	return d.opts.tailIndices > 0 && idx < n && n-idx <= d.opts.tailIndices
+}
+
+// appendIndex appends the index of an element of an array
+// of length n to the pointer, relatively to the end of the
+// array if it is located in the tail region.
+func (d *Differ) appendIndex(ptr *pointer, idx, n int) {
+	if d.isTailIndex(idx, n) {
+		ptr.appendTailIndex(idx, n)
+	} else {
+		ptr.appendIndex(idx)
+	}
+}
+
+func (d *Differ) unorderedDeepEqualSlice(ptr pointer, src, tgt []interface{}) bool {
 	if len(src) != len(tgt) {
 		return false
 	}
-	diff := make(map[uint64]struct{}, len(src))
-	count := 0
+	if d.tolerates() || d.hasher.custom != nil {
+		// The equality of the elements whose
+		// digests are equal must be confirmed.
+		return d.matchPermutation(ptr, src, tgt) != nil
+	}
+	// The arrays are compared as multisets: each
+	// digest must occur as many times in both.
+	diff := make(map[uint64]int, len(src))
 
-	for _, v := range src {
-		k := d.hasher.digest(v)
-		diff[k] = struct{}{}
-		count++
+	for i, v := range src {
+		diff[d.sourceDigest(ptr, i, v)]++
 	}
-	for _, v := range tgt {
-		k := d.hasher.digest(v)
+	for i, v := range tgt {
+		k := d.digestElem(ptr, i, v)
 		// If the digest hash is not in the compare,
-		// return early.
-		if _, ok := diff[k]; !ok {
+		// or occurs less times, return early.
+		if diff[k] == 0 {
 			return false
 		}
-		count--
+		diff[k]--
+	}
+	return true
+}
+
+// digestElems returns the hashes of the elements of an array.
+func (d *Differ) digestElems(a []interface{}) []uint64 {
+	hs := make([]uint64, len(a))
+	for i, v := range a {
+		hs[i] = d.hasher.digest(v)
+	}
+	return hs
+}
+
+// digestElem returns the hash of the element at index i
+// of the array located at ptr. With the Epsilon options, the
+// numbers are not hashed, and the elements whose digests are
+// equal must be compared with Differ.equal.
+func (d *Differ) digestElem(ptr pointer, i int, v interface{}) uint64 {
+	if d.tolerates() {
+		// The numbers that are nearly equal must
+		// have the same digest.
+		d.hasher.tolerant = true
+		defer func() { d.hasher.tolerant = false }()
+	}
+	if d.opts.scalars == nil {
+		return d.hasher.digest(v)
 	}
-	return count == 0
+	ptr.appendIndex(i)
+
+	return d.hasher.digestAt(ptr.string(), v)
+}
+
+// cost returns the cost of the operation, under the cost
+// model of the WithCostModel option if any, or its length
+// in bytes once marshaled to JSON otherwise.
+func (d *Differ) cost(op Operation) float64 {
+	if d.opts.costModel != nil {
+		return d.opts.costModel(op)
+	}
+	return float64(op.jsonLength())
+}
+
+// patchCost returns the total cost of the operations.
+func (d *Differ) patchCost(p Patch) float64 {
+	if d.opts.costModel == nil {
+		return float64(p.jsonLength())
+	}
+	var c float64
+	for _, op := range p {
+		c += d.opts.costModel(op)
+	}
+	return c
+}
+
+// prefersMove returns whether the given remove operation
+// and the addition of its value at path should be replaced
+// by a move operation. Without a cost model, factorized
+// operations are always preferred.
+func (d *Differ) prefersMove(remove Operation, path string, v interface{}, doc string) bool {
+	if d.opts.costModel == nil {
+		return true
+	}
+	move := Operation{Type: OperationMove, From: remove.Path, Path: path, Value: v}
+	add := Operation{Type: OperationAdd, Path: path, Value: v, valueLen: len(doc)}
+
+	return d.cost(move) <= d.cost(remove)+d.cost(add)
+}
+
+// prefersCopy is similar to prefersMove, but it returns
+// whether the addition of the value at path should be
+// replaced by a copy of the unchanged value at from.
+func (d *Differ) prefersCopy(from, path string, v interface{}, doc string) bool {
+	if d.opts.costModel == nil {
+		return true
+	}
+	cp := Operation{Type: OperationCopy, From: from, Path: path, Value: v}
+	add := Operation{Type: OperationAdd, Path: path, Value: v, valueLen: len(doc)}
+
+	return d.cost(cp) <= d.cost(add)
+}
+
+// emit appends a new operation to the patch, or only
+// records its statistics if the DryRun option is enabled.
+func (d *Differ) emit(typ string, from, path string, src, tgt interface{}, vl int) {
+	d.emitOp(Operation{
+		Type:     typ,
+		From:     from,
+		Path:     path,
+		OldValue: src,
+		Value:    tgt,
+		valueLen: vl,
+	})
+}
+
+// emitOp is similar to emit, but it takes
+// the operation to append as is.
+func (d *Differ) emitOp(op Operation) {
+	if d.opts.only != nil {
+		if _, ancestor := d.scope(op.Path); ancestor {
+			// The operation would change the
+			// values outside the paths.
+			return
+		}
+	}
+	if d.opts.equalOnly {
+		d.differs = true
+		return
+	}
+	if d.opts.metadata {
+		op.Value, op.OldValue = nil, nil
+		op.valueLen = 0
+	}
+	if op.marshalWithValue() {
+		if err := nonFiniteError(op.Path, op.Value); err != nil {
+			d.err = err
+			return
+		}
+	}
+	op.token = d.token
+	if d.opts.dryRun {
+		d.stats.add(op)
+		return
+	}
+	if d.opts.handler != nil {
+		if d.err == nil {
+			d.stats.add(op)
+			d.err = d.opts.handler(op)
+		}
+		return
+	}
+	d.patch = append(d.patch, op)
 }
 
 func (d *Differ) replace(path string, src, tgt interface{}, doc string) {
 	vl := len(doc)
 
 	if d.opts.invertible {
-		d.patch = d.patch.append(OperationTest, emptyPointer, path, nil, src, vl)
+		d.emit(OperationTest, emptyPointer, path, nil, src, vl)
 	}
-	d.patch = d.patch.append(OperationReplace, emptyPointer, path, src, tgt, vl)
+	typ := OperationReplace
+	if d.opts.convergent {
+		// An add operation sets the value of an
+		// object member whether it exists or not.
+		typ = OperationAdd
+	}
+	d.emit(typ, emptyPointer, path, src, tgt, vl)
 }
 
 func (d *Differ) add(path string, v interface{}, doc string, lcs bool) {
 	if !d.opts.factorize {
-		d.patch = d.patch.append(OperationAdd, emptyPointer, path, nil, v, 0)
+		d.emit(OperationAdd, emptyPointer, path, nil, v, 0)
 		return
 	}
-	idx := d.findRemoved(v)
-	if idx != -1 {
+	idx := d.findRemoved(path, v)
+	if idx != -1 && d.prefersMove(d.patch[idx], path, v, doc) {
 		op := d.patch[idx]
 
 		// https://tools.ietf.org/html/rfc6902#section-4.4f
@@ -482,43 +1394,80 @@ func (d *Differ) add(path string, v interface{}, doc string, lcs bool) {
 			d.patch = d.patch.remove(idx)
 			if !lcs {
 				d.patch = d.patch.append(OperationMove, op.Path, path, v, v, 0)
+				d.patch[len(d.patch)-1].token = op.token
 			} else {
 				d.patch = d.patch.prepend(d.snapshotPatchLen, OperationMove, op.Path, path, v, v, 0)
+				d.patch[d.snapshotPatchLen].token = op.token
 			}
+			d.unifyToken(op.token)
 		}
 		return
 	}
-	uptr := d.findUnchanged(v)
-
-	if len(uptr) != 0 && !d.opts.invertible {
-		d.patch = d.patch.append(OperationCopy, uptr, path, nil, v, 0)
+	if d.opts.noCopy {
+		d.emit(OperationAdd, emptyPointer, path, nil, v, len(doc))
+		return
+	}
+	uptr := d.findUnchanged(path, v)
+	if d.opts.sameParent && parentPointer(uptr) != parentPointer(path) {
+		uptr = emptyPointer
+	}
+	if len(uptr) != 0 && !d.opts.invertible && d.prefersCopy(uptr, path, v, doc) {
+		d.emit(OperationCopy, uptr, path, nil, v, 0)
 	} else {
-		d.patch = d.patch.append(OperationAdd, emptyPointer, path, nil, v, len(doc))
+		d.emit(OperationAdd, emptyPointer, path, nil, v, len(doc))
 	}
 }
 
 func (d *Differ) remove(path string, v interface{}) {
+	if d.opts.noRemove {
+		d.dropped = append(d.dropped, path)
+		return
+	}
 	if d.opts.invertible {
-		d.patch = d.patch.append(OperationTest, emptyPointer, path, nil, v, 0)
+		d.emit(OperationTest, emptyPointer, path, nil, v, 0)
+	}
+	op := Operation{
+		Type:     OperationRemove,
+		Path:     path,
+		OldValue: v,
 	}
-	d.patch = d.patch.append(OperationRemove, emptyPointer, path, v, nil, 0)
+	if d.opts.sizeGuards {
+		op.Size = containerSize(v)
+	}
+	d.emitOp(op)
 }
 
-func (d *Differ) findUnchanged(v interface{}) string {
-	if d.hashmap != nil {
-		k := d.hasher.digest(v)
-		node, ok := d.hashmap[k]
-		if ok {
-			return node.ptr
+func (d *Differ) findUnchanged(path string, v interface{}) string {
+	var (
+		node jsonNode
+		ok   bool
+	)
+	if d.opts.cacheSize > 0 {
+		if d.cache != nil {
+			node, ok = d.cache.get(d.hasher.digestAt(path, v))
 		}
+	} else if d.hashmap != nil {
+		node, ok = d.hashmap[d.hasher.digestAt(path, v)]
+	}
+	// Confirm the equality of the values to protect
+	// against hash collisions. The copied value must
+	// hold the ignored keys of the added value.
+	if ok && (deepEqual(node.val, v) || d.normalizes() && d.opts.ignoreKeys == nil && d.equal(path, node.val, v)) {
+		return node.ptr
 	}
 	return emptyPointer
 }
 
-func (d *Differ) findRemoved(v interface{}) int {
+func (d *Differ) findRemoved(path string, v interface{}) int {
 	for i := 0; i < len(d.patch); i++ {
 		op := d.patch[i]
-		if op.Type == OperationRemove && deepEqual(op.OldValue, v) {
+		if op.Type != OperationRemove {
+			continue
+		}
+		if d.opts.sameParent && parentPointer(op.Path) != parentPointer(path) {
+			continue
+		}
+		if deepEqual(op.OldValue, v) {
 			return i
 		}
 	}
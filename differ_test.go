@@ -2,14 +2,21 @@ package jsondiff
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 var testNameReplacer = strings.NewReplacer(",", "", "(", "", ")", "")
@@ -58,6 +65,31 @@ func TestDiffer_Reset(t *testing.T) {
 	}
 }
 
+func TestDiffer_Operations(t *testing.T) {
+	d := Differ{}
+	d.Compare(
+		map[string]interface{}{"a": "1", "b": "2", "c": "3"},
+		map[string]interface{}{"a": "3", "b": "4"},
+	)
+	var ops []Operation
+
+	d.Operations(func(op Operation) bool {
+		ops = append(ops, op)
+		return true
+	})
+	if !reflect.DeepEqual(ops, []Operation(d.Patch())) {
+		t.Errorf("operations mismatch patch")
+	}
+	n := 0
+	d.Operations(func(op Operation) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("expected iteration to stop after 2 operations, got %d", n)
+	}
+}
+
 func TestOptions(t *testing.T) {
 	makeopts := func(opts ...Option) []Option { return opts }
 
@@ -66,11 +98,60 @@ func TestOptions(t *testing.T) {
 		options  []Option
 	}{
 		{"testdata/tests/options/invertible.json", makeopts(Invertible())},
+		{"testdata/tests/options/coalesce-tests.json", makeopts(Invertible(), CoalesceTests())},
 		{"testdata/tests/options/factorization.json", makeopts(Factorize())},
+		{"testdata/tests/options/null-missing.json", makeopts(NullEqualsMissing())},
+		{"testdata/tests/options/case-insensitive-keys.json", makeopts(CaseInsensitiveKeys())},
+		{"testdata/tests/options/no-copy.json", makeopts(Factorize(), NoCopy())},
+		{"testdata/tests/options/explicit-index.json", makeopts(ExplicitArrayIndex())},
 		{"testdata/tests/options/rationalization.json", makeopts(Rationalize())},
 		{"testdata/tests/options/equivalence.json", makeopts(Equivalent())},
 		{"testdata/tests/options/ignore.json", makeopts()},
 		{"testdata/tests/options/lcs.json", makeopts(LCS(), Factorize())},
+		{"testdata/tests/options/prune.json", makeopts(PruneTargetNulls())},
+		{"testdata/tests/options/prune-elements.json", makeopts(PruneTargetNullElements())},
+		{"testdata/tests/options/presence.json", makeopts()},
+		{"testdata/tests/options/presence.json", makeopts(StrictPresence(), PruneTargetNulls())},
+		{"testdata/tests/options/same-parent.json", makeopts(Factorize(), SameParentMovesOnly())},
+		{"testdata/tests/options/negative-indices.json", makeopts(NegativeArrayIndices(2))},
+		{"testdata/tests/options/negative-indices-lcs.json", makeopts(NegativeArrayIndices(2), LCS())},
+		{"testdata/tests/options/base64.json", makeopts(DecodeBase64JSON("/token", "/items/*/data"))},
+		{"testdata/tests/options/size-guards.json", makeopts(WithRemoveSizeGuards())},
+		{"testdata/tests/options/group-arrays.json", makeopts(GroupArrayOps())},
+		{"testdata/tests/options/sort-by-path.json", makeopts(SortByPath())},
+		{"testdata/tests/options/sort-by-path-moves.json", makeopts(SortByPath(), Factorize(), LCS())},
+		{"testdata/tests/options/no-remove.json", makeopts(NoRemove())},
+		{"testdata/tests/options/no-remove.json", makeopts(NoRemove(), Factorize(), Rationalize(), LCS())},
+		{"testdata/tests/options/ratio.json", makeopts(MaxPatchRatio(1.5))},
+		{"testdata/tests/options/max-ops.json", makeopts(MaxOps(2))},
+		{"testdata/tests/options/max-value-bytes.json", makeopts(MaxValueBytes(16))},
+		{"testdata/tests/options/append-only.json", makeopts(AppendOnly("/logs", "/jobs/*/events"))},
+		{"testdata/tests/options/set-paths.json", makeopts(SetPaths("/tags", "/items/*/labels"))},
+		{"testdata/tests/options/scalar-decoder.json", makeopts(
+			WithScalarDecoder("/owner", decodeUserID),
+			WithScalarDecoder("/ids/*", decodeUserID),
+		)},
+		{"testdata/tests/options/equal-func.json", makeopts(
+			EqualFunc("/updated", sameSecond),
+			EqualFunc("/items/*/url", sameURL),
+		)},
+		{"testdata/tests/options/equal-func.json", makeopts(
+			EqualFunc("/updated", sameSecond),
+			EqualFunc("/items/*/url", sameURL),
+			LCS(),
+		)},
+		{"testdata/tests/options/ignore-keys.json", makeopts(IgnoreKeysAnywhere("_rev", "_ts", "_etag"))},
+		{"testdata/tests/options/partial-merge.json", makeopts(PartialMerge())},
+		{"testdata/tests/options/partial-merge.json", makeopts(PartialMerge(), Rationalize())},
+		{"testdata/tests/options/array-alignment.json", makeopts(WithArrayAlignment("/items", alignByID))},
+		{"testdata/tests/options/deterministic-order.json", makeopts(Factorize(), DeterministicOrder())},
+		{"testdata/tests/options/array-moves.json", makeopts(DetectArrayMoves())},
+		{"testdata/tests/options/match-by-key.json", makeopts(MatchByKey("/items", "id"))},
+		{"testdata/tests/options/only-paths.json", makeopts(OnlyPaths("/spec", "/status/phase"))},
+		{"testdata/tests/options/only-paths.json", makeopts(OnlyPaths("/spec", "/status/phase"), Factorize())},
+		{"testdata/tests/options/only-paths.json", makeopts(OnlyPaths("0/spec", "0/status/phase", "1/status"))},
+		{"testdata/tests/options/epsilon.json", makeopts(Epsilon(1e-9))},
+		{"testdata/tests/options/epsilon.json", makeopts(RelativeEpsilon(1e-9))},
 		{"testdata/tests/options/all.json", makeopts(Factorize(), Rationalize(), Invertible(), Equivalent())},
 	} {
 		var (
@@ -152,6 +233,9 @@ func runTestCase(t *testing.T, tc testcase, pc patchGetter, opts ...Option) {
 		if g, w := op.Path, want.Path; g != w {
 			t.Errorf("op #%d mismatch: path: got %q, want %q", i, g, w)
 		}
+		if g, w := op.Size, want.Size; !reflect.DeepEqual(g, w) {
+			t.Errorf("op #%d mismatch: size: got %v, want %v", i, g, w)
+		}
 		switch want.Type {
 		case OperationCopy, OperationMove:
 			if g, w := op.From, want.From; g != w {
@@ -196,6 +280,103 @@ func runTestCase(t *testing.T, tc testcase, pc patchGetter, opts ...Option) {
 	}
 }
 
+// TestDiffer_targetKeys verifies that the operations always
+// reference the actual keys of the target document, properly
+// escaped, whatever the options used to compare the documents.
+func TestDiffer_targetKeys(t *testing.T) {
+	makeopts := func(opts ...Option) []Option { return opts }
+
+	src := map[string]interface{}{
+		"Foo":  "a",
+		"a/b":  map[string]interface{}{"~1": 1.0, "x.y": 2.0},
+		"list": []interface{}{map[string]interface{}{"K": "v"}},
+		"é~":   true,
+	}
+	tgt := map[string]interface{}{
+		"foo":  "a",
+		"a/b":  map[string]interface{}{"~1": 3.0, "X.y": 2.0, "~01": 4.0},
+		"list": []interface{}{map[string]interface{}{"k": "v"}, "w"},
+		"É~":   true,
+	}
+	for _, opts := range [][]Option{
+		makeopts(),
+		makeopts(Factorize()),
+		makeopts(Rationalize()),
+		makeopts(Invertible()),
+		makeopts(LCS()),
+		makeopts(Factorize(), Rationalize(), Invertible(), Equivalent(), LCS()),
+		makeopts(CaseInsensitiveKeys()),
+		makeopts(CaseInsensitiveKeys(), Factorize()),
+		makeopts(CaseInsensitiveKeys(), Rationalize()),
+		makeopts(CaseInsensitiveKeys(), Invertible()),
+		makeopts(CaseInsensitiveKeys(), LCS()),
+		makeopts(CaseInsensitiveKeys(), Factorize(), Rationalize(), Invertible(), Equivalent(), LCS()),
+	} {
+		b, err := json.Marshal(tgt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &Differ{targetBytes: b}
+		d.WithOpts(opts...).Compare(src, tgt)
+
+		patch := d.Patch()
+		for _, op := range patch {
+			if op.Type == OperationRemove || op.Type == OperationTest {
+				continue
+			}
+			if !hasTargetKeys(t, tgt, op.Path) {
+				t.Errorf("operation %s does not reference the target keys", op)
+			}
+		}
+		a, err := json.Marshal(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err = patch.apply(a, true)
+		if err != nil {
+			t.Fatalf("failed to apply patch: %s", err)
+		}
+		if got := unmarshalMarshal(t, a); !bytes.Equal(got, b) {
+			t.Errorf("patch does not produce the expected changes")
+			t.Logf("got: %s", got)
+			t.Logf("want: %s", b)
+		}
+	}
+}
+
+// hasTargetKeys returns whether all the object keys
+// referenced by the pointer exist in the target value.
+func hasTargetKeys(t *testing.T, tgt interface{}, ptr string) bool {
+	t.Helper()
+
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := tgt
+	for _, tok := range tokens {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			e, ok := val[rfc6901Unescaper.Replace(tok)]
+			if !ok {
+				return false
+			}
+			v = e
+		case []interface{}:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i >= len(val) {
+				// Past-the-end elements are not
+				// part of the target document.
+				return true
+			}
+			v = val[i]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func TestDiffer_unorderedDeepEqualSlice(t *testing.T) {
 	for _, tc := range []struct {
 		src, tgt []interface{}
@@ -230,7 +411,7 @@ func TestDiffer_unorderedDeepEqualSlice(t *testing.T) {
 		},
 	} {
 		d := Differ{}
-		eq := d.unorderedDeepEqualSlice(tc.src, tc.tgt)
+		eq := d.unorderedDeepEqualSlice(pointer{}, tc.src, tc.tgt)
 		if eq != tc.equal {
 			t.Errorf("equality mismatch, got %t, want %t", eq, tc.equal)
 		}
@@ -417,3 +598,1518 @@ func unmarshalMarshal(t *testing.T, b []byte) []byte {
 	}
 	return b2
 }
+
+func TestDiffer_maxPatchRatio(t *testing.T) {
+	src := map[string]interface{}{"a": 1.0, "b": 2.0}
+	tgt := map[string]interface{}{"c": 1.0, "d": 2.0}
+
+	patch, err := Compare(src, tgt, MaxPatchRatio(1), Invertible())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 2 || patch[0].Type != OperationTest || patch[1].Type != OperationReplace {
+		t.Fatalf("expected root test and replace operations, got:\n%s", patch)
+	}
+	if patch[0].Path != "" || patch[1].Path != "" {
+		t.Errorf("expected root operations, got:\n%s", patch)
+	}
+	// The ignored values must not be overwritten.
+	patch, err = Compare(src, tgt, MaxPatchRatio(1), Ignores("/a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 3 {
+		t.Errorf("expected patch to be kept, got:\n%s", patch)
+	}
+}
+
+func TestDiffer_replaceFallbacks(t *testing.T) {
+	src := `{"x":{"a":1,"b":2,"c":3},"y":1}`
+	tgt := `{"x":{"d":1,"e":2,"f":3},"y":2}`
+
+	for _, tc := range []struct {
+		opts []Option
+		want string
+	}{
+		// The values of the source are kept,
+		// rather than replaced by the target.
+		{[]Option{NoRemove(), MaxPatchRatio(0.1)}, `{"x":{"a":1,"b":2,"c":3,"d":1,"e":2,"f":3},"y":2}`},
+		{[]Option{NoRemove(), MaxOps(2)}, `{"x":{"a":1,"b":2,"c":3,"d":1,"e":2,"f":3},"y":2}`},
+		// The keys absent from the partial
+		// target are not fetched.
+		{[]Option{PartialMerge(), MaxPatchRatio(0.1)}, `{"x":{"a":1,"b":2,"c":3,"d":1,"e":2,"f":3},"y":2}`},
+		{[]Option{PartialMerge(), MaxOps(2)}, `{"x":{"a":1,"b":2,"c":3,"d":1,"e":2,"f":3},"y":2}`},
+	} {
+		patch, err := CompareJSON([]byte(src), []byte(tgt), tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := patch.Apply([]byte(src))
+		if err != nil {
+			t.Fatalf("failed to apply patch %s: %s", patch, err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("patch %s applied to %s: got %s, want %s", patch, src, b, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		src, tgt string
+		opts     []Option
+		want     string
+	}{
+		// The value moved into the subtree is
+		// removed from its source location.
+		{
+			`{"a":{"k":"v"},"x":{"p":1,"q":2,"r":3}}`,
+			`{"x":{"s":{"k":"v"},"t":1,"u":2}}`,
+			[]Option{Factorize(), MaxOps(2)},
+			`{"x":{"s":{"k":"v"},"t":1,"u":2}}`,
+		},
+		// The ignored keys are not overwritten.
+		{
+			`{"x":{"id":1,"p":1,"q":2,"r":3}}`,
+			`{"x":{"id":2,"s":1,"t":1,"u":2}}`,
+			[]Option{IgnoreKeysAnywhere("id"), MaxOps(2)},
+			`{"x":{"id":1,"s":1,"t":1,"u":2}}`,
+		},
+		{
+			`{"x":{"id":1,"p":1,"q":2,"r":3}}`,
+			`{"x":{"id":2,"s":1,"t":1,"u":2}}`,
+			[]Option{IgnoreKeysAnywhere("id"), MaxPatchRatio(0.1)},
+			`{"x":{"id":1,"s":1,"t":1,"u":2}}`,
+		},
+	} {
+		patch, err := CompareJSON([]byte(tc.src), []byte(tc.tgt), tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := patch.Apply([]byte(tc.src))
+		if err != nil {
+			t.Fatalf("failed to apply patch %s: %s", patch, err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("patch %s applied to %s: got %s, want %s", patch, tc.src, b, tc.want)
+		}
+	}
+}
+
+func TestDiffer_strictAppendOnly(t *testing.T) {
+	src := map[string]interface{}{"logs": []interface{}{1.0, 2.0}}
+
+	for _, tgt := range []interface{}{
+		map[string]interface{}{"logs": []interface{}{1.0}},
+		map[string]interface{}{"logs": []interface{}{1.0, 3.0, 4.0}},
+	} {
+		_, err := CompareWithoutMarshal(src, tgt, AppendOnly("/logs"), StrictAppendOnly())
+		if !errors.Is(err, ErrAppendOnly) {
+			t.Errorf("expected error to match ErrAppendOnly, got %v", err)
+		}
+	}
+	patch, err := CompareWithoutMarshal(src, map[string]interface{}{
+		"logs": []interface{}{1.0, 2.0, 3.0},
+	}, AppendOnly("/logs"), StrictAppendOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 || patch[0].Path != "/logs/-" {
+		t.Errorf("unexpected patch:\n%s", patch)
+	}
+}
+
+// decodeUserID decodes user identifiers that
+// have an optional "usr_" prefix and are case
+// insensitive.
+func decodeUserID(s string) (string, bool) {
+	s = strings.TrimPrefix(s, "usr_")
+	if s == "" {
+		return "", false
+	}
+	return strings.ToLower(s), true
+}
+
+// sameSecond returns whether two RFC 3339
+// timestamps are equal once truncated to
+// the second.
+func sameSecond(a, b interface{}) bool {
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if !aok || !bok {
+		return false
+	}
+	at, err := time.Parse(time.RFC3339Nano, as)
+	if err != nil {
+		return false
+	}
+	bt, err := time.Parse(time.RFC3339Nano, bs)
+	if err != nil {
+		return false
+	}
+	return at.Truncate(time.Second).Equal(bt.Truncate(time.Second))
+}
+
+// sameURL returns whether two URLs are equal
+// regardless of the order of their query
+// parameters.
+func sameURL(a, b interface{}) bool {
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if !aok || !bok {
+		return false
+	}
+	au, err := url.Parse(as)
+	if err != nil {
+		return false
+	}
+	bu, err := url.Parse(bs)
+	if err != nil {
+		return false
+	}
+	aq, bq := au.Query(), bu.Query()
+	au.RawQuery, bu.RawQuery = "", ""
+
+	return au.String() == bu.String() && aq.Encode() == bq.Encode()
+}
+
+// alignByID aligns the objects of two arrays that have
+// the same "id" key, in the order of the source array.
+func alignByID(src, tgt []interface{}) [][2]int {
+	idx := make(map[interface{}]int, len(tgt))
+	for j, e := range tgt {
+		idx[e.(map[string]interface{})["id"]] = j
+	}
+	var pairs [][2]int
+	for i, e := range src {
+		if j, ok := idx[e.(map[string]interface{})["id"]]; ok {
+			pairs = append(pairs, [2]int{i, j})
+		}
+	}
+	return pairs
+}
+
+func TestDiffer_scalarDecoder_hashing(t *testing.T) {
+	src := map[string]interface{}{
+		"ids": []interface{}{"usr_abc", "usr_def"},
+	}
+	tgt := map[string]interface{}{
+		"ids": []interface{}{"DEF", "abc"},
+	}
+	patch, err := CompareWithoutMarshal(src, tgt, Equivalent(), WithScalarDecoder("/ids/*", decodeUserID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("expected empty patch, got:\n%s", patch)
+	}
+	patch, err = CompareWithoutMarshal(src, tgt, Equivalent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) == 0 {
+		t.Error("expected non-empty patch")
+	}
+}
+
+func TestDiffer_costModel(t *testing.T) {
+	big := strings.Repeat("z", 256)
+	src := map[string]interface{}{
+		"a":   map[string]interface{}{"b": "foo", "c": "bar"},
+		"big": big,
+		"d":   []interface{}{"x", "y"},
+		"e":   map[string]interface{}{"f": true},
+	}
+	tgt := map[string]interface{}{
+		"a":   map[string]interface{}{"b": "baz", "c": "qux"},
+		"big": big,
+		"d":   []interface{}{"x", "y"},
+		"g":   map[string]interface{}{"f": true},
+		"h":   []interface{}{"x", "y"},
+	}
+	types := func(p Patch) []string {
+		var s []string
+		for _, op := range p {
+			s = append(s, op.Type)
+		}
+		return s
+	}
+	// sizes returns a cost model where the cost of the
+	// replace operations is the size of their value, and
+	// the cost of the others is defined by type.
+	sizes := func(m map[string]float64) func(Operation) float64 {
+		return func(op Operation) float64 {
+			if op.Type == OperationReplace {
+				return float64(valueLength(op.Value))
+			}
+			return m[op.Type]
+		}
+	}
+	for _, tc := range []struct {
+		name  string
+		model func(Operation) float64
+		want  []string
+	}{
+		{
+			"default",
+			nil,
+			[]string{"replace", "move", "copy"},
+		},
+		{
+			"sized replace",
+			sizes(map[string]float64{"add": 1, "remove": 1, "move": 1, "copy": 1}),
+			[]string{"replace", "replace", "move", "copy"},
+		},
+		{
+			"expensive factorization",
+			sizes(map[string]float64{"add": 1, "remove": 1, "move": 100, "copy": 100}),
+			[]string{"replace", "replace", "remove", "add", "add"},
+		},
+		{
+			"cheap replace",
+			func(Operation) float64 { return 1 },
+			[]string{"replace"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []Option{Factorize(), Rationalize()}
+			if tc.model != nil {
+				opts = append(opts, WithCostModel(tc.model))
+			}
+			patch, err := Compare(src, tgt, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := types(patch); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q\n%s", got, tc.want, patch)
+			}
+		})
+	}
+}
+
+func TestDiffer_factorizeCache(t *testing.T) {
+	var elems []interface{}
+	for i := 0; i < 100; i++ {
+		elems = append(elems, map[string]interface{}{"id": float64(i)})
+	}
+	src := map[string]interface{}{
+		"a": elems,
+	}
+	tgt := map[string]interface{}{
+		"a": append(elems[:100:100], "new"),
+		"b": map[string]interface{}{"id": float64(0)},
+		"c": map[string]interface{}{"id": float64(99)},
+	}
+	for _, tc := range []struct {
+		size int
+		want []string
+	}{
+		{0, []string{"add", "copy", "copy"}},
+		{10, []string{"add", "add", "copy"}},
+		{200, []string{"add", "copy", "copy"}},
+	} {
+		d := new(Differ).WithOpts(Factorize(), FactorizeCache(tc.size))
+		d.Compare(src, tgt)
+
+		var types []string
+		for _, op := range d.Patch() {
+			types = append(types, op.Type)
+		}
+		if !reflect.DeepEqual(types, tc.want) {
+			t.Errorf("size %d: got %q, want %q:\n%s", tc.size, types, tc.want, d.Patch())
+		}
+		if tc.size > 0 {
+			if d.cache == nil || d.cache.len() > tc.size {
+				t.Errorf("size %d: expected cache to be bounded", tc.size)
+			}
+			if d.hashmap != nil {
+				t.Errorf("size %d: expected hashmap to be unused", tc.size)
+			}
+		}
+	}
+}
+
+func TestDiffer_normalizedReplaces(t *testing.T) {
+	useNumber := UnmarshalFunc(func(b []byte, v any) error {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		return dec.Decode(v)
+	})
+	src := `{
+		"owner": "usr_ABC",
+		"ids": ["usr_abc", "usr_def", "ghi"],
+		"nested": {
+			"list": [{"id": "usr_A", "n": 1.50}, {"id": "B", "n": [1e2, -0]}],
+			"n": {"a": 0.5, "b": [10, 20]}
+		},
+		"logs": [{"by": "usr_x", "at": 1.0}],
+		"changed": 1
+	}`
+	tgt := `{
+		"owner": "abc",
+		"ids": ["ABC", "usr_DEF", "ghi"],
+		"nested": {
+			"list": [{"id": "a", "n": 1.5}, {"id": "usr_b", "n": [100, 0]}],
+			"n": {"a": 5e-1, "b": [1e1, 2e1]}
+		},
+		"logs": [{"by": "X", "at": 1}, {"by": "y", "at": 2}],
+		"changed": 2
+	}`
+	scalars := []Option{
+		WithScalarDecoder("/owner", decodeUserID),
+		WithScalarDecoder("/ids/*", decodeUserID),
+		WithScalarDecoder("/nested/list/*/id", decodeUserID),
+		WithScalarDecoder("/logs/*/by", decodeUserID),
+	}
+	normalizations := map[string][]Option{
+		"scalars": scalars,
+		"numbers": {NumericValueEquality()},
+		"all":     append([]Option{NumericValueEquality()}, scalars...),
+	}
+	structures := map[string][]Option{
+		"default":         nil,
+		"lcs":             {LCS()},
+		"factorize":       {Factorize()},
+		"rationalize":     {Rationalize()},
+		"equivalent":      {Equivalent()},
+		"append-only":     {AppendOnly("/logs")},
+		"factorize+ratio": {Factorize(), Rationalize()},
+		"lcs+rationalize": {LCS(), Rationalize()},
+		"lcs+equivalent":  {LCS(), Equivalent()},
+	}
+	for nn, norm := range normalizations {
+		for sn, st := range structures {
+			opts := append(append([]Option{useNumber}, norm...), st...)
+
+			d := new(Differ).WithOpts(opts...)
+			d.opts.setDefaultCodec()
+
+			var sv, tv interface{}
+			if err := d.opts.unmarshal([]byte(src), &sv); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.opts.unmarshal([]byte(tgt), &tv); err != nil {
+				t.Fatal(err)
+			}
+			patch, err := CompareJSON([]byte(src), []byte(tgt), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, op := range patch {
+				if op.Type != OperationReplace {
+					continue
+				}
+				old, err := valueAt(sv, op.Path)
+				if err != nil {
+					t.Fatalf("%s/%s: %s", nn, sn, err)
+				}
+				if d.equal(op.Path, old, op.Value) {
+					t.Errorf("%s/%s: replace operation of equal normalized values: %s", nn, sn, op)
+				}
+			}
+			if nn == "all" {
+				var want int
+				for _, op := range patch {
+					if op.Path == "/changed" || op.Type == OperationAdd && strings.HasPrefix(op.Path, "/logs/") {
+						want++
+					}
+				}
+				if want != len(patch) || want == 0 {
+					t.Errorf("%s/%s: unexpected operations:\n%s", nn, sn, patch)
+				}
+			}
+		}
+	}
+	// The LCS of the arrays is computed with
+	// the normalized values of the elements.
+	patch, err := CompareJSON(
+		[]byte(`{"ids":["usr_abc","usr_def"]}`),
+		[]byte(`{"ids":["new","ABC","usr_DEF"]}`),
+		append(scalars, LCS())...,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 || patch[0].Type != OperationAdd || patch[0].Path != "/ids/0" {
+		t.Errorf("expected a single add operation of /ids/0, got:\n%s", patch)
+	}
+}
+
+// valueAt returns the value located at the JSON pointer.
+func valueAt(v interface{}, ptr string) (interface{}, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		t = rfc6901Unescaper.Replace(t)
+		switch c := v.(type) {
+		case map[string]interface{}:
+			v = c[t]
+		case []interface{}:
+			i, err := strconv.Atoi(t)
+			if err != nil || i >= len(c) {
+				return nil, fmt.Errorf("invalid index %q", t)
+			}
+			v = c[i]
+		default:
+			return nil, fmt.Errorf("invalid pointer %q", ptr)
+		}
+	}
+	return v, nil
+}
+
+func TestDiffer_timeout(t *testing.T) {
+	src := make([]interface{}, 1000)
+	tgt := make([]interface{}, 1000)
+	for i := range src {
+		src[i] = map[string]interface{}{"v": float64(i)}
+		tgt[i] = map[string]interface{}{"v": float64(i + 1)}
+	}
+	for _, opts := range [][]Option{
+		{WithTimeout(time.Nanosecond)},
+		{WithTimeout(time.Nanosecond), Factorize()},
+		{WithTimeout(time.Nanosecond), DryRun()},
+	} {
+		d := new(Differ).WithOpts(opts...)
+		d.Compare(src, tgt)
+
+		if !errors.Is(d.err, ErrTimeout) {
+			t.Errorf("got error %v, want %v", d.err, ErrTimeout)
+		}
+		if len(d.Patch()) != 0 || d.Stats().Operations() != 0 {
+			t.Errorf("expected partial results to be discarded, got %d operations", len(d.Patch()))
+		}
+		if _, err := CompareWithoutMarshal(src, tgt, opts...); !errors.Is(err, ErrTimeout) {
+			t.Errorf("got error %v, want %v", err, ErrTimeout)
+		}
+	}
+	patch, err := CompareWithoutMarshal(src, tgt, WithTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != len(src) {
+		t.Errorf("got %d operations, want %d", len(patch), len(src))
+	}
+}
+
+func TestDiffer_CompareWithError(t *testing.T) {
+	src := map[string]interface{}{"a": []interface{}{1.0, 2.0, 3.0}, "b": "foo"}
+	tgt := map[string]interface{}{"a": []interface{}{4.0, 5.0, 6.0}, "b": "foo"}
+
+	d := (&Differ{}).WithOpts(Rationalize())
+	patch, err := d.CompareWithError(src, tgt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The target is marshaled to rationalize the operations.
+	want, err := Compare(src, tgt, Rationalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch.String() != want.String() {
+		t.Errorf("got patch:\n%s\nwant:\n%s", patch.String(), want.String())
+	}
+	if d.targetBytes != nil {
+		t.Errorf("expected marshaled target to be released")
+	}
+	e := errors.New("marshal")
+	for _, tc := range []struct {
+		opts []Option
+		err  error
+	}{
+		{[]Option{MaxDepth(1)}, ErrMaxDepth},
+		{[]Option{Factorize(), WithOperationHandler(func(Operation) error { return nil })}, ErrHandlerOptions},
+		{[]Option{WithOperationHandler(func(Operation) error { return e })}, e},
+		{[]Option{Rationalize(), MarshalFunc(func(any) ([]byte, error) { return nil, e })}, e},
+	} {
+		d := (&Differ{}).WithOpts(tc.opts...)
+		patch, err := d.CompareWithError(src, tgt)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("got error %v, want %v", err, tc.err)
+		}
+		if patch != nil {
+			t.Errorf("expected nil patch, got:\n%s", patch.String())
+		}
+		// The error is also reported by the
+		// comparison without return values.
+		d.Reset()
+		d.Compare(src, tgt)
+		if !errors.Is(d.err, tc.err) {
+			t.Errorf("got error %v, want %v", d.err, tc.err)
+		}
+	}
+}
+
+func TestDiffer_CompareContext(t *testing.T) {
+	src := make([]interface{}, 1000)
+	tgt := make([]interface{}, 1000)
+	for i := range src {
+		src[i] = map[string]interface{}{"v": float64(i)}
+		tgt[i] = map[string]interface{}{"v": float64(i + 1)}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	d := new(Differ)
+	if err := d.CompareContext(ctx, src, tgt); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if len(d.Patch()) != 0 {
+		t.Errorf("expected no operations, got %d", len(d.Patch()))
+	}
+	// The context is canceled during the comparison.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var n int
+	d = new(Differ).WithOpts(WithCostModel(func(Operation) float64 {
+		if n++; n == 10 {
+			cancel()
+		}
+		return 1
+	}), Rationalize())
+	if err := d.CompareContext(ctx, src, tgt); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if len(d.Patch()) != 0 || d.Stats().Operations() != 0 {
+		t.Errorf("expected partial results to be discarded, got %d operations", len(d.Patch()))
+	}
+	d = new(Differ)
+	if err := d.CompareContext(context.Background(), src, tgt); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Patch()) != len(src) {
+		t.Errorf("got %d operations, want %d", len(d.Patch()), len(src))
+	}
+}
+
+// cancelHasher cancels a context once it has hashed n values.
+type cancelHasher struct {
+	n, calls int
+	cancel   func()
+}
+
+func (h *cancelHasher) Digest(interface{}) uint64 {
+	if h.calls++; h.calls == h.n {
+		h.cancel()
+	}
+	return 0
+}
+
+func TestDiffer_CompareContext_factorize(t *testing.T) {
+	src := make([]interface{}, 1000)
+	tgt := make([]interface{}, 1000)
+	for i := range src {
+		src[i] = map[string]interface{}{"v": float64(i), "w": []interface{}{float64(i)}}
+		tgt[i] = map[string]interface{}{"v": float64(i), "w": []interface{}{float64(i)}, "x": true}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The context is canceled during the preparation
+	// of the factorization, which stops shortly after.
+	h := &cancelHasher{n: 10, cancel: cancel}
+	d := new(Differ).WithOpts(Factorize(), WithHasher(h))
+	if err := d.CompareContext(ctx, src, tgt); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if h.calls > h.n+4*deadlineCheckInterval {
+		t.Errorf("got %d digests after cancellation, want at most %d", h.calls-h.n, 4*deadlineCheckInterval)
+	}
+}
+
+func TestDiffer_parallel(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+
+	doc := func(n int) (map[string]interface{}, map[string]interface{}) {
+		src := make(map[string]interface{}, n)
+		tgt := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			k := fmt.Sprintf("key~/%d", i)
+			v := map[string]interface{}{
+				"id":    float64(i),
+				"tags":  []interface{}{"a", "b", "c"},
+				"ratio": rnd.Float64(),
+			}
+			switch rnd.Intn(5) {
+			case 0:
+				src[k] = v
+			case 1:
+				tgt[k] = v
+			case 2:
+				src[k] = v
+				tgt[k] = map[string]interface{}{
+					"id":    float64(i),
+					"tags":  []interface{}{"c", "a", "d"},
+					"ratio": v["ratio"],
+				}
+			default:
+				src[k], tgt[k] = v, v
+			}
+		}
+		return src, tgt
+	}
+	src, tgt := doc(1000)
+
+	// The large object is nested.
+	nsrc, ntgt := doc(500)
+	nsrc = map[string]interface{}{"a": nsrc, "b": 1.0}
+	ntgt = map[string]interface{}{"a": ntgt, "b": 2.0}
+
+	for _, opts := range [][]Option{
+		nil,
+		{Rationalize()},
+		{Invertible(), LCS()},
+		{Equivalent()},
+		{Factorize()},
+		{MaxOps(2)},
+		{CaseInsensitiveKeys()},
+		{NoRemove()},
+	} {
+		for _, docs := range [][2]map[string]interface{}{{src, tgt}, {nsrc, ntgt}} {
+			want, err := Compare(docs[0], docs[1], opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			patch, err := Compare(docs[0], docs[1], append(opts, Parallel(4))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := patch.String(), want.String(); got != want {
+				t.Errorf("got patch:\n%s\nwant:\n%s", got, want)
+			}
+		}
+	}
+	d := new(Differ).WithOpts(NoRemove())
+	d.Compare(src, tgt)
+	dropped := d.DroppedRemovals()
+
+	d = new(Differ).WithOpts(NoRemove(), Parallel(4))
+	d.Compare(src, tgt)
+	if got := d.DroppedRemovals(); len(dropped) == 0 || !reflect.DeepEqual(got, dropped) {
+		t.Errorf("got dropped removals %q, want %q", got, dropped)
+	}
+	d = new(Differ).WithOpts(DryRun())
+	d.Compare(src, tgt)
+	want := d.Stats()
+
+	d = new(Differ).WithOpts(DryRun(), Parallel(8))
+	d.Compare(src, tgt)
+	if got := d.Stats(); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+	if Equal(src, tgt, Parallel(4)) || !Equal(src, src, Parallel(4)) {
+		t.Errorf("unexpected equality result")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	d = new(Differ).WithOpts(Parallel(4))
+	if err := d.CompareContext(ctx, src, tgt); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if len(d.Patch()) != 0 {
+		t.Errorf("expected partial results to be discarded, got %d operations", len(d.Patch()))
+	}
+	tgt["key~/0"] = math.NaN()
+	if _, err := CompareWithoutMarshal(src, tgt, Parallel(4)); !errors.Is(err, ErrNonFinite) {
+		t.Errorf("got error %v, want %v", err, ErrNonFinite)
+	}
+}
+
+func TestDiffer_convergentMode(t *testing.T) {
+	src := `{"a":1,"b":{"c":"x","d":[1,2,3]},"e":[{"f":1},{"f":2}],"g":{"h":true},"i":"y"}`
+	tgt := `{"a":2,"b":{"c":"z","d":[1,2,3,4]},"e":[{"f":2}],"j":{"h":true},"i":"y"}`
+
+	// The drifted source has new array elements
+	// and misses some members changed by the patch.
+	drift := `{"b":{"d":[0,1,2,3]},"e":[{"f":0},{"f":1},{"f":2}],"g":{"h":true},"i":"y","k":0}`
+	want := `{"a":2,"b":{"c":"z","d":[1,2,3,4]},"e":[{"f":2}],"j":{"h":true},"i":"y","k":0}`
+
+	for _, opts := range [][]Option{
+		{ConvergentMode()},
+		{ConvergentMode(), Factorize(), Invertible()},
+		{ConvergentMode(), Rationalize()},
+	} {
+		patch, err := CompareJSON([]byte(src), []byte(tgt), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, op := range patch {
+			if op.Type != OperationAdd && op.Type != OperationRemove {
+				t.Errorf("unexpected %s operation", op.Type)
+			}
+			if op.Type == OperationAdd && strings.Contains(op.Path, "/e/") {
+				t.Errorf("unexpected add operation of an array element: %s", op)
+			}
+		}
+		b, err := patch.apply([]byte(drift), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got, expected interface{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(want), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("patched document mismatch:\ngot:  %s\nwant: %s\npatch: %s", b, want, patch)
+		}
+	}
+}
+
+func TestDiffer_deterministicOrder(t *testing.T) {
+	src := make(map[string]interface{})
+	tgt := make(map[string]interface{})
+	for i := 0; i < 32; i++ {
+		k := "k" + strconv.Itoa(i)
+		src[k] = map[string]interface{}{"v": []interface{}{"x"}}
+		tgt[k] = map[string]interface{}{"v": []interface{}{"x"}}
+	}
+	tgt["new"] = map[string]interface{}{"v": []interface{}{"x"}}
+
+	for _, opts := range [][]Option{
+		{Factorize(), DeterministicOrder()},
+		{Factorize(), FactorizeCache(4), DeterministicOrder()},
+	} {
+		var first string
+		for i := 0; i < 50; i++ {
+			d := new(Differ).WithOpts(opts...)
+			d.Compare(src, tgt)
+
+			s := fmt.Sprint(d.Patch())
+			if i == 0 {
+				first = s
+				continue
+			}
+			if s != first {
+				t.Fatalf("run #%d: got %s, want %s", i, s, first)
+			}
+		}
+		if want := `[{"op":"copy","from":"/k9","path":"/new"}]`; first != want {
+			t.Errorf("got %s, want %s", first, want)
+		}
+	}
+}
+
+func TestDiffer_detectArrayMoves(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]Option{
+		{DetectArrayMoves()},
+		{DetectArrayMoves(), LCS(), Factorize()},
+		{DetectArrayMoves(), Invertible()},
+	} {
+		for i := 0; i < 500; i++ {
+			src := make([]interface{}, r.Intn(10))
+			for j := range src {
+				src[j] = map[string]interface{}{"v": float64(r.Intn(4))}
+			}
+			tgt := make([]interface{}, len(src))
+			copy(tgt, src)
+			r.Shuffle(len(tgt), func(i, j int) { tgt[i], tgt[j] = tgt[j], tgt[i] })
+
+			d := new(Differ).WithOpts(opts...)
+			d.Compare(src, tgt)
+
+			for _, op := range d.Patch() {
+				if op.Type != OperationMove {
+					t.Fatalf("unexpected operation %s", op)
+				}
+			}
+			sb, err := json.Marshal(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := d.Patch().Apply(sb)
+			if err != nil {
+				t.Fatalf("failed to apply patch %s: %s", d.Patch(), err)
+			}
+			tb, err := json.Marshal(tgt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, tb) {
+				t.Errorf("patch %s applied to %s: got %s, want %s", d.Patch(), sb, b, tb)
+			}
+		}
+	}
+}
+
+func TestDiffer_matchByKey(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, opts := range [][]Option{
+		{MatchByKey("/items", "id")},
+		{MatchByKey("/items", "id"), Factorize()},
+		{MatchByKey("/items", "id"), Invertible()},
+	} {
+		for i := 0; i < 500; i++ {
+			var src, tgt []interface{}
+			for id := 0; id < 8; id++ {
+				if r.Intn(3) != 0 {
+					src = append(src, map[string]interface{}{"id": float64(id), "v": float64(r.Intn(2))})
+				}
+				if r.Intn(3) != 0 {
+					tgt = append(tgt, map[string]interface{}{"id": float64(id), "v": float64(r.Intn(2))})
+				}
+			}
+			r.Shuffle(len(src), func(i, j int) { src[i], src[j] = src[j], src[i] })
+			r.Shuffle(len(tgt), func(i, j int) { tgt[i], tgt[j] = tgt[j], tgt[i] })
+
+			sd := map[string]interface{}{"items": src}
+			td := map[string]interface{}{"items": tgt}
+
+			d := new(Differ).WithOpts(opts...)
+			d.Compare(sd, td)
+
+			sb, err := json.Marshal(sd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := d.Patch().Apply(sb)
+			if err != nil {
+				t.Fatalf("failed to apply patch %s: %s", d.Patch(), err)
+			}
+			tb, err := json.Marshal(td)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, tb) {
+				t.Errorf("patch %s applied to %s: got %s, want %s", d.Patch(), sb, b, tb)
+			}
+		}
+	}
+}
+
+func TestDiffer_epsilon(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		opts     []Option
+		src, tgt interface{}
+		equal    bool
+	}{
+		{"absolute", []Option{Epsilon(0.01)}, 1.0, 1.005, true},
+		{"absolute exceeded", []Option{Epsilon(0.01)}, 1.0, 1.02, false},
+		{"absolute large numbers", []Option{Epsilon(1e-9)}, 1e12, 1e12 + 1, false},
+		{"relative large numbers", []Option{RelativeEpsilon(1e-9)}, 1e12, 1e12 + 1, true},
+		{"relative small numbers", []Option{RelativeEpsilon(1e-9)}, 1e-12, 2e-12, false},
+		{"either tolerance", []Option{Epsilon(1e-9), RelativeEpsilon(1e-9)}, 1e-12, 2e-12, true},
+		{"json.Number", []Option{Epsilon(1e-9)}, json.Number("0.30000000000000004"), json.Number("0.3"), true},
+		{"mixed numbers", []Option{Epsilon(1e-9)}, 0.30000000000000004, json.Number("0.3"), true},
+		{"integer float strict", []Option{Epsilon(1e-9), IntegerFloatStrict()}, json.Number("1"), json.Number("1.0"), false},
+		{"booleans", []Option{Epsilon(1)}, true, false, false},
+		{
+			"equivalent arrays",
+			[]Option{Epsilon(1e-9), Equivalent()},
+			[]interface{}{0.30000000000000004, 1.0, map[string]interface{}{"a": 0.1}},
+			[]interface{}{map[string]interface{}{"a": 0.1 + 1e-12}, 1.0, 0.3},
+			true,
+		},
+		{
+			"not equivalent arrays",
+			[]Option{Epsilon(1e-9), Equivalent()},
+			[]interface{}{1.0, 2.0},
+			[]interface{}{3.0, 4.0},
+			false,
+		},
+		{
+			"reordered arrays",
+			[]Option{Epsilon(1e-9), DetectArrayMoves()},
+			[]interface{}{"a", 0.30000000000000004},
+			[]interface{}{0.3, "a"},
+			true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := new(Differ).WithOpts(tc.opts...)
+			d.Compare(tc.src, tc.tgt)
+
+			var moves bool
+			for _, op := range d.Patch() {
+				moves = moves || op.Type == OperationMove
+			}
+			if equal := len(d.Patch()) == 0 || moves; equal != tc.equal {
+				t.Errorf("got equal %t, want %t:\n%s", equal, tc.equal, d.Patch())
+			}
+		})
+	}
+}
+
+func TestDiffer_onlyPathsRationalize(t *testing.T) {
+	src := `{"status":{"phase":"Pending","a":1,"b":2}}`
+	tgt := `{"status":{"phase":"Running","a":3,"b":4}}`
+
+	patch, err := CompareJSON([]byte(src), []byte(tgt), OnlyPaths("/status/phase"), Rationalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"value":"Running","op":"replace","path":"/status/phase"}`
+	if s := patch.String(); s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+func TestDiffer_operationHandler(t *testing.T) {
+	src := `{"a":[1,2,3],"b":{"c":"x","d":true},"e":null}`
+	tgt := `{"a":[1,4],"b":{"c":"y"},"f":[{}]}`
+
+	want, err := CompareJSON([]byte(src), []byte(tgt), Invertible(), LCS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Patch
+	patch, err := CompareJSON([]byte(src), []byte(tgt), Invertible(), LCS(), WithOperationHandler(func(op Operation) error {
+		got = append(got, op)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("expected empty patch, got %d operations", len(patch))
+	}
+	if got.String() != want.String() {
+		t.Errorf("handled operations mismatch:\ngot:  %s\nwant: %s", got.String(), want.String())
+	}
+	// The comparison is aborted by the
+	// first error of the handler.
+	errStop := errors.New("stop")
+	var n int
+	_, err = CompareJSON([]byte(src), []byte(tgt), WithOperationHandler(func(Operation) error {
+		if n++; n == 2 {
+			return errStop
+		}
+		return nil
+	}))
+	if !errors.Is(err, errStop) {
+		t.Errorf("got error %v, want %v", err, errStop)
+	}
+	if n != 2 {
+		t.Errorf("got %d handled operations, want 2", n)
+	}
+	var d Differ
+	d.WithOpts(WithOperationHandler(func(Operation) error { return nil }))
+	d.Compare(map[string]interface{}{"a": 1.0}, map[string]interface{}{"b": 1.0})
+	if s := d.Stats(); s.Operations() != 2 {
+		t.Errorf("got %d operations in stats, want 2", s.Operations())
+	}
+	for _, opt := range []Option{Factorize(), Rationalize(), GroupArrayOps(), MaxPatchRatio(1), MaxOps(1)} {
+		_, err := CompareJSON([]byte(src), []byte(tgt), opt, WithOperationHandler(func(Operation) error { return nil }))
+		if !errors.Is(err, ErrHandlerOptions) {
+			t.Errorf("got error %v, want %v", err, ErrHandlerOptions)
+		}
+	}
+}
+
+func TestDiffer_renamedKeys(t *testing.T) {
+	src := `{"z":{"x":"foo"},"y":"bar"}`
+	tgt := `{"a":{"x":"foo"},"b":"bar"}`
+
+	for _, tc := range []struct {
+		opts []Option
+		want string
+	}{
+		{
+			[]Option{Factorize()},
+			`[{"op":"move","from":"/z","path":"/a"},{"op":"move","from":"/y","path":"/b"}]`,
+		},
+		{
+			[]Option{Factorize(), Invertible()},
+			`[{"value":{"x":"foo"},"op":"test","path":"/z"},{"op":"move","from":"/z","path":"/a"},` +
+				`{"value":"bar","op":"test","path":"/y"},{"op":"move","from":"/y","path":"/b"}]`,
+		},
+		{
+			[]Option{Factorize(), Ignores("/y")},
+			`[{"op":"move","from":"/z","path":"/a"},{"value":"bar","op":"add","path":"/b"}]`,
+		},
+		{
+			[]Option{Factorize(), PartialMerge()},
+			`[{"value":{"x":"foo"},"op":"add","path":"/a"},{"value":"bar","op":"add","path":"/b"}]`,
+		},
+	} {
+		patch, err := CompareJSON([]byte(src), []byte(tgt), tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("got %s, want %s", b, tc.want)
+		}
+	}
+	// The value of a removed key that is added at
+	// several keys is only moved to the first one.
+	for _, tc := range []struct {
+		src, tgt string
+	}{
+		{`{"z":1}`, `{"a":1,"b":1}`},
+		{`{"z":{"x":"foo"},"y":1}`, `{"a":{"x":"foo"},"b":{"x":"foo"},"c":{"x":"foo"}}`},
+		{`{"y":1,"z":1}`, `{"a":1,"b":1,"c":1}`},
+	} {
+		for _, opts := range [][]Option{
+			{Factorize()},
+			{Factorize(), Invertible()},
+		} {
+			patch, err := CompareJSON([]byte(tc.src), []byte(tc.tgt), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := patch.Apply([]byte(tc.src))
+			if err != nil {
+				t.Fatalf("failed to apply patch %s: %s", patch, err)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.tgt), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("patch %s applied to %s: got %s, want %s", patch, tc.src, b, tc.tgt)
+			}
+		}
+	}
+}
+
+func TestDiffer_nullEqualsMissing(t *testing.T) {
+	src := `{"a":[{"x":1,"y":null},{"z":2}],"b":null}`
+	tgt := `{"a":[{"z":2,"w":null},{"x":1}],"c":null}`
+
+	ok, err := EqualJSON([]byte(src), []byte(tgt), Equivalent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected documents to differ without NullEqualsMissing")
+	}
+	if ok, err = EqualJSON([]byte(src), []byte(tgt), NullEqualsMissing(), Equivalent()); err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("expected documents to be equivalent")
+	}
+	moves, err := CompareJSON([]byte(src), []byte(tgt), NullEqualsMissing(), DetectArrayMoves())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"op":"move","from":"/a/1","path":"/a/0"}`; moves.String() != want {
+		t.Errorf("got %s, want %s", moves.String(), want)
+	}
+	patch, err := CompareJSON([]byte(`{"a":[{"x":1,"y":null}]}`), []byte(`{"a":[{},{"x":1}]}`), NullEqualsMissing(), LCS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"value":{},"op":"add","path":"/a/0"}`
+	if s := patch.String(); s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+	// The StrictPresence option takes precedence.
+	patch, err = CompareJSON([]byte(`{"a":null}`), []byte(`{}`), NullEqualsMissing(), StrictPresence())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"op":"remove","path":"/a"}`; patch.String() != want {
+		t.Errorf("got %s, want %s", patch.String(), want)
+	}
+}
+
+func TestDiffer_ambiguousKeys(t *testing.T) {
+	src := map[string]interface{}{
+		"foo": 1.0,
+		"Foo": 2.0,
+		"a": map[string]interface{}{
+			"Bar": 1.0,
+			"X":   1.0,
+		},
+	}
+	tgt := map[string]interface{}{
+		"FOO": 2.0,
+		"a": map[string]interface{}{
+			"bar": 1.0,
+			"BAR": 1.0,
+			"x":   2.0,
+		},
+	}
+	d := new(Differ).WithOpts(CaseInsensitiveKeys())
+	d.Compare(src, tgt)
+
+	want := []string{"/FOO", "/Foo", "/foo", "/a/BAR", "/a/Bar", "/a/bar"}
+	if got := d.AmbiguousKeys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got ambiguous keys %q, want %q", got, want)
+	}
+	patch := d.Patch()
+	var found bool
+	for _, op := range patch {
+		if op.Path == "/a/x" && op.Type == OperationReplace {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected replace operation of /a/x, got:\n%s", patch.String())
+	}
+	d.Reset()
+	if len(d.AmbiguousKeys()) != 0 {
+		t.Error("expected ambiguous keys to be reset")
+	}
+}
+
+func TestDiffer_normalizedKeysMatching(t *testing.T) {
+	ignore, fold := IgnoreKeysAnywhere("ts"), CaseInsensitiveKeys()
+
+	for _, tc := range []struct {
+		src, tgt string
+		opts     []Option
+		want     string
+	}{
+		{`[{"a":1,"ts":1},2]`, `[2,{"a":1,"ts":2}]`, []Option{ignore, Equivalent()}, ``},
+		{`[{"a":1,"ts":1},2]`, `[2,{"a":1,"ts":2}]`, []Option{ignore, SetPaths("")}, ``},
+		{`[{"a":1,"ts":1},2]`, `[2,{"a":1,"ts":2}]`, []Option{ignore, DetectArrayMoves()}, `{"op":"move","from":"/1","path":"/0"}`},
+		{`[{"a":1,"ts":1},2,3]`, `[0,{"a":1,"ts":2},2,3]`, []Option{ignore, LCS()}, `{"value":0,"op":"add","path":"/0"}`},
+		{`[{"A":1},2]`, `[2,{"a":1}]`, []Option{fold, Equivalent()}, `{"op":"move","from":"/0/A","path":"/0/a"}`},
+		{`[{"A":1},2]`, `[2,{"a":1}]`, []Option{fold, SetPaths("")}, `{"op":"move","from":"/0/A","path":"/0/a"}`},
+		{`[{"A":1},2]`, `[2,{"a":1}]`, []Option{fold, DetectArrayMoves()}, `{"op":"move","from":"/1","path":"/0"}
+{"op":"move","from":"/1/A","path":"/1/a"}`},
+		{`[{"A":1},2,3]`, `[0,{"a":1},2,3]`, []Option{fold, LCS()}, `{"value":0,"op":"add","path":"/0"}
+{"op":"move","from":"/1/A","path":"/1/a"}`},
+	} {
+		patch, err := CompareJSON([]byte(tc.src), []byte(tc.tgt), tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := patch.String(); s != tc.want {
+			t.Errorf("%s -> %s: got patch:\n%s\nwant:\n%s", tc.src, tc.tgt, s, tc.want)
+		}
+	}
+}
+
+func TestDiffer_caseInsensitiveKeysCopy(t *testing.T) {
+	src := map[string]interface{}{"UserName": "a"}
+	tgt := map[string]interface{}{"username": "b"}
+
+	for _, opt := range []Option{NoRemove(), PartialMerge()} {
+		patch, err := Compare(src, tgt, CaseInsensitiveKeys(), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"op":"copy","from":"/UserName","path":"/username"}
+{"value":"b","op":"replace","path":"/username"}`
+		if s := patch.String(); s != want {
+			t.Errorf("got patch:\n%s\nwant:\n%s", s, want)
+		}
+	}
+}
+
+func TestDiffer_DroppedRemovals(t *testing.T) {
+	src := map[string]interface{}{
+		"a/b": 1.0,
+		"c":   []interface{}{1.0, 2.0, 3.0, 4.0},
+		"d":   map[string]interface{}{"e": true, "f": false},
+	}
+	tgt := map[string]interface{}{
+		"c": []interface{}{2.0, 3.0},
+		"d": map[string]interface{}{"f": true},
+	}
+	for _, opts := range [][]Option{
+		{NoRemove()},
+		{NoRemove(), LCS(), Factorize(), Invertible()},
+		{NoRemove(), DetectArrayMoves()},
+	} {
+		d := new(Differ).WithOpts(opts...)
+		d.Compare(src, tgt)
+
+		for _, op := range d.Patch() {
+			if op.Type == OperationRemove || op.Type == OperationMove {
+				t.Errorf("unexpected operation %s", op)
+			}
+		}
+		want := []string{"/a~1b", "/c/2", "/c/3", "/d/e"}
+		if got := d.DroppedRemovals(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got dropped removals %q, want %q", got, want)
+		}
+		d.Reset()
+		if len(d.DroppedRemovals()) != 0 {
+			t.Error("expected dropped removals to be reset")
+		}
+	}
+}
+
+func TestDiffer_CompareAt(t *testing.T) {
+	src := map[string]interface{}{
+		"a": []interface{}{1.0, 2.0, map[string]interface{}{"b": "some long value"}},
+		"c": map[string]interface{}{"d": "some long value", "e": 1.0},
+	}
+	tgt := map[string]interface{}{
+		"a": []interface{}{2.0, map[string]interface{}{"b": "some long value"}},
+		"c": map[string]interface{}{"f": "some long value", "e": 2.0},
+		"g": map[string]interface{}{"b": "some long value"},
+	}
+	const base = "/spec/items~1x/0"
+
+	for _, opts := range [][]Option{
+		nil,
+		{Factorize()},
+		{Factorize(), Rationalize(), Invertible()},
+		{LCS()},
+		{WithResultChecksum()},
+		{MaxPatchRatio(0.1)},
+	} {
+		want, err := Compare(src, tgt, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := new(Differ).WithOpts(opts...)
+		patch, err := d.CompareAtWithError(base, src, tgt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(patch) != len(want) {
+			t.Fatalf("got %d operations, want %d", len(patch), len(want))
+		}
+		for i, op := range patch {
+			w := want[i]
+			if w.Type == OperationAdd && w.Path == emptyPointer {
+				// The value at base must exist.
+				w.Type = OperationReplace
+			}
+			w.Path = base + w.Path
+			if w.hasFrom() {
+				w.From = base + w.From
+			}
+			if op.Type != w.Type || op.Path != w.Path || op.From != w.From {
+				t.Errorf("op #%d mismatch: got %s, want %s", i, op, w)
+			}
+		}
+	}
+	// The patch applies to the larger document.
+	doc := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"items/x": []interface{}{src, 1.0},
+		},
+	}
+	d := new(Differ).WithOpts(Factorize(), LCS())
+	d.CompareAt(base, src, tgt)
+
+	sb, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.Patch().apply(sb, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc["spec"].(map[string]interface{})["items/x"].([]interface{})[0] = tgt
+
+	want, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unmarshalMarshal(t, b); !bytes.Equal(got, want) {
+		t.Errorf("got document %s, want %s", got, want)
+	}
+	// The pointer is reset for the next comparisons.
+	d.Reset()
+	d.Compare(src, tgt)
+	for _, op := range d.Patch() {
+		if strings.HasPrefix(op.Path, base) {
+			t.Errorf("unexpected base of operation %s", op)
+		}
+	}
+	d.Reset()
+	d.CompareAt("", 1.0, "x")
+	if p := d.Patch(); len(p) != 1 || p[0].Type != OperationAdd || p[0].Path != "" {
+		t.Errorf("got patch %s, want root add operation", &p)
+	}
+	if _, err := d.CompareAtWithError("a/b", src, tgt); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+func TestDiffer_UnusedIgnores(t *testing.T) {
+	src := map[string]interface{}{
+		"a": map[string]interface{}{"secret": "x", "b": 1.0},
+		"c": []interface{}{map[string]interface{}{"at": 1.0}},
+		"d": 1.0,
+	}
+	tgt := map[string]interface{}{
+		"a": map[string]interface{}{"secret": "y", "b": 2.0},
+		"c": []interface{}{map[string]interface{}{"at": 2.0}},
+		"d": 2.0,
+	}
+	d := new(Differ).WithOpts(
+		TrackIgnores(),
+		Ignores("/a/secret", "/a/secert", "/c/*/at", "1/a"),
+		OnlyPaths("/a", "/c", "/e"),
+	)
+	d.Compare(src, tgt)
+
+	want := []string{"/a/secert", "1/a", "/e"}
+	if got := d.UnusedIgnores(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got unused ignores %q, want %q", got, want)
+	}
+	if p := d.Patch(); len(p) != 1 || p[0].Path != "/a/b" {
+		t.Errorf("got patch %s", &p)
+	}
+	d.Reset()
+	d.Compare(src, src)
+
+	want = []string{"/a/secret", "/a/secert", "/c/*/at", "1/a", "/a", "/c", "/e"}
+	if got := d.UnusedIgnores(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got unused ignores %q after reset, want %q", got, want)
+	}
+	if got := new(Differ).WithOpts(Ignores("/x")).UnusedIgnores(); got != nil {
+		t.Errorf("expected no unused ignores without tracking, got %q", got)
+	}
+	// The rules matched by the workers of
+	// a parallel comparison are merged.
+	ps := make(map[string]interface{}, 2*parallelThreshold)
+	pt := make(map[string]interface{}, 2*parallelThreshold)
+	for i := 0; i < 2*parallelThreshold; i++ {
+		k := strconv.Itoa(i)
+		ps[k] = map[string]interface{}{"v": 1.0}
+		pt[k] = map[string]interface{}{"v": 2.0}
+	}
+	d = new(Differ).WithOpts(TrackIgnores(), Parallel(4), Ignores("/200/v", "/**/w"))
+	d.Compare(ps, pt)
+
+	if got := d.UnusedIgnores(); !reflect.DeepEqual(got, []string{"/**/w"}) {
+		t.Errorf("got unused ignores %q, want [/**/w]", got)
+	}
+}
+
+func TestDiffer_metadataOnly(t *testing.T) {
+	src := `{"a":[1,2,3],"b":{"c":"some long value","d":1},"e":[{"f":1},{"f":2}]}`
+	tgt := `{"a":[1,3],"b":{"d":2},"e":[{"f":2}],"g":"some long value"}`
+
+	for _, opts := range [][]Option{
+		nil,
+		{Invertible()},
+		{LCS(), Invertible(), CoalesceTests()},
+		{Factorize(), Rationalize()},
+		{MaxPatchRatio(0.01)},
+	} {
+		patch, err := CompareJSON([]byte(src), []byte(tgt), append(opts, MetadataOnly())...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The operations are those of a comparison
+		// without the options that require values.
+		var filtered []Option
+		for _, o := range opts {
+			var d Differ
+			o(&d)
+			if !d.opts.factorize && !d.opts.rationalize && !d.opts.coalesce && d.opts.maxRatio == 0 {
+				filtered = append(filtered, o)
+			}
+		}
+		want, err := CompareJSON([]byte(src), []byte(tgt), filtered...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(patch) != len(want) {
+			t.Fatalf("got %d operations, want %d", len(patch), len(want))
+		}
+		for i, op := range patch {
+			if op.Value != nil || op.OldValue != nil {
+				t.Errorf("op #%d has a value: %s", i, op)
+			}
+			if w := want[i]; op.Type != w.Type || op.Path != w.Path || op.From != w.From {
+				t.Errorf("op #%d mismatch: got %s, want %s", i, op, w)
+			}
+		}
+		ps, ws := patch.Stats(), want.Stats()
+		if ps.Operations() != ws.Operations() || ps.Adds != ws.Adds || ps.Removes != ws.Removes || ps.Replaces != ws.Replaces || ps.Tests != ws.Tests {
+			t.Errorf("got stats %+v, want %+v", ps, ws)
+		}
+	}
+}
+
+func TestDiffer_equalDocuments(t *testing.T) {
+	src := map[string]interface{}{
+		"a": []interface{}{1.0, "b", map[string]interface{}{"c": true}},
+		"d": map[string]interface{}{"e": nil},
+	}
+	var cpy interface{}
+	if err := json.Unmarshal([]byte(`{"a":[1,"b",{"c":true}],"d":{"e":null}}`), &cpy); err != nil {
+		t.Fatal(err)
+	}
+	for _, tgt := range []interface{}{src, cpy} {
+		d := new(Differ).WithOpts(Factorize(), WithResultChecksum())
+		d.Compare(src, tgt)
+
+		if d.hashmap != nil {
+			t.Errorf("got %d indexed values, want none", len(d.hashmap))
+		}
+		patch := d.Patch()
+		if len(patch) != 1 || patch[0].Type != OperationChecksum {
+			t.Errorf("got patch %s, want a single checksum", patch)
+		}
+	}
+	// The documents that share a subtree are compared
+	// like the documents that don't.
+	tgt := map[string]interface{}{
+		"a": src["a"],
+		"d": map[string]interface{}{"e": false},
+	}
+	want, err := CompareJSON([]byte(`{"a":[1,"b",{"c":true}],"d":{"e":null}}`), []byte(`{"a":[1,"b",{"c":true}],"d":{"e":false}}`), Factorize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := Compare(src, tgt, Factorize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch.String() != want.String() {
+		t.Errorf("got patch %s, want %s", patch, want)
+	}
+}
+
+func TestDiffer_CompareBoth(t *testing.T) {
+	src := `{"a":{"b":"some value","c":[1,2,3]},"d":[{"e":1},{"e":2}],"f":null}`
+	tgt := `{"a":{"b":"some value","c":[1,3]},"d":[{"e":2}],"g":{"b":"some value","c":[1,2,3]},"h":"some value"}`
+
+	var before, after interface{}
+	if err := json.Unmarshal([]byte(src), &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(tgt), &after); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{Factorize()},
+		{Factorize(), FactorizeCache(8)},
+		{Factorize(), Rationalize(), Invertible()},
+		{LCS(), Invertible()},
+		{Factorize(), PruneTargetNulls()},
+	} {
+		d := new(Differ).WithOpts(opts...)
+		forward, reverse, err := d.CompareBoth(before, after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantForward, err := Compare(before, after, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantReverse, err := Compare(after, before, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reverse.String() != wantReverse.String() {
+			t.Errorf("got reverse patch %s, want %s", reverse, wantReverse)
+		}
+		// Unlike the reverse patch, the forward patch
+		// isn't overwritten by the next comparison.
+		d.Reset()
+		d.Compare(before, after)
+
+		if forward.String() != wantForward.String() {
+			t.Errorf("got forward patch %s, want %s", forward, wantForward)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package jsondiff
+
+import "strconv"
+
+// elideValues replaces the values and old values of the
+// operations of the patch whose JSON representation exceeds
+// the size of the MaxValueBytes option by their placeholder.
+func (d *Differ) elideValues() {
+	for i := range d.patch {
+		op := &d.patch[i]
+		if op.Type == OperationChecksum {
+			continue
+		}
+		if v, ok := d.elide(op.Value); ok {
+			op.Value = v
+			op.valueLen = valueLength(v)
+		}
+		if v, ok := d.elide(op.OldValue); ok {
+			op.OldValue = v
+		}
+		if d.err != nil {
+			return
+		}
+	}
+}
+
+// elide returns the placeholder of the value, and whether
+// its JSON representation exceeds the maximum size.
+func (d *Differ) elide(v interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	n := valueLength(v)
+	if n <= d.opts.maxValue {
+		return nil, false
+	}
+	s := "<elided " + formatSize(n)
+	if d.opts.elisionSums {
+		sum, err := checksum(v)
+		if err != nil {
+			d.err = err
+			return nil, false
+		}
+		s += " " + sum
+	}
+	return s + ">", true
+}
+
+// formatSize returns the size of n bytes in the
+// largest unit in which it is at least one.
+func formatSize(n int) string {
+	switch {
+	case n < 1<<10:
+		return strconv.Itoa(n) + "B"
+	case n < 1<<20:
+		return strconv.Itoa(n>>10) + "KB"
+	default:
+		return strconv.Itoa(n>>20) + "MB"
+	}
+}
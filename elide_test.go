@@ -0,0 +1,58 @@
+package jsondiff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_formatSize(t *testing.T) {
+	for _, tc := range []struct {
+		n    int
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1KB"},
+		{43520, "42KB"},
+		{1<<20 - 1, "1023KB"},
+		{3 << 20, "3MB"},
+	} {
+		if got := formatSize(tc.n); got != tc.want {
+			t.Errorf("formatSize(%d): got %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestDiffer_elidedValueChecksums(t *testing.T) {
+	src := map[string]interface{}{"blob": strings.Repeat("a", 2048), "n": 1.0}
+	tgt := map[string]interface{}{"blob": strings.Repeat("b", 2048), "n": 2.0}
+
+	patch, err := Compare(src, tgt, Invertible(), MaxValueBytes(64), ElidedValueChecksums())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 4 {
+		t.Fatalf("got %d operations, want 4: %s", len(patch), patch)
+	}
+	srcSum, _ := Checksum(src["blob"])
+	tgtSum, _ := Checksum(tgt["blob"])
+
+	for i, want := range []interface{}{
+		"<elided 2KB " + srcSum + ">",
+		"<elided 2KB " + tgtSum + ">",
+		1.0,
+		2.0,
+	} {
+		if patch[i].Value != want {
+			t.Errorf("op #%d: got value %v, want %v", i, patch[i].Value, want)
+		}
+	}
+	if want := "<elided 2KB " + srcSum + ">"; patch[1].OldValue != want {
+		t.Errorf("got old value %v, want %v", patch[1].OldValue, want)
+	}
+	_, err = Compare(src, tgt, MaxValueBytes(64), WithOperationHandler(func(Operation) error { return nil }))
+	if !errors.Is(err, ErrHandlerOptions) {
+		t.Errorf("got error %v, want %v", err, ErrHandlerOptions)
+	}
+}
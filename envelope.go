@@ -0,0 +1,191 @@
+package jsondiff
+
+import (
+	"errors"
+	"fmt"
+)
+
+// minDedupLength is the minimum length in bytes of the
+// JSON representation of a value for it to be stored in
+// the values table of an envelope.
+const minDedupLength = 32
+
+// A PatchEnvelope wraps a patch with additional data
+// computed by the Differ. The envelope format is not
+// part of RFC 6902 and must be handled by a cooperating
+// consumer.
+//
+// When the DeduplicateValues option is enabled, the values
+// that are repeated in several operations are stored once
+// in the Values table, and the operations reference them
+// with a ValueRef, which is represented in JSON as an object
+// with a single "$ref" member holding the index of the value
+// in the table. The original patch can be reconstructed with
+// the Rehydrate method. Whatever the options, the values of the
+// operations that are themselves objects with a single "$ref"
+// member holding a number are stored in the table, such that
+// they are not mistaken for a reference.
+//
+// When the WithStateHashes option is enabled, PreHash and
+// PostHash hold the checksums of the source and target
+// documents (see Checksum), such that a chain of patches can
+// be verified, by checking with the VerifyPreState method that
+// the current state matches the pre-state of a patch before
+// applying it, and optionally with VerifyPostState that the
+// result matches its post-state.
+type PatchEnvelope struct {
+	PreHash  string        `json:"preHash,omitempty"`
+	PostHash string        `json:"postHash,omitempty"`
+	Values   []interface{} `json:"values,omitempty"`
+	Patch    Patch         `json:"patch"`
+}
+
+// ErrStateMismatch is the error returned when the checksum
+// of a document does not match the state hash of an envelope.
+var ErrStateMismatch = errors.New("jsondiff: state hash mismatch")
+
+// ValueRef is a reference to the value stored at
+// Index in the values table of a PatchEnvelope.
+type ValueRef struct {
+	Index int `json:"$ref"`
+}
+
+// Envelope returns the list of JSON patch operations
+// generated by the Differ instance wrapped in an envelope.
+// Unlike Patch, the operations of the envelope are a copy.
+func (d *Differ) Envelope() PatchEnvelope {
+	e := PatchEnvelope{
+		PreHash:  d.preHash,
+		PostHash: d.postHash,
+		Patch:    make(Patch, len(d.patch)),
+	}
+	copy(e.Patch, d.patch)
+
+	if d.opts.dedup {
+		d.deduplicate(&e)
+	}
+	for i, op := range e.Patch {
+		if op.marshalWithValue() && isValueRef(op.Value) {
+			e.Patch[i].Value = ValueRef{Index: len(e.Values)}
+			e.Values = append(e.Values, op.Value)
+		}
+	}
+	return e
+}
+
+// isValueRef returns whether the value has the JSON
+// representation of a value reference.
+func isValueRef(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return false
+	}
+	ref, ok := m["$ref"]
+
+	return ok && isNumberType(jsonTypeSwitch(ref))
+}
+
+// VerifyPreState verifies that the checksum of the given
+// document, to which the patch is about to be applied,
+// matches the pre-state hash of the envelope. It returns an
+// error that wraps ErrStateMismatch if it doesn't, or if the
+// envelope has no state hashes.
+func (e PatchEnvelope) VerifyPreState(doc interface{}) error {
+	return verifyState(e.PreHash, doc)
+}
+
+// VerifyPostState is similar to VerifyPreState, but it
+// verifies that the checksum of the document that results
+// from the application of the patch matches the post-state
+// hash of the envelope.
+func (e PatchEnvelope) VerifyPostState(doc interface{}) error {
+	return verifyState(e.PostHash, doc)
+}
+
+func verifyState(hash string, doc interface{}) error {
+	sum, err := Checksum(doc)
+	if err != nil {
+		return err
+	}
+	if sum != hash {
+		return fmt.Errorf("%w: got %s, want %q", ErrStateMismatch, sum, hash)
+	}
+	return nil
+}
+
+// deduplicate moves the large values that are repeated
+// in the operations of the envelope to its values table.
+func (d *Differ) deduplicate(e *PatchEnvelope) {
+	type class struct {
+		val interface{}
+		ops []int
+	}
+	var (
+		classes []class
+		hashes  = make(map[uint64][]int) // hash -> indices of classes
+	)
+	for i, op := range e.Patch {
+		if !op.marshalWithValue() || valueLength(op.Value) < minDedupLength {
+			continue
+		}
+		k := d.hasher.digest(op.Value)
+		c := -1
+		for _, idx := range hashes[k] {
+			// Confirm the equality of the values
+			// to protect against hash collisions.
+			if deepEqual(classes[idx].val, op.Value) {
+				c = idx
+				break
+			}
+		}
+		if c == -1 {
+			c = len(classes)
+			classes = append(classes, class{val: op.Value})
+			hashes[k] = append(hashes[k], c)
+		}
+		classes[c].ops = append(classes[c].ops, i)
+	}
+	for _, c := range classes {
+		if len(c.ops) < 2 {
+			continue
+		}
+		ref := ValueRef{Index: len(e.Values)}
+		e.Values = append(e.Values, c.val)
+
+		for _, i := range c.ops {
+			e.Patch[i].Value = ref
+		}
+	}
+}
+
+// Rehydrate returns a copy of the patch of the envelope
+// where the value references are replaced by the values
+// of the table they point to.
+func (e PatchEnvelope) Rehydrate() (Patch, error) {
+	p := make(Patch, len(e.Patch))
+	copy(p, e.Patch)
+
+	for i, op := range p {
+		var (
+			idx int
+			ok  bool
+		)
+		switch ref := op.Value.(type) {
+		case ValueRef:
+			idx, ok = ref.Index, true
+		case map[string]interface{}:
+			// Value reference decoded from JSON.
+			if f, isNum := ref["$ref"].(float64); isNum && len(ref) == 1 {
+				idx, ok = int(f), true
+			}
+		}
+		if !ok {
+			continue
+		}
+		if idx < 0 || idx >= len(e.Values) {
+			return nil, fmt.Errorf("jsondiff: op #%d: invalid value reference %d", i, idx)
+		}
+		p[i].Value = e.Values[idx]
+	}
+	return p, nil
+}
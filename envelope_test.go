@@ -0,0 +1,202 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiffer_Envelope_deduplicateValues(t *testing.T) {
+	def := map[string]interface{}{
+		"enabled": true,
+		"labels":  []interface{}{"default", "generated"},
+	}
+	src := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"id": "a"},
+			map[string]interface{}{"id": "b"},
+			map[string]interface{}{"id": "c"},
+		},
+	}
+	tgt := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"id": "a", "config": def},
+			map[string]interface{}{"id": "b", "config": def},
+			map[string]interface{}{"id": "c", "config": "small"},
+		},
+		"other": def,
+	}
+	d := (&Differ{}).WithOpts(DeduplicateValues())
+	d.Compare(src, tgt)
+
+	e := d.Envelope()
+	if len(e.Values) != 1 {
+		t.Fatalf("got %d values, want 1", len(e.Values))
+	}
+	if !reflect.DeepEqual(e.Values[0], def) {
+		t.Errorf("unexpected value: %v", e.Values[0])
+	}
+	refs := 0
+	for _, op := range e.Patch {
+		if _, ok := op.Value.(ValueRef); ok {
+			refs++
+		}
+	}
+	if refs != 3 {
+		t.Errorf("got %d value references, want 3", refs)
+	}
+	// The patch of the Differ must be left untouched.
+	for _, op := range d.Patch() {
+		if _, ok := op.Value.(ValueRef); ok {
+			t.Errorf("differ patch contains value reference")
+		}
+	}
+	// Round-trip the envelope through JSON and
+	// verify that the consumer can rehydrate it.
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Values []interface{} `json:"values"`
+		Patch  []struct {
+			Op    string      `json:"op"`
+			Path  string      `json:"path"`
+			Value interface{} `json:"value"`
+		} `json:"patch"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	de := PatchEnvelope{Values: decoded.Values}
+	for _, op := range decoded.Patch {
+		de.Patch = append(de.Patch, Operation{Type: op.Op, Path: op.Path, Value: op.Value})
+	}
+	p, err := de.Rehydrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := d.Patch(); len(p) != len(want) {
+		t.Fatalf("got %d operations, want %d", len(p), len(want))
+	}
+	for i, op := range p {
+		if !reflect.DeepEqual(op.Value, d.Patch()[i].Value) {
+			t.Errorf("op #%d: value mismatch", i)
+		}
+	}
+}
+
+func TestDiffer_Envelope_noDeduplication(t *testing.T) {
+	def := map[string]interface{}{"a": "a long enough string value to be deduplicated"}
+
+	d := &Differ{}
+	d.Compare(map[string]interface{}{}, map[string]interface{}{"a": def, "b": def})
+
+	e := d.Envelope()
+	if len(e.Values) != 0 {
+		t.Errorf("expected empty values table")
+	}
+	if !reflect.DeepEqual(e.Patch, d.Patch()) {
+		t.Errorf("envelope patch mismatch")
+	}
+}
+
+func TestPatchEnvelope_Rehydrate_literalRef(t *testing.T) {
+	ref := map[string]interface{}{"$ref": float64(0)}
+	src := map[string]interface{}{}
+	tgt := map[string]interface{}{"a": ref, "b": map[string]interface{}{"$ref": "#/a"}}
+
+	for _, opts := range [][]Option{nil, {DeduplicateValues()}} {
+		d := (&Differ{}).WithOpts(opts...)
+		d.Compare(src, tgt)
+
+		b, err := json.Marshal(d.Envelope())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e PatchEnvelope
+		if err := json.Unmarshal(b, &e); err != nil {
+			t.Fatal(err)
+		}
+		p, err := e.Rehydrate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := d.Patch(); p.String() != want.String() {
+			t.Errorf("got patch %s, want %s", p, want)
+		}
+	}
+}
+
+func TestPatchEnvelope_Rehydrate_invalidRef(t *testing.T) {
+	e := PatchEnvelope{
+		Patch: Patch{{Type: OperationAdd, Path: "/a", Value: ValueRef{Index: 1}}},
+	}
+	if _, err := e.Rehydrate(); err == nil {
+		t.Errorf("expected non-nil error")
+	}
+}
+
+func TestDiffer_Envelope_stateHashes(t *testing.T) {
+	states := []string{
+		`{"count":0}`,
+		`{"count":1,"items":["a"]}`,
+		`{"count":2,"items":["a","b"]}`,
+	}
+	var events []PatchEnvelope
+
+	d := (&Differ{}).WithOpts(WithStateHashes())
+	for i := 1; i < len(states); i++ {
+		var src, tgt interface{}
+		if err := json.Unmarshal([]byte(states[i-1]), &src); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(states[i]), &tgt); err != nil {
+			t.Fatal(err)
+		}
+		d.Reset()
+		d.Compare(src, tgt)
+		events = append(events, d.Envelope())
+	}
+	// Replay the chain of events from the initial state.
+	state := []byte(states[0])
+	for i, e := range events {
+		if e.PreHash == "" || e.PostHash == "" {
+			t.Fatalf("event #%d: missing state hashes", i)
+		}
+		if err := e.VerifyPreState(json.RawMessage(state)); err != nil {
+			t.Fatalf("event #%d: %s", i, err)
+		}
+		b, err := e.Patch.apply(state, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.VerifyPostState(json.RawMessage(b)); err != nil {
+			t.Fatalf("event #%d: %s", i, err)
+		}
+		state = b
+	}
+	// An event cannot be applied out of order.
+	err := events[1].VerifyPreState(json.RawMessage(states[0]))
+	if !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("expected error to match ErrStateMismatch, got %v", err)
+	}
+	// Envelopes without state hashes never match.
+	err = PatchEnvelope{}.VerifyPreState(json.RawMessage(states[0]))
+	if !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("expected error to match ErrStateMismatch, got %v", err)
+	}
+	b, err := json.Marshal(events[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["preHash"] != events[0].PreHash || m["postHash"] != events[0].PostHash {
+		t.Errorf("unexpected envelope JSON: %s", b)
+	}
+}
@@ -2,6 +2,8 @@ package jsondiff
 
 import (
 	"encoding/json"
+	"math/big"
+	"reflect"
 	"strconv"
 )
 
@@ -21,6 +23,7 @@ const (
 	jsonBoolean
 	jsonNumberFloat
 	jsonNumberString
+	jsonNumberBig
 	jsonArray
 	jsonObject
 )
@@ -28,7 +31,7 @@ const (
 // jsonTypeSwitch returns the JSON type of the value
 // held by the interface using a type switch statement.
 func jsonTypeSwitch(i interface{}) jsonValueType {
-	switch i.(type) {
+	switch v := i.(type) {
 	case nil:
 		return jsonNull
 	case string:
@@ -39,6 +42,16 @@ func jsonTypeSwitch(i interface{}) jsonValueType {
 		return jsonNumberFloat
 	case json.Number:
 		return jsonNumberString
+	case *big.Int:
+		if v == nil {
+			return jsonNull
+		}
+		return jsonNumberBig
+	case *big.Float:
+		if v == nil {
+			return jsonNull
+		}
+		return jsonNumberBig
 	case []interface{}:
 		return jsonArray
 	case map[string]interface{}:
@@ -50,9 +63,42 @@ func jsonTypeSwitch(i interface{}) jsonValueType {
 
 // areComparable returns whether the interface values
 // i1 and i2 can be compared. The values are comparable
-// only if they are both non-nil and share the same kind.
+// only if they are both non-nil and share the same kind,
+// the numbers decoded as float64 and json.Number values
+// being of the same kind.
 func areComparable(i1, i2 interface{}) bool {
-	return jsonTypeSwitch(i1) == jsonTypeSwitch(i2)
+	t1, t2 := jsonTypeSwitch(i1), jsonTypeSwitch(i2)
+	return t1 == t2 || isNumberType(t1) && isNumberType(t2)
+}
+
+func isNumberType(t jsonValueType) bool {
+	return t == jsonNumberFloat || t == jsonNumberString || t == jsonNumberBig
+}
+
+// isContainer returns whether the value is a JSON
+// array or object.
+func isContainer(i interface{}) bool {
+	switch i.(type) {
+	case []interface{}, map[string]interface{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// containerSize returns the number of elements or keys
+// of a JSON array or object, and nil for other values.
+func containerSize(i interface{}) *int {
+	var n int
+	switch v := i.(type) {
+	case []interface{}:
+		n = len(v)
+	case map[string]interface{}:
+		n = len(v)
+	default:
+		return nil
+	}
+	return &n
 }
 
 func deepEqual(src, tgt interface{}) bool {
@@ -63,6 +109,118 @@ func deepEqual(src, tgt interface{}) bool {
 	return deepEqualValue(src, tgt)
 }
 
+// normalizes returns whether one of the options that
+// normalize the values before their comparison, or that
+// compare them with a custom function, is enabled.
+func (d *Differ) normalizes() bool {
+	return d.opts.scalars != nil || d.opts.numbers || d.tolerates() || d.opts.nullMissing || d.opts.equalFuncs != nil ||
+		d.opts.ignoreKeys != nil || d.opts.foldKeys
+}
+
+// equal returns whether the values located at the given
+// pointer are deeply equal once their scalars have been
+// normalized by the active options, such that the values
+// whose comparison does not produce any operation are
+// equal. Without normalization, it is equivalent to
+// deepEqual.
+func (d *Differ) equal(ptr string, src, tgt interface{}) bool {
+	return d.equalFold(ptr, src, tgt, false)
+}
+
+// matches is similar to equal, but the keys of the objects
+// that differ only by case are also paired with the option
+// CaseInsensitiveKeys, to match the elements of two arrays,
+// whose comparison then renames the keys.
+func (d *Differ) matches(ptr string, src, tgt interface{}) bool {
+	return d.equalFold(ptr, src, tgt, d.opts.foldKeys)
+}
+
+// equalFold implements equal and matches, the keys being
+// paired case-insensitively if fold is true.
+func (d *Differ) equalFold(ptr string, src, tgt interface{}, fold bool) bool {
+	if deepEqual(src, tgt) {
+		return true
+	}
+	if !d.normalizes() {
+		return false
+	}
+	if d.opts.equalFuncs != nil && d.customEqual(ptr, src, tgt) {
+		return true
+	}
+	switch sv := src.(type) {
+	case map[string]interface{}:
+		tv, ok := tgt.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		return d.equalObjects(ptr, sv, tv, fold)
+	case []interface{}:
+		tv, ok := tgt.([]interface{})
+		if !ok || len(sv) != len(tv) {
+			return false
+		}
+		for i, v := range sv {
+			if !d.equalFold(ptr+string(separator)+strconv.Itoa(i), v, tv[i], fold) {
+				return false
+			}
+		}
+		return true
+	case string:
+		return d.opts.scalars != nil && d.equalScalars(ptr, src, tgt)
+	case json.Number, float64:
+		return d.opts.numbers && d.equalNumbers(src, tgt) || d.tolerates() && d.nearlyEqual(src, tgt)
+	default:
+		return false
+	}
+}
+
+// equalObjects is similar to equal for two objects, but the
+// keys whose value is null are equal to the missing keys with
+// the NullEqualsMissing option, and the keys of the option
+// IgnoreKeysAnywhere are not compared. With fold, the keys
+// are paired like with the CaseInsensitiveKeys option.
+func (d *Differ) equalObjects(ptr string, src, tgt map[string]interface{}, fold bool) bool {
+	var aliases map[string]string
+	if fold {
+		aliases = pairFoldedKeys(src, tgt, nil)
+	}
+	paired := make(map[string]struct{}, len(aliases))
+	for _, sk := range aliases {
+		paired[sk] = struct{}{}
+	}
+	for k, v := range src {
+		if _, ok := d.opts.ignoreKeys[k]; ok {
+			continue
+		}
+		if _, ok := paired[k]; ok {
+			continue
+		}
+		if _, ok := tgt[k]; !ok && (v != nil || !d.opts.nullMissing) {
+			return false
+		}
+	}
+	for k, t := range tgt {
+		if _, ok := d.opts.ignoreKeys[k]; ok {
+			continue
+		}
+		sk := k
+		if a, ok := aliases[k]; ok {
+			sk = a
+		}
+		v, ok := src[sk]
+		if !ok {
+			if t != nil || !d.opts.nullMissing {
+				return false
+			}
+			continue
+		}
+		if !d.equalFold(ptr+string(separator)+rfc6901Escaper.Replace(k), v, t, fold) {
+			return false
+		}
+	}
+	return true
+}
+
 func deepEqualValue(src, tgt interface{}) bool {
 	st := jsonTypeSwitch(src)
 	if st == jsonInvalid {
@@ -73,6 +231,12 @@ func deepEqualValue(src, tgt interface{}) bool {
 		panic(invalidJSONTypeError{t: tgt})
 	}
 	if st != tt {
+		if st == jsonNumberBig || tt == jsonNumberBig {
+			return isNumberType(st) && isNumberType(tt) && equalBigNumbers(src, tgt)
+		}
+		if isNumberType(st) && isNumberType(tt) {
+			return equalMixedNumbers(src, tgt)
+		}
 		return false
 	}
 	switch st {
@@ -86,6 +250,8 @@ func deepEqualValue(src, tgt interface{}) bool {
 		return src.(float64) == tgt.(float64)
 	case jsonNumberString:
 		return src.(json.Number) == tgt.(json.Number)
+	case jsonNumberBig:
+		return equalBigNumbers(src, tgt)
 	case jsonArray:
 		oarr := src.([]interface{})
 		narr := tgt.([]interface{})
@@ -93,6 +259,11 @@ func deepEqualValue(src, tgt interface{}) bool {
 		if len(oarr) != len(narr) {
 			return false
 		}
+		if len(oarr) != 0 && &oarr[0] == &narr[0] {
+			// Both arrays share the same
+			// elements, which are not walked.
+			return true
+		}
 		for i := 0; i < len(oarr); i++ {
 			if !deepEqual(oarr[i], narr[i]) {
 				return false
@@ -106,6 +277,9 @@ func deepEqualValue(src, tgt interface{}) bool {
 		if len(oobj) != len(nobj) {
 			return false
 		}
+		if sameMap(oobj, nobj) {
+			return true
+		}
 		for k, v1 := range oobj {
 			v2, ok := nobj[k]
 			if !ok {
@@ -122,11 +296,49 @@ func deepEqualValue(src, tgt interface{}) bool {
 	}
 }
 
+// sameMap returns whether m1 and m2 are the same map,
+// such as a value compared with itself, or a subtree
+// shared by two documents. Since maps are not comparable,
+// their reflected pointers are.
+func sameMap(m1, m2 map[string]interface{}) bool {
+	return reflect.ValueOf(m1).UnsafePointer() == reflect.ValueOf(m2).UnsafePointer()
+}
+
+// pruneNulls returns a copy of the value where the object
+// keys that hold a null value are removed, recursively.
+// If elems is true, the null elements of arrays are also
+// removed. Scalar values are returned as-is.
+func pruneNulls(i interface{}, elems bool) interface{} {
+	switch v := i.(type) {
+	case []interface{}:
+		arr := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			if e == nil && elems {
+				continue
+			}
+			arr = append(arr, pruneNulls(e, elems))
+		}
+		return arr
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if e == nil {
+				continue
+			}
+			obj[k] = pruneNulls(e, elems)
+		}
+		return obj
+	default:
+		return i
+	}
+}
+
 var jsonTypeNames = []string{
 	jsonInvalid:      "Invalid",
 	jsonBoolean:      "Boolean",
 	jsonNumberFloat:  "Number",
 	jsonNumberString: "json.Number",
+	jsonNumberBig:    "big number",
 	jsonString:       "String",
 	jsonNull:         "Null",
 	jsonObject:       "Object",
@@ -140,3 +352,24 @@ func (t jsonValueType) String() string {
 	}
 	return "type" + strconv.Itoa(int(t))
 }
+
+// equalFunc associates a pattern of pointers with the
+// function that compares the values located at the
+// matching pointers.
+type equalFunc struct {
+	pattern pattern
+	equal   func(a, b interface{}) bool
+}
+
+// customEqual returns whether the values located at the
+// given pointer are equal according to the function of the
+// first matching pattern. It returns false if no pattern
+// matches.
+func (d *Differ) customEqual(ptr string, src, tgt interface{}) bool {
+	for i := range d.opts.equalFuncs {
+		if f := &d.opts.equalFuncs[i]; f.pattern.match(ptr) {
+			return f.equal(src, tgt)
+		}
+	}
+	return false
+}
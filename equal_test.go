@@ -2,6 +2,9 @@ package jsondiff
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
 	"reflect"
 	"testing"
 )
@@ -93,6 +96,9 @@ func Test_jsonTypeSwitch(t *testing.T) {
 }
 
 func Test_deepEqualValue(t *testing.T) {
+	arr := []interface{}{1.0, 2.0, 1.0, 2.0}
+	obj := map[string]interface{}{"a": math.NaN()}
+
 	for _, tc := range []struct {
 		src, tgt interface{}
 		equal    bool
@@ -122,6 +128,33 @@ func Test_deepEqualValue(t *testing.T) {
 			json.Number("69.42"),
 			false,
 		},
+		{
+			// The same map is equal to itself, even
+			// though its NaN number is not.
+			obj,
+			obj,
+			true,
+		},
+		{
+			map[string]interface{}{"a": math.NaN()},
+			map[string]interface{}{"a": math.NaN()},
+			false,
+		},
+		{
+			arr[:2],
+			arr[2:],
+			true,
+		},
+		{
+			arr[:2],
+			arr[1:3],
+			false,
+		},
+		{
+			arr[:2],
+			arr[:3],
+			false,
+		},
 	} {
 		ok := deepEqualValue(tc.src, tc.tgt)
 		if ok != tc.equal {
@@ -130,6 +163,42 @@ func Test_deepEqualValue(t *testing.T) {
 	}
 }
 
+func Test_deepEqualValue_bigNumbers(t *testing.T) {
+	// Both values exceed the precision of float64,
+	// and are rounded to the same float64 value.
+	a, _ := new(big.Int).SetString("12345678901234567890123", 10)
+	b, _ := new(big.Int).SetString("12345678901234567890124", 10)
+	f, _ := new(big.Float).SetPrec(128).SetString("12345678901234567890123")
+	h, _ := new(big.Float).SetPrec(128).SetString("0.1")
+
+	for _, tc := range []struct {
+		src, tgt interface{}
+		equal    bool
+	}{
+		{a, new(big.Int).Set(a), true},
+		{a, b, false},
+		{a, f, true},
+		{b, f, false},
+		{a, json.Number("12345678901234567890123"), true},
+		{a, json.Number("1.2345678901234567890123e22"), true},
+		{b, json.Number("12345678901234567890123"), false},
+		{big.NewInt(42), 42.0, true},
+		{big.NewInt(42), 42.5, false},
+		{h, 0.1, false}, // 0.1 isn't exactly representable in binary
+		{new(big.Float).SetInf(false), new(big.Float).SetInf(false), true},
+		{new(big.Float).SetInf(false), new(big.Float).SetInf(true), false},
+		{a, "12345678901234567890123", false},
+		{(*big.Int)(nil), nil, true},
+	} {
+		if ok := deepEqualValue(tc.src, tc.tgt); ok != tc.equal {
+			t.Errorf("%v == %v: got %t, want %t", tc.src, tc.tgt, ok, tc.equal)
+		}
+		if ok := deepEqualValue(tc.tgt, tc.src); ok != tc.equal {
+			t.Errorf("%v == %v: got %t, want %t", tc.tgt, tc.src, ok, tc.equal)
+		}
+	}
+}
+
 func Test_deepEqual_invalid_type(t *testing.T) {
 	defer func() {
 		if err := recover(); err == nil {
@@ -143,6 +212,42 @@ func Test_deepEqual_invalid_type(t *testing.T) {
 	deepEqual(foo{}, nil)
 }
 
+func Test_pruneNulls(t *testing.T) {
+	src := map[string]interface{}{
+		"a": nil,
+		"b": []interface{}{nil, "x", map[string]interface{}{"c": nil}},
+		"d": map[string]interface{}{"e": nil, "f": 1.0},
+	}
+	for _, tc := range []struct {
+		elems bool
+		want  interface{}
+	}{
+		{
+			false,
+			map[string]interface{}{
+				"b": []interface{}{nil, "x", map[string]interface{}{}},
+				"d": map[string]interface{}{"f": 1.0},
+			},
+		},
+		{
+			true,
+			map[string]interface{}{
+				"b": []interface{}{"x", map[string]interface{}{}},
+				"d": map[string]interface{}{"f": 1.0},
+			},
+		},
+	} {
+		before := fmt.Sprint(src)
+
+		if got := pruneNulls(src, tc.elems); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("got %v, want %v", got, tc.want)
+		}
+		if after := fmt.Sprint(src); after != before {
+			t.Errorf("source value has been modified: got %s, want %s", after, before)
+		}
+	}
+}
+
 func Test_jsonValueType_String(t *testing.T) {
 	for typ := 0; typ < len(jsonTypeNames); typ++ {
 		s := jsonValueType(typ).String()
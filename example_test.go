@@ -151,6 +151,33 @@ func ExampleInvertible() {
 	// {"value":"4","op":"add","path":"/c"}
 }
 
+func ExamplePatch_Invert() {
+	source := `{"a":"1","b":"2"}`
+	target := `{"a":"3","c":"4"}`
+
+	patch, err := jsondiff.CompareJSON(
+		[]byte(source),
+		[]byte(target),
+		jsondiff.Invertible(),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	inverse, err := patch.Invert()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, op := range inverse {
+		fmt.Printf("%s\n", op)
+	}
+	// Output:
+	// {"value":"4","op":"test","path":"/c"}
+	// {"op":"remove","path":"/c"}
+	// {"value":"2","op":"add","path":"/b"}
+	// {"value":"3","op":"test","path":"/a"}
+	// {"value":"1","op":"replace","path":"/a"}
+}
+
 func ExampleFactorize() {
 	source := `{"a":[1,2,3],"b":{"foo":"bar"}}`
 	target := `{"a":[1,2,3],"c":[1,2,3],"d":{"foo":"bar"}}`
@@ -0,0 +1,42 @@
+package jsondiff
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// nonFiniteError returns the error of the first number of
+// the value of an operation that is NaN or infinite, which
+// cannot be represented in JSON.
+func nonFiniteError(path string, v interface{}) error {
+	ptr, f, ok := findNonFinite(v)
+	if !ok {
+		return nil
+	}
+	return fmt.Errorf("%w at %q: %v", ErrNonFinite, path+ptr, f)
+}
+
+// findNonFinite returns the pointer, relative to the value,
+// of a float64 number of the value that is NaN or infinite.
+func findNonFinite(v interface{}) (string, float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return emptyPointer, t, true
+		}
+	case []interface{}:
+		for i, e := range t {
+			if p, f, ok := findNonFinite(e); ok {
+				return string(separator) + strconv.Itoa(i) + p, f, true
+			}
+		}
+	case map[string]interface{}:
+		for k, e := range t {
+			if p, f, ok := findNonFinite(e); ok {
+				return string(separator) + rfc6901Escaper.Replace(k) + p, f, true
+			}
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,70 @@
+package jsondiff
+
+import "strings"
+
+// groupArrayOperations reorders the operations of the patch
+// such that the operations applied to the elements of the same
+// array are contiguous, at the position of the first of them.
+// An operation is moved only if it doesn't conflict with the
+// operations it is moved before, and the relative order of the
+// operations of each array is preserved.
+func groupArrayOperations(p Patch) Patch {
+	if len(p) < 3 {
+		return p
+	}
+	out := make(Patch, 0, len(p))
+	placed := make([]bool, len(p))
+
+	for i, op := range p {
+		if placed[i] {
+			continue
+		}
+		out = append(out, op)
+		placed[i] = true
+
+		arr, ok := arrayParent(op)
+		if !ok {
+			continue
+		}
+		// Hoist the next operations of the same array,
+		// until one of them depends on an operation it
+		// would be moved before.
+	L:
+		for j := i + 1; j < len(p); j++ {
+			if placed[j] {
+				continue
+			}
+			if a, ok := arrayParent(p[j]); !ok || a != arr {
+				continue
+			}
+			for k := i + 1; k < j; k++ {
+				if placed[k] {
+					continue
+				}
+				if _, ok := operationsConflict(p[k], p[j]); ok {
+					break L
+				}
+			}
+			out = append(out, p[j])
+			placed[j] = true
+		}
+	}
+	return append(p[:0], out...)
+}
+
+// arrayParent returns the location of the array whose
+// element is the target of the operation. Since the
+// document is unknown to the operation, a numeric last
+// segment is assumed to be an index.
+func arrayParent(op Operation) (string, bool) {
+	i := strings.LastIndexByte(op.Path, separator)
+	if i == -1 {
+		return "", false
+	}
+	if seg := op.Path[i+1:]; seg != "-" {
+		if _, ok := parseIndex(seg); !ok {
+			return "", false
+		}
+	}
+	return op.Path[:i], true
+}
@@ -0,0 +1,71 @@
+package jsondiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_groupArrayOperations(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		patch Patch
+		want  []string
+	}{
+		{
+			"independent operations",
+			Patch{
+				{Type: OperationAdd, Path: "/a/0"},
+				{Type: OperationReplace, Path: "/a/1/x"},
+				{Type: OperationReplace, Path: "/a/2"},
+			},
+			[]string{"/a/0", "/a/2", "/a/1/x"},
+		},
+		{
+			"shifted index",
+			Patch{
+				{Type: OperationReplace, Path: "/a/0"},
+				{Type: OperationReplace, Path: "/a/2/x"},
+				{Type: OperationRemove, Path: "/a/1"},
+			},
+			[]string{"/a/0", "/a/2/x", "/a/1"},
+		},
+		{
+			"relative order",
+			Patch{
+				{Type: OperationReplace, Path: "/a/0"},
+				{Type: OperationRemove, Path: "/a/2/x"},
+				{Type: OperationRemove, Path: "/a/1"},
+				{Type: OperationReplace, Path: "/a/0"},
+			},
+			[]string{"/a/0", "/a/2/x", "/a/1", "/a/0"},
+		},
+		{
+			"move source",
+			Patch{
+				{Type: OperationReplace, Path: "/a/0"},
+				{Type: OperationMove, From: "/a/3", Path: "/b"},
+				{Type: OperationReplace, Path: "/a/3"},
+			},
+			[]string{"/a/0", "/b", "/a/3"},
+		},
+		{
+			"moved element shifts indices",
+			Patch{
+				{Type: OperationReplace, Path: "/arr/2"},
+				{Type: OperationMove, From: "/arr/0", Path: "/k"},
+				{Type: OperationReplace, Path: "/arr/1"},
+			},
+			[]string{"/arr/2", "/k", "/arr/1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var paths []string
+			for _, op := range groupArrayOperations(tc.patch) {
+				paths = append(paths, op.Path)
+			}
+			if !reflect.DeepEqual(paths, tc.want) {
+				t.Errorf("got %q, want %q", paths, tc.want)
+			}
+		})
+	}
+}
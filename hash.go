@@ -1,17 +1,71 @@
 package jsondiff
 
 import (
-	"encoding/binary"
+	"encoding/json"
 	"hash/maphash"
-	"math"
+	"math/big"
+	"sort"
 )
 
+// Hasher computes the digests of JSON values, as decoded by
+// the json.Unmarshal function, which the Differ uses to find
+// the values that are equal. See the WithHasher option.
+type Hasher interface {
+	Digest(v interface{}) uint64
+}
+
 type hasher struct {
 	mh maphash.Hash
+
+	// custom is the Hasher of the WithHasher
+	// option, which replaces the default hash.
+	custom Hasher
+
+	// scalars holds the decoders of the WithScalarDecoder
+	// option, and ptr the pointer of the value being hashed,
+	// which is maintained only if there are any.
+	scalars []scalarDecoder
+	ptr     pointer
+
+	// numbers and intFloat reflect the NumericValueEquality
+	// and IntegerFloatStrict options.
+	numbers  bool
+	intFloat bool
+
+	// tolerant indicates that the numbers are compared
+	// with the tolerance of the Epsilon options, and must
+	// all be hashed alike.
+	tolerant bool
+
+	// nullMissing indicates that the keys whose value
+	// is null are equal to the missing keys, and are
+	// not hashed, as with the NullEqualsMissing option.
+	nullMissing bool
+
+	// ignoreKeys holds the keys of the IgnoreKeysAnywhere
+	// option, which are not hashed, and foldKeys indicates
+	// that the keys are hashed by their folded form, as with
+	// the CaseInsensitiveKeys option.
+	ignoreKeys map[string]struct{}
+	foldKeys   bool
 }
 
 func (h *hasher) digest(val interface{}) uint64 {
+	return h.digestAt(emptyPointer, val)
+}
+
+// digestAt is similar to digest, but it takes the pointer
+// of the value, to hash the strings that match one of the
+// patterns of the WithScalarDecoder option by their decoded
+// canonical representation.
+func (h *hasher) digestAt(ptr string, val interface{}) uint64 {
+	if h.custom != nil {
+		return h.custom.Digest(val)
+	}
 	h.mh.Reset()
+	if h.scalars != nil {
+		h.ptr.buf = append(h.ptr.buf[:0], ptr...)
+	}
 	h.hash(val)
 
 	return h.mh.Sum64()
@@ -20,6 +74,11 @@ func (h *hasher) digest(val interface{}) uint64 {
 func (h *hasher) hash(i interface{}) {
 	switch v := i.(type) {
 	case string:
+		if h.scalars != nil {
+			if c, ok := decodeScalar(h.scalars, h.ptr.string(), v); ok {
+				v = c
+			}
+		}
 		_, _ = h.mh.WriteString(v)
 	case bool:
 		if v {
@@ -28,13 +87,67 @@ func (h *hasher) hash(i interface{}) {
 			_ = h.mh.WriteByte('0')
 		}
 	case float64:
-		var buf [8]byte
-		binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
-		_, _ = h.mh.Write(buf[:])
+		// A float is equal to the number represented
+		// by its shortest decimal representation, and
+		// is hashed alike.
+		h.hash(json.Number(formatFloat(v)))
+	case json.Number:
+		// Numbers are hashed using their exact
+		// representation, like they are compared,
+		// unless they are compared by value.
+		_ = h.mh.WriteByte('#')
+		if h.tolerant {
+			break
+		}
+		if h.numbers {
+			if c, ok := normalizeNumber(string(v)); ok {
+				if h.intFloat && !isIntegerNumber(v) {
+					_ = h.mh.WriteByte('.')
+				}
+				_, _ = h.mh.WriteString(c)
+				break
+			}
+		}
+		_, _ = h.mh.WriteString(string(v))
+	case *big.Int:
+		if v == nil {
+			_ = h.mh.WriteByte('0')
+			break
+		}
+		// The big numbers are hashed by the canonical
+		// representation of their value, such that the
+		// integers are hashed alike whatever their type.
+		_ = h.mh.WriteByte('#')
+		if h.tolerant {
+			break
+		}
+		_, _ = h.mh.WriteString(v.String())
+	case *big.Float:
+		if v == nil {
+			_ = h.mh.WriteByte('0')
+			break
+		}
+		_ = h.mh.WriteByte('#')
+		if h.tolerant {
+			break
+		}
+		if v.IsInt() {
+			i, _ := v.Int(nil)
+			_, _ = h.mh.WriteString(i.String())
+			break
+		}
+		_, _ = h.mh.WriteString(v.Text('g', -1))
 	case nil:
 		_ = h.mh.WriteByte('0')
 	case []interface{}:
-		for _, e := range v {
+		for i, e := range v {
+			if h.scalars != nil {
+				n := len(h.ptr.buf)
+				h.ptr.appendIndex(i)
+				h.hash(e)
+				h.ptr.buf = h.ptr.buf[:n]
+				continue
+			}
 			h.hash(e)
 		}
 	case map[string]interface{}:
@@ -42,13 +155,36 @@ func (h *hasher) hash(i interface{}) {
 
 		// Extract keys first, and sort them
 		// in lexicographical order.
-		for k := range v {
+		for k, e := range v {
+			if e == nil && h.nullMissing {
+				continue
+			}
+			if _, ok := h.ignoreKeys[k]; ok {
+				continue
+			}
 			keys = append(keys, k)
 		}
-		sortStrings(keys)
-
+		if h.foldKeys {
+			sort.Slice(keys, func(i, j int) bool {
+				fi, fj := foldASCII(keys[i]), foldASCII(keys[j])
+				return fi < fj || fi == fj && keys[i] < keys[j]
+			})
+		} else {
+			sortStrings(keys)
+		}
 		for _, k := range keys {
-			_, _ = h.mh.WriteString(k)
+			if h.foldKeys {
+				_, _ = h.mh.WriteString(foldASCII(k))
+			} else {
+				_, _ = h.mh.WriteString(k)
+			}
+			if h.scalars != nil {
+				n := len(h.ptr.buf)
+				h.ptr.appendKey(k)
+				h.hash(v[k])
+				h.ptr.buf = h.ptr.buf[:n]
+				continue
+			}
 			h.hash(v[k])
 		}
 	}
@@ -3,6 +3,7 @@ package jsondiff
 import (
 	"encoding/json"
 	"hash/maphash"
+	"math/big"
 	"os"
 	"testing"
 )
@@ -34,6 +35,202 @@ func Test_digestValue(t *testing.T) {
 	}
 }
 
+func Test_digestValue_numbers(t *testing.T) {
+	h := hasher{}
+
+	for _, pair := range [][2]json.Number{
+		{"1", "1.0"},
+		{"1", "2"},
+		{"1.5", "1.50"},
+		{"100", "1e2"},
+	} {
+		if h.digest(pair[0]) == h.digest(pair[1]) {
+			t.Errorf("expected hash sums of %s and %s to differ", pair[0], pair[1])
+		}
+	}
+	if h.digest(json.Number("42")) != h.digest(json.Number("42")) {
+		t.Errorf("expected hash sums to be equal")
+	}
+}
+
+func Test_digestValue_mixedNumbers(t *testing.T) {
+	h := hasher{}
+
+	for _, tc := range []struct {
+		f     float64
+		n     json.Number
+		equal bool
+	}{
+		{2, "2", true},
+		{1.5, "1.5", true},
+		{1e21, "1e+21", true},
+		{2, "2.0", false},
+		{2, "3", false},
+	} {
+		if equal := h.digest(tc.f) == h.digest(tc.n); equal != tc.equal {
+			t.Errorf("got equal hash sums of %v and %s: %t, want %t", tc.f, tc.n, equal, tc.equal)
+		}
+		if equal := deepEqual(tc.f, tc.n); equal != tc.equal {
+			t.Errorf("got equal values %v and %s: %t, want %t", tc.f, tc.n, equal, tc.equal)
+		}
+	}
+	d := new(Differ).WithOpts(Equivalent())
+	src := []interface{}{1.0, 2.0}
+	tgt := []interface{}{json.Number("2"), json.Number("1")}
+	if patch, err := d.CompareWithError(src, tgt); err != nil || len(patch) != 0 {
+		t.Errorf("got patch %v and error %v, want none", patch, err)
+	}
+}
+
+func Test_digestValue_bigNumbers(t *testing.T) {
+	h := hasher{}
+
+	a, _ := new(big.Int).SetString("12345678901234567890123", 10)
+	b, _ := new(big.Int).SetString("12345678901234567890124", 10)
+	f, _ := new(big.Float).SetPrec(128).SetString("12345678901234567890123")
+
+	if h.digest(a) != h.digest(new(big.Int).Set(a)) {
+		t.Errorf("expected hash sums of equal big integers to be equal")
+	}
+	if h.digest(a) != h.digest(f) {
+		t.Errorf("expected hash sums of equal big integer and float to be equal")
+	}
+	if h.digest(a) == h.digest(b) {
+		t.Errorf("expected hash sums of %s and %s to differ", a, b)
+	}
+	src := []interface{}{a, b, "foo"}
+	tgt := []interface{}{"foo", new(big.Int).Set(b), f}
+
+	patch, err := CompareWithoutMarshal(src, tgt, Equivalent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("expected empty patch, got:\n%s", patch.String())
+	}
+	patch, err = CompareWithoutMarshal(
+		map[string]interface{}{"a": a, "b": b},
+		map[string]interface{}{"a": f, "b": a},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 || patch[0].Path != "/b" {
+		t.Errorf("expected single replace of /b, got:\n%s", patch.String())
+	}
+}
+
+func Test_digestValue_numericValue(t *testing.T) {
+	h := hasher{numbers: true}
+
+	for _, pair := range [][2]json.Number{
+		{"1.50", "1.5"},
+		{"1e2", "100"},
+		{"-0", "0"},
+		{"0.5", "5E-1"},
+	} {
+		if h.digest(pair[0]) != h.digest(pair[1]) {
+			t.Errorf("expected hash sums of %s and %s to be equal", pair[0], pair[1])
+		}
+	}
+	if h.digest(json.Number("1")) == h.digest(json.Number("-1")) {
+		t.Errorf("expected hash sums to differ")
+	}
+	h.intFloat = true
+	if h.digest(json.Number("1")) == h.digest(json.Number("1.0")) {
+		t.Errorf("expected hash sums of integer and decimal forms to differ")
+	}
+}
+
+// idHasher hashes the objects by the value of their
+// id member, and all the other values alike.
+type idHasher struct{ calls int }
+
+func (h *idHasher) Digest(v interface{}) uint64 {
+	h.calls++
+	if o, ok := v.(map[string]interface{}); ok {
+		if id, ok := o["id"].(float64); ok {
+			return uint64(id)
+		}
+	}
+	return 0
+}
+
+func TestWithHasher(t *testing.T) {
+	src := `{"a":[{"id":1,"v":"x"},{"id":2,"v":"y"}],"b":{"id":3,"v":"some long value"}}`
+
+	var h idHasher
+	patch, err := CompareJSON([]byte(src), []byte(`{"a":[{"id":2,"v":"y"},{"id":1,"v":"x"}],"b":{"id":3,"v":"some long value"},"c":{"id":3,"v":"some long value"}}`),
+		WithHasher(&h), Equivalent(), Factorize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.calls == 0 {
+		t.Error("expected the custom hasher to be called")
+	}
+	want := `{"op":"copy","from":"/b","path":"/c"}`
+	if s := patch.String(); s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+	// The elements whose digests are equal
+	// are compared to confirm their equality.
+	patch, err = CompareJSON([]byte(src), []byte(`{"a":[{"id":2,"v":"z"},{"id":1,"v":"x"}],"b":{"id":3,"v":"some long value"}}`),
+		WithHasher(&h), Equivalent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) == 0 {
+		t.Error("expected non-empty patch")
+	}
+}
+
+// constHasher hashes all the values alike,
+// such that every two values collide.
+type constHasher struct{}
+
+func (constHasher) Digest(interface{}) uint64 { return 42 }
+
+func TestDiffer_factorizeCollisions(t *testing.T) {
+	src := `{"a":{"b":"some long value"},"c":"another long value"}`
+	tgt := `{"a":{"b":"some long value"},"c":"another long value","d":"a different long value"}`
+
+	for _, opts := range [][]Option{
+		{Factorize(), WithHasher(constHasher{})},
+		{Factorize(), FactorizeCache(8), WithHasher(constHasher{})},
+	} {
+		patch, err := CompareJSON([]byte(src), []byte(tgt), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"value":"a different long value","op":"add","path":"/d"}`
+		if s := patch.String(); s != want {
+			t.Errorf("got %s, want %s", s, want)
+		}
+	}
+}
+
+func Test_normalizeNumber(t *testing.T) {
+	for _, tc := range []struct {
+		s, want string
+		ok      bool
+	}{
+		{"0", "0", true},
+		{"-0.00", "0", true},
+		{"1.50", "15e-1", true},
+		{"100", "1e2", true},
+		{"1e2", "1e2", true},
+		{"-0.012E+3", "-12e0", true},
+		{"1.", "", false},
+		{"abc", "", false},
+		{"1e", "", false},
+	} {
+		got, ok := normalizeNumber(tc.s)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("normalizeNumber(%q): got (%q, %t), want (%q, %t)", tc.s, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
 func BenchmarkHashing(b *testing.B) {
 	if testing.Short() {
 		b.Skip("skipping benchmark in short mode")
@@ -0,0 +1,90 @@
+package jsondiff
+
+import "strings"
+
+// ElementIdentity represents the identity of an
+// element of an array compared by the Differ.
+type ElementIdentity struct {
+	// Token identifies the element. Two identities that
+	// share the same token represent the same element,
+	// such as the source and destination of a move.
+	Token int
+
+	// Array is the pointer of the array, as
+	// referenced by the operations of the patch.
+	Array string
+
+	// Source and Target are the indices of the element
+	// in the source and target arrays. Source is -1 if
+	// the element was added, and Target is -1 if it was
+	// removed.
+	Source int
+	Target int
+}
+
+// IdentityMap associates the operations of a
+// patch with the array elements they apply to.
+type IdentityMap struct {
+	// Elements lists the identities of the elements
+	// of the arrays compared by the Differ.
+	Elements []ElementIdentity
+
+	// Operations associates the index of the operations
+	// of the patch that apply to an array element, or to
+	// one of its descendants, with the token of the
+	// innermost element.
+	Operations map[int]int
+}
+
+// Identities returns the identities of the array elements
+// compared by the Differ, and the association between the
+// operations of the patch and the elements they apply to.
+// It requires the TrackElementIdentity option, and returns
+// an empty map otherwise.
+func (d *Differ) Identities() IdentityMap {
+	m := IdentityMap{
+		Elements:   make([]ElementIdentity, len(d.idents)),
+		Operations: make(map[int]int),
+	}
+	copy(m.Elements, d.idents)
+
+	for i, op := range d.patch {
+		if op.token != 0 {
+			m.Operations[i] = op.token
+		}
+	}
+	return m
+}
+
+// track assigns a new identity to the element at
+// index si of the source array, and ti of the target
+// array, and makes it the current identity.
+func (d *Differ) track(arr string, si, ti int) {
+	if !d.opts.identities {
+		return
+	}
+	d.token = len(d.idents) + 1
+	d.idents = append(d.idents, ElementIdentity{
+		Token:  d.token,
+		Array:  strings.Clone(arr),
+		Source: si,
+		Target: ti,
+	})
+}
+
+// restoreToken restores the current identity
+// once an array has been compared.
+func (d *Differ) restoreToken(token int) {
+	d.token = token
+}
+
+// unifyToken associates the current element, which is
+// the destination of a move, with the token of the element
+// that is the source of the move.
+func (d *Differ) unifyToken(token int) {
+	if !d.opts.identities || token == 0 || d.token == 0 {
+		return
+	}
+	d.idents[d.token-1].Token = token
+	d.token = token
+}
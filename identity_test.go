@@ -0,0 +1,96 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffer_Identities(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		src, tgt string
+		opts     []Option
+		elements []ElementIdentity
+		ops      map[int]int
+	}{
+		{
+			name: "positional",
+			src:  `["a","b","c"]`,
+			tgt:  `["a","x"]`,
+			elements: []ElementIdentity{
+				{Token: 1, Array: "", Source: 2, Target: -1},
+				{Token: 2, Array: "", Source: 0, Target: 0},
+				{Token: 3, Array: "", Source: 1, Target: 1},
+			},
+			ops: map[int]int{0: 1, 1: 3},
+		},
+		{
+			name: "lcs",
+			src:  `{"a":["a","b",{"c":1}]}`,
+			tgt:  `{"a":["b",{"c":2},"d"]}`,
+			opts: []Option{LCS()},
+			elements: []ElementIdentity{
+				{Token: 1, Array: "/a", Source: 0, Target: -1},
+				{Token: 2, Array: "/a", Source: 1, Target: 0},
+				{Token: 3, Array: "/a", Source: 2, Target: 1},
+				{Token: 4, Array: "/a", Source: -1, Target: 2},
+			},
+			ops: map[int]int{0: 1, 1: 3, 2: 4},
+		},
+		{
+			name: "nested arrays",
+			src:  `[[1],[2]]`,
+			tgt:  `[[1],[2,3]]`,
+			elements: []ElementIdentity{
+				{Token: 1, Array: "", Source: 0, Target: 0},
+				{Token: 2, Array: "", Source: 1, Target: 1},
+				{Token: 3, Array: "/1", Source: 0, Target: 0},
+				{Token: 4, Array: "/1", Source: -1, Target: 1},
+			},
+			ops: map[int]int{0: 4},
+		},
+		{
+			name: "move",
+			src:  `{"a":[{"x":1}],"b":[]}`,
+			tgt:  `{"a":[],"b":[{"x":1}]}`,
+			opts: []Option{Factorize()},
+			elements: []ElementIdentity{
+				{Token: 1, Array: "/a", Source: 0, Target: -1},
+				{Token: 1, Array: "/b", Source: -1, Target: 0},
+			},
+			ops: map[int]int{0: 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var src, tgt interface{}
+			if err := json.Unmarshal([]byte(tc.src), &src); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.tgt), &tgt); err != nil {
+				t.Fatal(err)
+			}
+			d := (&Differ{}).WithOpts(append(tc.opts, TrackElementIdentity())...)
+			d.Compare(src, tgt)
+			t.Logf("\n%s", d.Patch())
+
+			m := d.Identities()
+			if !reflect.DeepEqual(m.Elements, tc.elements) {
+				t.Errorf("elements mismatch:\ngot:  %+v\nwant: %+v", m.Elements, tc.elements)
+			}
+			if !reflect.DeepEqual(m.Operations, tc.ops) {
+				t.Errorf("operations mismatch: got %v, want %v", m.Operations, tc.ops)
+			}
+		})
+	}
+}
+
+func TestDiffer_Identities_disabled(t *testing.T) {
+	d := &Differ{}
+	d.Compare([]interface{}{"a"}, []interface{}{"b"})
+
+	m := d.Identities()
+	if len(m.Elements) != 0 || len(m.Operations) != 0 {
+		t.Errorf("expected empty map, got %+v", m)
+	}
+}
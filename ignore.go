@@ -0,0 +1,80 @@
+package jsondiff
+
+// ignoreRule represents a pointer of the Ignores or
+// OnlyPaths options, whose matches are tracked with
+// the TrackIgnores option.
+type ignoreRule struct {
+	raw string  // pointer given to the option
+	pat pattern // resolved pointer
+	ok  bool    // false if it references no value
+}
+
+func makeRules(ptrs []string) []ignoreRule {
+	rules := make([]ignoreRule, len(ptrs))
+	for i, s := range ptrs {
+		rules[i].raw = s
+		if ptr, ok := resolveOptionPointer(s); ok {
+			rules[i].pat = compilePattern(ptr)
+			rules[i].ok = true
+		}
+	}
+	return rules
+}
+
+// trackRules records in a bitset the rules that match
+// the pointer, the pointers of the Ignores option first,
+// followed by the paths of the OnlyPaths option, which
+// match the pointers located within them.
+func (d *Differ) trackRules(ptr string) {
+	n := len(d.opts.ignoreRules) + len(d.opts.onlyRules)
+	if len(d.ruleHits) < (n+63)/64 {
+		d.ruleHits = append(d.ruleHits, make([]uint64, (n+63)/64-len(d.ruleHits))...)
+	}
+	for i := range d.opts.ignoreRules {
+		if r := &d.opts.ignoreRules[i]; r.ok && r.pat.match(ptr) {
+			d.ruleHits[i/64] |= 1 << (i % 64)
+		}
+	}
+	for j := range d.opts.onlyRules {
+		if r := &d.opts.onlyRules[j]; r.ok && isPointerPrefix(r.pat.raw, ptr) {
+			i := len(d.opts.ignoreRules) + j
+			d.ruleHits[i/64] |= 1 << (i % 64)
+		}
+	}
+}
+
+// mergeRuleHits records the rules matched by a worker.
+func (d *Differ) mergeRuleHits(w *Differ) {
+	if len(d.ruleHits) < len(w.ruleHits) {
+		d.ruleHits = append(d.ruleHits, make([]uint64, len(w.ruleHits)-len(d.ruleHits))...)
+	}
+	for i, b := range w.ruleHits {
+		d.ruleHits[i] |= b
+	}
+}
+
+// UnusedIgnores returns the pointers of the Ignores and
+// OnlyPaths options, as given to the options, that matched
+// none of the values compared since the last reset, when the
+// TrackIgnores option is enabled. The pointers of the Ignores
+// option are listed first, in order. A relative pointer that
+// goes up from the root of the documents is always unused.
+// Since the values that are equal are not traversed, a pointer
+// is only matched if it is located within values that differ.
+func (d *Differ) UnusedIgnores() []string {
+	if !d.opts.trackRules {
+		return nil
+	}
+	var unused []string
+	for i := 0; i < len(d.opts.ignoreRules)+len(d.opts.onlyRules); i++ {
+		if i/64 < len(d.ruleHits) && d.ruleHits[i/64]&(1<<(i%64)) != 0 {
+			continue
+		}
+		if i < len(d.opts.ignoreRules) {
+			unused = append(unused, d.opts.ignoreRules[i].raw)
+		} else {
+			unused = append(unused, d.opts.onlyRules[i-len(d.opts.ignoreRules)].raw)
+		}
+	}
+	return unused
+}
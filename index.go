@@ -0,0 +1,117 @@
+package jsondiff
+
+import "errors"
+
+// ErrNotIndexed is the error returned by the method
+// Differ.CompareAgainstIndex when no source document has
+// been indexed with the Differ.Index method.
+var ErrNotIndexed = errors.New("jsondiff: no indexed source document")
+
+// sourceIndex holds the source document indexed by
+// Differ.Index, and the digests of its arrays and
+// objects, indexed by pointer.
+type sourceIndex struct {
+	doc     interface{}
+	digests map[string]uint64
+}
+
+// Index computes the digests of the arrays and objects of
+// the given source document, which are reused by the next
+// comparisons of the document with Differ.CompareAgainstIndex,
+// rather than computed again by each of them. The digests
+// locate the unchanged values of the Factorize option, and
+// match the elements of the arrays compared with the LCS and
+// Equivalent options.
+//
+// The index is retained by Differ.Reset, and replaced by
+// the next call to Index. The document must not be modified
+// while it is indexed, and the options of the Differ must not
+// be changed either, since they determine the digests.
+func (d *Differ) Index(doc interface{}) {
+	d.hasher.nullMissing = d.opts.nullMissing && !d.opts.strict
+
+	d.index = &sourceIndex{
+		doc:     doc,
+		digests: make(map[string]uint64),
+	}
+	d.indexValue(pointer{}, doc)
+}
+
+func (d *Differ) indexValue(ptr pointer, v interface{}) {
+	switch t := v.(type) {
+	case []interface{}:
+		for i, e := range t {
+			p := ptr.clone()
+			p.appendIndex(i)
+			d.indexValue(p, e)
+		}
+	case map[string]interface{}:
+		for k, e := range t {
+			p := ptr.clone()
+			p.appendKey(k)
+			d.indexValue(p, e)
+		}
+	default:
+		// The scalar values are cheaper
+		// to hash than to look up.
+		return
+	}
+	d.index.digests[ptr.copy()] = d.hasher.digestAt(ptr.string(), v)
+}
+
+// CompareAgainstIndex is similar to CompareWithError, but
+// it compares the source document indexed by Differ.Index
+// with the target value, and reuses the digests of the
+// index. It returns ErrNotIndexed if there is no index.
+func (d *Differ) CompareAgainstIndex(tgt interface{}) (Patch, error) {
+	if d.index == nil {
+		return nil, ErrNotIndexed
+	}
+	d.indexed = true
+	defer func() { d.indexed = false }()
+
+	return d.CompareWithError(d.index.doc, tgt)
+}
+
+// indexedDigest returns the digest of the value of the source
+// document located at ptr from the index, if the comparison is
+// against the indexed document, and the value is an array or
+// an object. The digests of the numbers compared with the
+// tolerance of the Epsilon options differ from those of the
+// index, which isn't used.
+func (d *Differ) indexedDigest(ptr string) (uint64, bool) {
+	if !d.indexed || d.tolerates() {
+		return 0, false
+	}
+	k, ok := d.index.digests[ptr]
+	return k, ok
+}
+
+// sourceDigest is similar to digestElem, but the element
+// at index i of the array located at ptr belongs to the
+// source document, whose digest may be indexed.
+func (d *Differ) sourceDigest(ptr pointer, i int, v interface{}) uint64 {
+	if d.indexed && isContainer(v) {
+		p := ptr.clone()
+		p.appendIndex(i)
+		if k, ok := d.indexedDigest(p.string()); ok {
+			return k
+		}
+	}
+	return d.digestElem(ptr, i, v)
+}
+
+// sourceDigests is similar to digestElems, but the
+// array located at ptr belongs to the source document.
+func (d *Differ) sourceDigests(ptr pointer, a []interface{}) []uint64 {
+	if !d.indexed || d.hasher.scalars != nil {
+		// The elements are hashed without their
+		// pointer, unlike the indexed values.
+		return d.digestElems(a)
+	}
+	hs := make([]uint64, len(a))
+	for i, v := range a {
+		hs[i] = d.sourceDigest(ptr, i, v)
+	}
+	return hs
+}
@@ -0,0 +1,103 @@
+package jsondiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiffer_CompareAgainstIndex(t *testing.T) {
+	item := func(i int, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"id":   float64(i),
+			"name": name,
+			"tags": []interface{}{"a", map[string]interface{}{"k": float64(i)}},
+		}
+	}
+	doc := func(names ...string) map[string]interface{} {
+		items := make([]interface{}, len(names))
+		for i, n := range names {
+			items[i] = item(i, n)
+		}
+		return map[string]interface{}{
+			"items": items,
+			"meta":  map[string]interface{}{"version": 1.0, "labels": []interface{}{"x", "y"}},
+			"copy":  nil,
+		}
+	}
+	// The arrays are long enough for their
+	// elements to be compared by digest.
+	names := strings.Split("abcdefghijklmnopqrst", "")
+	src := doc(names...)
+
+	moved := doc(names...)
+	items := moved["items"].([]interface{})
+	items[0], items[3] = items[3], items[0]
+	moved["copy"] = item(1, "b")
+
+	targets := []interface{}{
+		src,
+		doc(append(names, "u")...),
+		doc(names[2:]...),
+		doc(append([]string{"x"}, names[5:]...)...),
+		moved,
+		map[string]interface{}{"items": []interface{}{}},
+		[]interface{}{src},
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{Factorize()},
+		{Factorize(), FactorizeCache(2)},
+		{Factorize(), Rationalize(), LCS()},
+		{Equivalent()},
+		{DetectArrayMoves()},
+		{LCS(), Epsilon(0.5)},
+		{Factorize(), Parallel(2)},
+	} {
+		d := new(Differ).WithOpts(opts...)
+		d.Index(src)
+
+		for i, tgt := range targets {
+			want, err := CompareWithoutMarshal(src, tgt, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			patch, err := d.CompareAgainstIndex(tgt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := patch.String(), want.String(); got != want {
+				t.Errorf("target #%d: got patch:\n%s\nwant:\n%s", i, got, want)
+			}
+			d.Reset()
+		}
+		if d.index == nil {
+			t.Errorf("expected index to be retained by reset")
+		}
+	}
+}
+
+func TestDiffer_CompareAgainstIndex_notIndexed(t *testing.T) {
+	d := new(Differ)
+	if _, err := d.CompareAgainstIndex(map[string]interface{}{}); !errors.Is(err, ErrNotIndexed) {
+		t.Errorf("got error %v, want %v", err, ErrNotIndexed)
+	}
+}
+
+func TestDiffer_Index(t *testing.T) {
+	d := new(Differ)
+	d.Index(map[string]interface{}{
+		"a": []interface{}{1.0, map[string]interface{}{"b~/": "c"}},
+		"d": "e",
+	})
+	var ptrs []string
+	for p := range d.index.digests {
+		ptrs = append(ptrs, p)
+	}
+	sortStrings(ptrs)
+
+	if got, want := fmt.Sprint(ptrs), `[ /a /a/1]`; got != want {
+		t.Errorf("got indexed pointers %s, want %s", got, want)
+	}
+}
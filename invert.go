@@ -0,0 +1,155 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Invert returns the patch that reverts the changes of the
+// patch, such that its application to the patched document
+// returns the original document. The patch must be invertible,
+// as generated with the Invertible option: the previous values
+// of the remove and replace operations are found in the test
+// operations that precede them, which are required, while the
+// other test operations are preserved. The previous value of
+// a replaced object member can also be found in the test of
+// its parent object that precedes the replacements of its
+// siblings, as generated with the CoalesceTests option. The replace and remove
+// operations of the inverse are preceded by a test operation
+// of the value they replace or remove, such that the inverse
+// is also invertible.
+//
+// The add and copy operations are assumed to create the value
+// they set, rather than replacing the value of an existing
+// object member, and are inverted by remove operations. The
+// operations that append an element to an array with the "-"
+// reference token cannot be inverted, since the index of the
+// element is unknown.
+func (p Patch) Invert() (Patch, error) {
+	inv := make(Patch, 0, len(p))
+
+	for i := len(p) - 1; i >= 0; i-- {
+		op := p[i]
+		var prev *Operation
+		if i > 0 && p[i-1].Type == OperationTest && p[i-1].Path == op.Path {
+			prev = &p[i-1]
+		}
+		switch op.Type {
+		case OperationTest, OperationChecksum:
+			inv = append(inv, op)
+		case OperationAdd, OperationCopy:
+			if op.Path == emptyPointer {
+				// The operation replaces the root document.
+				if prev == nil || op.Type == OperationCopy {
+					return nil, invertError(i, op, "the previous root document is unknown")
+				}
+				inv = append(inv,
+					Operation{Type: OperationTest, Path: op.Path, Value: op.Value},
+					Operation{Type: OperationAdd, Path: op.Path, Value: prev.Value, OldValue: op.Value},
+				)
+				i--
+				continue
+			}
+			if strings.HasSuffix(op.Path, "/-") {
+				return nil, invertError(i, op, "the index of the appended element is unknown")
+			}
+			if op.Type == OperationAdd {
+				inv = append(inv, Operation{Type: OperationTest, Path: op.Path, Value: op.Value})
+			}
+			inv = append(inv, Operation{Type: OperationRemove, Path: op.Path, OldValue: op.Value})
+		case OperationRemove, OperationReplace:
+			if prev == nil && op.Type == OperationReplace {
+				// The test of the member may have been
+				// coalesced with the tests of its siblings
+				// with the CoalesceTests option.
+				if v, ok := coalescedValue(p, i); ok {
+					inv = append(inv,
+						Operation{Type: OperationTest, Path: op.Path, Value: op.Value},
+						Operation{Type: OperationReplace, Path: op.Path, Value: v, OldValue: op.Value},
+					)
+					continue
+				}
+			}
+			if prev == nil {
+				return nil, invertError(i, op, "no test operation precedes it")
+			}
+			if op.Type == OperationRemove {
+				inv = append(inv, Operation{Type: OperationAdd, Path: op.Path, Value: prev.Value})
+			} else {
+				inv = append(inv,
+					Operation{Type: OperationTest, Path: op.Path, Value: op.Value},
+					Operation{Type: OperationReplace, Path: op.Path, Value: prev.Value, OldValue: op.Value},
+				)
+			}
+			// The test operation is part of the
+			// inverse of the current operation.
+			i--
+		case OperationMove:
+			if strings.HasSuffix(op.Path, "/-") {
+				return nil, invertError(i, op, "the index of the appended element is unknown")
+			}
+			inv = append(inv, Operation{Type: OperationMove, From: op.Path, Path: op.From})
+		case OperationIncrement:
+			v, ok := negateNumber(op.Value)
+			if !ok {
+				return nil, invertError(i, op, "its value is not a number")
+			}
+			inv = append(inv, Operation{Type: OperationIncrement, Path: op.Path, Value: v})
+		default:
+			return nil, invertError(i, op, "its type is unsupported")
+		}
+	}
+	return inv, nil
+}
+
+// coalescedValue returns the previous value of the member
+// replaced by the operation at index i of the patch, from
+// the test operation of its parent object that precedes the
+// replacements of its sibling members, if any.
+func coalescedValue(p Patch, i int) (interface{}, bool) {
+	parent := parentPointer(p[i].Path)
+	for j := i - 1; j >= 0; j-- {
+		op := p[j]
+		if op.Type == OperationReplace && op.Path != parent && parentPointer(op.Path) == parent {
+			continue
+		}
+		if op.Type != OperationTest || op.Path != parent {
+			break
+		}
+		obj, ok := op.Value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		tokens, err := decodePointer(p[i].Path)
+		if err != nil || len(tokens) == 0 {
+			break
+		}
+		v, ok := obj[tokens[len(tokens)-1]]
+		return v, ok
+	}
+	return nil, false
+}
+
+func invertError(i int, op Operation, reason string) error {
+	return fmt.Errorf("jsondiff: operation #%d (%s %q) cannot be inverted: %s", i, op.Type, op.Path, reason)
+}
+
+// negateNumber returns the opposite of a JSON number.
+func negateNumber(v interface{}) (interface{}, bool) {
+	switch n := v.(type) {
+	case float64:
+		return -n, true
+	case json.Number:
+		s := string(n)
+		if _, ok := normalizeNumber(s); !ok {
+			return nil, false
+		}
+		if strings.HasPrefix(s, "-") {
+			return json.Number(s[1:]), true
+		}
+		return json.Number("-" + s), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,220 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPatch_Invert(t *testing.T) {
+	patch := Patch{
+		{Type: OperationTest, Path: "/a", Value: "1"},
+		{Type: OperationReplace, Path: "/a", Value: "3"},
+		{Type: OperationTest, Path: "/b", Value: "2"},
+		{Type: OperationRemove, Path: "/b"},
+		{Type: OperationAdd, Path: "/c/0", Value: "4"},
+		{Type: OperationMove, From: "/d/0", Path: "/d/2"},
+		{Type: OperationCopy, From: "/e", Path: "/f"},
+		{Type: OperationTest, Path: "/g", Value: true},
+		{Type: OperationIncrement, Path: "/h", Value: json.Number("-1.5")},
+	}
+	inv, err := patch.Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[` +
+		`{"value":1.5,"op":"increment","path":"/h"},` +
+		`{"value":true,"op":"test","path":"/g"},` +
+		`{"op":"remove","path":"/f"},` +
+		`{"op":"move","from":"/d/2","path":"/d/0"},` +
+		`{"value":"4","op":"test","path":"/c/0"},` +
+		`{"op":"remove","path":"/c/0"},` +
+		`{"value":"2","op":"add","path":"/b"},` +
+		`{"value":"3","op":"test","path":"/a"},` +
+		`{"value":"1","op":"replace","path":"/a"}` +
+		`]`
+	b, err := json.Marshal(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestPatch_Invert_coalescedTests(t *testing.T) {
+	src := map[string]interface{}{
+		"spec": map[string]interface{}{"a": "1", "b": "2", "c": "3"},
+	}
+	tgt := map[string]interface{}{
+		"spec": map[string]interface{}{"a": "4", "b": "5", "c": "3"},
+	}
+	patch, err := Compare(src, tgt, Invertible(), CoalesceTests())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 3 || patch[0].Type != OperationTest || patch[0].Path != "/spec" {
+		t.Fatalf("expected coalesced test of the parent object, got:\n%s", patch.String())
+	}
+	// The test of the parent fails if a member, even
+	// unchanged by the patch, doesn't match.
+	if _, err := patch.Apply([]byte(`{"spec":{"a":"1","b":"2","c":"0"}}`)); !errors.Is(err, ErrTestFailed) {
+		t.Errorf("got error %v, want %v", err, ErrTestFailed)
+	}
+	inv, err := patch.Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[` +
+		`{"value":"5","op":"test","path":"/spec/b"},` +
+		`{"value":"2","op":"replace","path":"/spec/b"},` +
+		`{"value":"4","op":"test","path":"/spec/a"},` +
+		`{"value":"1","op":"replace","path":"/spec/a"},` +
+		`{"value":{"a":"1","b":"2","c":"3"},"op":"test","path":"/spec"}` +
+		`]`
+	b, err := json.Marshal(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestPatch_Invert_coalescedTestsShifted(t *testing.T) {
+	// The insertion of the first element shifts the
+	// index of the object whose members are replaced.
+	src := `{"list":["p",{"a":1,"b":2,"c":3,"d":4},{"a":5,"b":6,"c":7,"d":8}]}`
+	tgt := `{"list":["q","p",{"a":10,"b":20,"c":30,"d":40},{"a":5,"b":6,"c":7,"d":8}]}`
+
+	patch, err := CompareJSON([]byte(src), []byte(tgt), LCS(), Invertible(), CoalesceTests())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		patch    func() (Patch, error)
+		doc, res string
+	}{
+		{func() (Patch, error) { return patch, nil }, src, tgt},
+		{patch.Invert, tgt, src},
+	} {
+		p, err := tc.patch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := p.Apply([]byte(tc.doc))
+		if err != nil {
+			t.Fatalf("failed to apply patch %s: %s", p, err)
+		}
+		var got, want interface{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tc.res), &want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("patch %s applied to %s: got %s, want %s", p, tc.doc, b, tc.res)
+		}
+	}
+}
+
+func TestPatch_Invert_errors(t *testing.T) {
+	for _, tc := range []struct {
+		patch Patch
+		err   string
+	}{
+		{
+			Patch{{Type: OperationReplace, Path: "/a", Value: 1}},
+			`operation #0 (replace "/a") cannot be inverted: no test operation precedes it`,
+		},
+		{
+			Patch{
+				{Type: OperationTest, Path: "/b", Value: 1},
+				{Type: OperationRemove, Path: "/a"},
+			},
+			`operation #1 (remove "/a") cannot be inverted: no test operation precedes it`,
+		},
+		{
+			Patch{{Type: OperationAdd, Path: "/a/-", Value: 1}},
+			`operation #0 (add "/a/-") cannot be inverted: the index of the appended element is unknown`,
+		},
+		{
+			Patch{{Type: OperationIncrement, Path: "/a", Value: "1"}},
+			`operation #0 (increment "/a") cannot be inverted: its value is not a number`,
+		},
+		{
+			Patch{{Type: "unknown", Path: "/a"}},
+			`operation #0 (unknown "/a") cannot be inverted: its type is unsupported`,
+		},
+	} {
+		_, err := tc.patch.Invert()
+		if err == nil {
+			t.Errorf("expected non-nil error for patch %s", tc.patch)
+			continue
+		}
+		if !strings.HasSuffix(err.Error(), tc.err) {
+			t.Errorf("got error %q, want %q", err, tc.err)
+		}
+	}
+}
+
+func TestPatch_Invert_roundTrip(t *testing.T) {
+	for _, opts := range [][]Option{
+		{Invertible()},
+		{Invertible(), Factorize()},
+		{Invertible(), Factorize(), Rationalize(), LCS()},
+		{Invertible(), WithResultChecksum()},
+		{Invertible(), CoalesceTests()},
+		{Invertible(), CoalesceTests(), LCS()},
+	} {
+		for _, f := range []string{
+			"testdata/tests/rfc.json",
+			"testdata/tests/array.json",
+			"testdata/tests/object.json",
+			"testdata/tests/root.json",
+			"testdata/tests/options/invertible.json",
+			"testdata/tests/options/coalesce-tests.json",
+			"testdata/tests/options/all.json",
+		} {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cases []testcase
+			if err := json.Unmarshal(b, &cases); err != nil {
+				t.Fatal(err)
+			}
+			for _, tc := range cases {
+				patch, err := Compare(tc.Before, tc.After, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				inv, err := patch.Invert()
+				if err != nil {
+					t.Errorf("%s: %s: %s\n%s", f, tc.Name, err, patch)
+					continue
+				}
+				tgt, err := json.Marshal(tc.After)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res, err := inv.Apply(tgt)
+				if err != nil {
+					t.Errorf("%s: %s: %s\n%s", f, tc.Name, err, inv)
+					continue
+				}
+				var got interface{}
+				if err := json.Unmarshal(res, &got); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, tc.Before) {
+					t.Errorf("%s: %s: got %s\n%s", f, tc.Name, res, inv)
+				}
+			}
+		}
+	}
+}
@@ -1,5 +1,12 @@
 package jsondiff
 
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
 // findKey finds and return the object value that match key.
 // It assumes to be on an opening curly bracket.
 // The function expects a compact JSON input.
@@ -160,3 +167,101 @@ func _compact(src, dst []byte) []byte {
 	}
 	return dst
 }
+
+// valueLength returns the length in bytes of the JSON
+// representation of the value, as it would be produced
+// by the json.Marshal function, without marshaling it.
+func valueLength(i interface{}) int {
+	switch v := i.(type) {
+	case nil:
+		return len("null")
+	case bool:
+		if v {
+			return len("true")
+		}
+		return len("false")
+	case string:
+		return stringLength(v)
+	case float64:
+		return floatLength(v)
+	case json.Number:
+		if v == "" {
+			return 1 // "0"
+		}
+		return len(v)
+	case []interface{}:
+		l := 2 + max(len(v)-1, 0)
+		for _, e := range v {
+			l += valueLength(e)
+		}
+		return l
+	case map[string]interface{}:
+		l := 2 + max(len(v)-1, 0)
+		for k, e := range v {
+			l += stringLength(k) + 1 + valueLength(e)
+		}
+		return l
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return 0
+		}
+		return len(b)
+	}
+}
+
+// stringLength returns the length of the quoted and
+// escaped JSON representation of the string, following
+// the rules of the encoding/json package (HTML-safe).
+func stringLength(s string) int {
+	l := 2
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\' || c == '\b' || c == '\f' || c == '\n' || c == '\r' || c == '\t':
+				l += 2
+			case c < ' ' || c == '<' || c == '>' || c == '&':
+				l += 6 // \u00XX
+			default:
+				l++
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			l += len("\ufffd") // replacement character
+		case r == '\u2028' || r == '\u2029':
+			l += 6
+		default:
+			l += size
+		}
+		i += size
+	}
+	return l
+}
+
+// floatLength returns the length of the JSON representation
+// of the number, following the encoding/json formatting.
+func floatLength(f float64) int {
+	var buf [32]byte
+
+	abs := math.Abs(f)
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		b := strconv.AppendFloat(buf[:0], f, 'e', -1, 64)
+		// Clean up e-09 to e-9.
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			return n - 1
+		}
+		return len(b)
+	}
+	return len(strconv.AppendFloat(buf[:0], f, 'f', -1, 64))
+}
+
+// intLength returns the length of the
+// decimal representation of the integer.
+func intLength(n int) int {
+	var buf [20]byte
+	return len(strconv.AppendInt(buf[:0], int64(n), 10))
+}
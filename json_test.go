@@ -156,3 +156,34 @@ func Test_compactInPlace(t *testing.T) {
 		t.Errorf("got %q, want %q", b, want)
 	}
 }
+
+func Test_valueLength(t *testing.T) {
+	for _, v := range []interface{}{
+		nil,
+		true,
+		false,
+		"",
+		"foo",
+		"\"quoted\" \\ \b\f\n\r\t \x01 <html> &    é \xff",
+		0.0,
+		-1.5,
+		1e21,
+		1e-7,
+		123456789.0,
+		1e20,
+		json.Number("12.50"),
+		[]interface{}{},
+		[]interface{}{"a", 1.0, nil},
+		map[string]interface{}{},
+		map[string]interface{}{"a": "b", "c<": []interface{}{true}},
+		[]string{"a", "b"},
+	} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l := valueLength(v); l != len(b) {
+			t.Errorf("got length %d, want %d for %s", l, len(b), b)
+		}
+	}
+}
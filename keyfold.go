@@ -0,0 +1,149 @@
+package jsondiff
+
+// foldKeys pairs the keys of the source and target objects
+// that differ only by the case of their ASCII letters, for
+// the CaseInsensitiveKeys option. The paired source keys are
+// removed from the comparison set, and their target keys are
+// marked as present in both objects, the source key being
+// renamed before their values are compared. It returns the source key
+// of each paired target key. The keys that share their folded
+// form with another key of the same object are ambiguous, and
+// are matched exactly. Their pointers are recorded, and are
+// returned by the method Differ.AmbiguousKeys.
+func (d *Differ) foldKeys(ptr pointer, src, tgt map[string]interface{}, cmpSet map[string]uint8) map[string]string {
+	aliases := pairFoldedKeys(src, tgt, func(sk, tk []string) {
+		d.ambiguousKeys(ptr, sk, tk)
+	})
+	for tk, sk := range aliases {
+		delete(cmpSet, sk)
+		cmpSet[tk] |= 1 << 0
+	}
+	return aliases
+}
+
+// pairFoldedKeys returns the source key of each key of the
+// target object that differs only by case from a key of the
+// source object, and that is not ambiguous. The ambiguous keys
+// of each folded form are passed to the function, if any, in
+// lexicographical order of their folded form.
+func pairFoldedKeys(src, tgt map[string]interface{}, ambiguous func(sk, tk []string)) map[string]string {
+	var (
+		sf = make(map[string][]string, len(src))
+		tf = make(map[string][]string, len(tgt))
+	)
+	for k := range src {
+		f := foldASCII(k)
+		sf[f] = append(sf[f], k)
+	}
+	for k := range tgt {
+		f := foldASCII(k)
+		tf[f] = append(tf[f], k)
+	}
+	folds := make([]string, 0, len(sf))
+	for f := range sf {
+		folds = append(folds, f)
+	}
+	sortStrings(folds)
+
+	var aliases map[string]string
+
+	for _, f := range folds {
+		sk, tk := sf[f], tf[f]
+		if tk == nil {
+			continue
+		}
+		if len(sk) != 1 || len(tk) != 1 {
+			if ambiguous != nil {
+				ambiguous(sk, tk)
+			}
+			continue
+		}
+		if sk[0] == tk[0] {
+			continue
+		}
+		if aliases == nil {
+			aliases = make(map[string]string)
+		}
+		aliases[tk[0]] = sk[0]
+	}
+	return aliases
+}
+
+// renameFoldedKey generates the move of the value of the
+// source key sk to the location of its paired target key, at
+// ptr, which precedes the operations of their comparison. The
+// value is copied instead if the options forbid its removal.
+func (d *Differ) renameFoldedKey(ptr pointer, sk string, v interface{}) {
+	from := pointer{buf: append([]byte(nil), ptr.buf[:ptr.sep]...)}
+	from.appendKey(sk)
+	if d.isIgnored(from) {
+		return
+	}
+	typ := OperationMove
+	if d.opts.noRemove || d.opts.partial {
+		typ = OperationCopy
+	}
+	d.emit(typ, from.string(), ptr.copy(), v, v, 0)
+}
+
+// renameMatchedKeys compares the elements of the target array
+// with the elements of the source array located at ptr matched
+// by perm, whose keys may differ by case, to rename their keys.
+// The elements are located at their index in the target array
+// if they have been moved to it, or in the source array otherwise.
+func (d *Differ) renameMatchedKeys(ptr pointer, src, tgt []interface{}, perm []int, moved bool, doc string) {
+	for j, i := range perm {
+		if deepEqual(src[i], tgt[j]) {
+			continue
+		}
+		if moved {
+			d.appendIndex(&ptr, j, len(src))
+		} else {
+			d.appendIndex(&ptr, i, len(src))
+		}
+		d.diff(ptr, src[i], tgt[j], doc)
+		ptr.rewind()
+	}
+}
+
+// ambiguousKeys records the pointers of the keys of the
+// source and target objects located at ptr, which share
+// the same folded form.
+func (d *Differ) ambiguousKeys(ptr pointer, src, tgt []string) {
+	keys := append(append(make([]string, 0, len(src)+len(tgt)), src...), tgt...)
+	sortStrings(keys)
+
+	for i, k := range keys {
+		if i > 0 && keys[i-1] == k {
+			continue
+		}
+		p := pointer{buf: append([]byte(nil), ptr.buf...)}
+		p.appendKey(k)
+		d.ambiguous = append(d.ambiguous, p.string())
+	}
+}
+
+// AmbiguousKeys returns the pointers of the object keys that
+// the CaseInsensitiveKeys option matched exactly, because they
+// differ only by case from another key of the same object.
+// The keys of each object are sorted in lexicographical order.
+func (d *Differ) AmbiguousKeys() []string {
+	return d.ambiguous
+}
+
+// foldASCII returns the string with its
+// ASCII letters converted to lower case.
+func foldASCII(s string) string {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; 'A' <= c && c <= 'Z' {
+			b := []byte(s)
+			for j := i; j < len(b); j++ {
+				if c := b[j]; 'A' <= c && c <= 'Z' {
+					b[j] = c + 'a' - 'A'
+				}
+			}
+			return string(b)
+		}
+	}
+	return s
+}
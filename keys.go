@@ -0,0 +1,134 @@
+package jsondiff
+
+// keyMatcher associates a pattern of pointers with the
+// name of the member that identifies the elements of the
+// arrays located at the matching pointers.
+type keyMatcher struct {
+	pattern pattern
+	key     string
+}
+
+// findKeyMember returns the name of the identity member of
+// the first pattern that matches the pointer.
+func findKeyMember(matchers []keyMatcher, ptr string) (string, bool) {
+	for i := range matchers {
+		if matchers[i].pattern.match(ptr) {
+			return matchers[i].key, true
+		}
+	}
+	return "", false
+}
+
+// compareByKey compares two arrays located at a pointer
+// that matches one of the patterns of the MatchByKey option,
+// by pairing their elements that have the same value for the
+// identity member. The elements of the source array that are
+// not paired are removed first, then the paired elements are
+// reordered with move operations if their order differs, and
+// the elements of the target array that are not paired are
+// added. Finally, the paired elements are compared to each
+// other, at their index in the target array. It returns false
+// if the elements are not all identified by a distinct value,
+// and the arrays must be compared normally.
+func (d *Differ) compareByKey(ptr pointer, src, tgt []interface{}, doc string) bool {
+	key, ok := findKeyMember(d.opts.keys, ptr.string())
+	if !ok {
+		return false
+	}
+	sk, ok := indexByMergeKey(src, key)
+	if !ok {
+		return false
+	}
+	tk, ok := indexByMergeKey(tgt, key)
+	if !ok {
+		return false
+	}
+	if d.opts.hasIgnore && (d.ignoresElem(ptr, len(src)) || d.ignoresElem(ptr, len(tgt))) {
+		// An ignored element would shift the
+		// indices of the following operations.
+		return false
+	}
+	if d.opts.equivalent && d.unorderedDeepEqualSlice(ptr, src, tgt) {
+		return true
+	}
+	ptr.snapshot()
+	d.snapshotPatchLen = len(d.patch)
+	defer d.restoreToken(d.token)
+
+	// The index in the target array of each element
+	// of the source array, or -1 if it's unpaired.
+	pairs := make([]int, len(src))
+	for i, v := range src {
+		pairs[i] = -1
+		if j, ok := tk[v.(map[string]interface{})[key]]; ok {
+			pairs[i] = j
+		}
+	}
+	// Remove the unpaired elements of the source array.
+	removed := 0
+	for i, j := range pairs {
+		if j != -1 {
+			continue
+		}
+		d.track(ptr.string(), i, -1)
+		d.appendIndex(&ptr, i-removed, len(src)-removed)
+		d.remove(ptr.copy(), src[i])
+		ptr.rewind()
+		removed++
+	}
+	// The remaining elements are the paired elements,
+	// in the order of the source array. Reorder them
+	// in the order of the target array.
+	var (
+		order  []int // target indices of the paired elements
+		source []int // source indices, in the same order
+		perm   []int // remaining indices, in the same order
+		values []interface{}
+		tokens = make([]int, len(tk))
+	)
+	rem := make(map[int]int, len(src)) // target index to remaining index
+	for _, j := range pairs {
+		if j != -1 {
+			rem[j] = len(rem)
+		}
+	}
+	for j, v := range tgt {
+		if i, ok := sk[v.(map[string]interface{})[key]]; ok {
+			order = append(order, j)
+			source = append(source, i)
+			perm = append(perm, rem[j])
+			values = append(values, src[i])
+		}
+	}
+	d.reorder(ptr, perm, values, func(n int) {
+		d.track(ptr.string(), source[n], order[n])
+		tokens[n] = d.token
+	})
+	// Add the unpaired elements of the target array,
+	// in order, such that all the elements that precede
+	// them in the target array are already present.
+	length := len(order)
+	for j, v := range tgt {
+		if _, ok := sk[v.(map[string]interface{})[key]]; ok {
+			continue
+		}
+		d.track(ptr.string(), -1, j)
+		d.appendIndex(&ptr, j, length)
+		d.add(ptr.copy(), v, doc, true)
+		ptr.rewind()
+		length++
+	}
+	// Compare the paired elements.
+	for n, j := range order {
+		i := source[n]
+		d.token = tokens[n]
+		d.appendIndex(&ptr, j, len(tgt))
+		if d.opts.rationalize {
+			d.diff(ptr, src[i], tgt[j], findIndex(doc, ptr.base.idx))
+		} else {
+			d.diff(ptr, src[i], tgt[j], doc)
+		}
+		ptr.rewind()
+	}
+	return true
+}
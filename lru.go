@@ -0,0 +1,68 @@
+package jsondiff
+
+import "container/list"
+
+// nodeCache is a least-recently-used cache of the
+// unchanged nodes of a document, indexed by the hash
+// of their value, which holds at most max entries.
+type nodeCache struct {
+	max   int
+	ll    *list.List
+	items map[uint64]*list.Element
+}
+
+type cacheEntry struct {
+	key  uint64
+	node jsonNode
+}
+
+func newNodeCache(max int) *nodeCache {
+	return &nodeCache{
+		max:   max,
+		ll:    list.New(),
+		items: make(map[uint64]*list.Element, max),
+	}
+}
+
+// add inserts or updates the node associated with
+// the key, and evicts the least recently used entry
+// if the cache exceeds its capacity.
+func (c *nodeCache) add(key uint64, node jsonNode) {
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*cacheEntry).node = node
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, node: node})
+
+	if c.ll.Len() > c.max {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*cacheEntry).key)
+	}
+}
+
+// get returns the node associated with the key,
+// and marks the entry as recently used.
+func (c *nodeCache) get(key uint64) (jsonNode, bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return jsonNode{}, false
+	}
+	c.ll.MoveToFront(e)
+
+	return e.Value.(*cacheEntry).node, true
+}
+
+// len returns the number of entries of the cache.
+func (c *nodeCache) len() int {
+	return c.ll.Len()
+}
+
+// reset removes all the entries of the cache.
+func (c *nodeCache) reset() {
+	c.ll.Init()
+	for k := range c.items {
+		delete(c.items, k)
+	}
+}
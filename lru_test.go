@@ -0,0 +1,38 @@
+package jsondiff
+
+import "testing"
+
+func Test_nodeCache(t *testing.T) {
+	c := newNodeCache(2)
+
+	c.add(1, jsonNode{ptr: "/a"})
+	c.add(2, jsonNode{ptr: "/b"})
+	if _, ok := c.get(1); !ok {
+		t.Fatal("expected key 1 to be cached")
+	}
+	// Key 2 is the least recently used.
+	c.add(3, jsonNode{ptr: "/c"})
+
+	if _, ok := c.get(2); ok {
+		t.Error("expected key 2 to be evicted")
+	}
+	for k, want := range map[uint64]string{1: "/a", 3: "/c"} {
+		if n, ok := c.get(k); !ok || n.ptr != want {
+			t.Errorf("key %d: got (%q, %t), want %q", k, n.ptr, ok, want)
+		}
+	}
+	c.add(3, jsonNode{ptr: "/d"})
+	if n, _ := c.get(3); n.ptr != "/d" {
+		t.Errorf("expected updated node, got %q", n.ptr)
+	}
+	if c.len() != 2 {
+		t.Errorf("got %d entries, want 2", c.len())
+	}
+	c.reset()
+	if c.len() != 0 {
+		t.Errorf("got %d entries after reset, want 0", c.len())
+	}
+	if _, ok := c.get(1); ok {
+		t.Error("expected empty cache after reset")
+	}
+}
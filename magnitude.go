@@ -0,0 +1,76 @@
+package jsondiff
+
+import "strconv"
+
+// Magnitude represents the importance of a change,
+// based on the size of the value it operates on.
+type Magnitude uint
+
+// Magnitudes of changes, from the smallest to the largest.
+const (
+	MagnitudeTrivial Magnitude = iota
+	MagnitudeModerate
+	MagnitudeMajor
+)
+
+var magnitudeNames = []string{
+	MagnitudeTrivial:  "trivial",
+	MagnitudeModerate: "moderate",
+	MagnitudeMajor:    "major",
+}
+
+// String implements the fmt.Stringer interface.
+func (m Magnitude) String() string {
+	if uint(m) < uint(len(magnitudeNames)) {
+		return magnitudeNames[m]
+	}
+	return "magnitude" + strconv.Itoa(int(m))
+}
+
+// MagnitudeThresholds defines the minimum size in bytes
+// of the JSON representation of the value of an operation
+// for it to be classified in the moderate and major
+// magnitude buckets. Smaller operations are trivial.
+type MagnitudeThresholds struct {
+	Moderate int
+	Major    int
+}
+
+// DefaultMagnitudeThresholds are the thresholds used
+// by the Patch.ByMagnitude method.
+var DefaultMagnitudeThresholds = MagnitudeThresholds{
+	Moderate: 64,
+	Major:    1024,
+}
+
+// ByMagnitude groups the operations of the patch by the
+// magnitude of the changes, using the default thresholds.
+func (p Patch) ByMagnitude() map[Magnitude][]Operation {
+	return p.ByMagnitudeThresholds(DefaultMagnitudeThresholds)
+}
+
+// ByMagnitudeThresholds groups the operations of the patch
+// by the magnitude of the changes, using the given thresholds.
+// The magnitude of an operation is determined by the size of
+// the value it adds, or removes for remove operations.
+// The relative order of the operations is preserved.
+func (p Patch) ByMagnitudeThresholds(t MagnitudeThresholds) map[Magnitude][]Operation {
+	m := make(map[Magnitude][]Operation)
+
+	for _, op := range p {
+		var (
+			l   = op.valueSize()
+			mag Magnitude
+		)
+		switch {
+		case l >= t.Major:
+			mag = MagnitudeMajor
+		case l >= t.Moderate:
+			mag = MagnitudeModerate
+		default:
+			mag = MagnitudeTrivial
+		}
+		m[mag] = append(m[mag], op)
+	}
+	return m
+}
@@ -0,0 +1,71 @@
+package jsondiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatch_ByMagnitude(t *testing.T) {
+	large := map[string]interface{}{
+		"a": strings.Repeat("x", 600),
+		"b": strings.Repeat("y", 600),
+	}
+	patch := Patch{
+		{Type: OperationReplace, Path: "/a", Value: 42.0},
+		{Type: OperationAdd, Path: "/b", Value: map[string]interface{}{"text": strings.Repeat("z", 100)}},
+		{Type: OperationRemove, Path: "/c", OldValue: large},
+		{Type: OperationRemove, Path: "/d"},
+		{Type: OperationAdd, Path: "/e", Value: large},
+	}
+	for _, tc := range []struct {
+		thresholds MagnitudeThresholds
+		want       map[Magnitude][]string
+	}{
+		{
+			DefaultMagnitudeThresholds,
+			map[Magnitude][]string{
+				MagnitudeTrivial:  {"/a", "/d"},
+				MagnitudeModerate: {"/b"},
+				MagnitudeMajor:    {"/c", "/e"},
+			},
+		},
+		{
+			MagnitudeThresholds{Moderate: 1, Major: 10},
+			map[Magnitude][]string{
+				MagnitudeModerate: {"/a", "/d"},
+				MagnitudeMajor:    {"/b", "/c", "/e"},
+			},
+		},
+	} {
+		m := patch.ByMagnitudeThresholds(tc.thresholds)
+		if len(m) != len(tc.want) {
+			t.Errorf("got %d magnitudes, want %d", len(m), len(tc.want))
+		}
+		for mag, paths := range tc.want {
+			ops := m[mag]
+			if len(ops) != len(paths) {
+				t.Errorf("%s: got %d operations, want %d", mag, len(ops), len(paths))
+				continue
+			}
+			for i, op := range ops {
+				if op.Path != paths[i] {
+					t.Errorf("%s: op #%d: got path %q, want %q", mag, i, op.Path, paths[i])
+				}
+			}
+		}
+	}
+	if m := patch.ByMagnitude(); len(m[MagnitudeMajor]) != 2 {
+		t.Errorf("expected 2 major operations")
+	}
+}
+
+func TestMagnitude_String(t *testing.T) {
+	for m, name := range magnitudeNames {
+		if s := Magnitude(m).String(); s != name {
+			t.Errorf("got %q, want %q", s, name)
+		}
+	}
+	if s := Magnitude(42).String(); s != "magnitude42" {
+		t.Errorf("got %q, want %q", s, "magnitude42")
+	}
+}
@@ -0,0 +1,265 @@
+package jsondiff
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Conflict represents a pair of operations from two
+// concurrent patches that cannot be applied together.
+type Conflict struct {
+	// Path is the location at which the
+	// operations overlap.
+	Path string
+
+	// Mine and Theirs are the conflicting
+	// operations of each patch.
+	Mine   Operation
+	Theirs Operation
+}
+
+// ThreeWayMerge computes the changes made to the base
+// value by mine and theirs, and returns a patch, relative
+// to the base value, that applies both sets of changes,
+// except those that overlap incompatibly, which are instead
+// reported as a list of conflicts and omitted from the patch.
+// The values of both sides are found in the operations of
+// each conflict. The options apply to both comparisons, such
+// that the LCS option, for example, locates the insertions and
+// removals of array elements more precisely, and reduces the
+// number of conflicts between the changes of the same array.
+func ThreeWayMerge(base, mine, theirs interface{}, opts ...Option) (merged Patch, conflicts []Conflict, err error) {
+	pm, err := Compare(base, mine, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	pt, err := Compare(base, theirs, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged, conflicts = mergePatches(pm, pt)
+
+	return merged, conflicts, nil
+}
+
+// mergePatches combines two patches relative to the same
+// document and returns the operations that can be applied
+// together, followed by the conflicts between the others.
+// Operations present in both patches are kept only once.
+func mergePatches(mine, theirs Patch) (Patch, []Conflict) {
+	conflicts, cm, ct := findConflicts(mine, theirs)
+
+	merged := make(Patch, 0, len(mine)+len(theirs))
+	for i, op := range mine {
+		if !cm[i] {
+			merged = append(merged, op)
+		}
+	}
+	kept := merged
+L:
+	for i, op := range theirs {
+		if ct[i] {
+			continue
+		}
+		for _, k := range kept {
+			if sameOperation(op, k) {
+				continue L
+			}
+		}
+		merged = append(merged, op)
+	}
+	return merged, conflicts
+}
+
+// ConflictsWith returns the conflicts between the operations
+// of the patch and those of another patch generated against
+// the same document, such as on two replicas, which cannot be
+// applied together. The patches are analyzed statically, and
+// the conflicts are those reported by ThreeWayMerge, such as
+// two different replacements of the same value, or the removal
+// of a subtree edited by the other patch, the "from" and "path"
+// locations of the move and copy operations being both touched,
+// as well as the array elements whose indices a move shifts.
+// The Mine and Theirs fields of each conflict hold the operations
+// of the patch and of the other patch respectively.
+func (p Patch) ConflictsWith(other Patch) []Conflict {
+	conflicts, _, _ := findConflicts(p, other)
+	return conflicts
+}
+
+// findConflicts returns the conflicts between the operations
+// of the given patches, and the set of conflicting operations
+// of each patch, indexed by position.
+func findConflicts(mine, theirs Patch) ([]Conflict, []bool, []bool) {
+	var (
+		conflicts []Conflict
+		cm        = make([]bool, len(mine))
+		ct        = make([]bool, len(theirs))
+	)
+	for i, a := range mine {
+		for j, b := range theirs {
+			if p, ok := operationsConflict(a, b); ok {
+				conflicts = append(conflicts, Conflict{
+					Path:   p,
+					Mine:   a,
+					Theirs: b,
+				})
+				cm[i], ct[j] = true, true
+			}
+		}
+	}
+	return conflicts, cm, ct
+}
+
+// operationsConflict returns whether the given operations
+// cannot be applied together, and the location at which
+// they overlap. Two operations conflict if one of the
+// locations they touch is equal to, or is a child of, one
+// of the locations touched by the other, unless they are
+// identical. The insertion or removal of an array element
+// also conflicts with the operations that touch the elements
+// whose indices it shifts, and with the other insertions and
+// removals in the same array, the move of an array element
+// being the removal of its from location.
+func operationsConflict(a, b Operation) (string, bool) {
+	if sameOperation(a, b) {
+		return "", false
+	}
+	for _, ea := range arrayElements(a) {
+		for _, eb := range arrayElements(b) {
+			if ea.parent == eb.parent {
+				return ea.parent, true
+			}
+		}
+	}
+	for _, la := range touchedLocations(a) {
+		for _, lb := range touchedLocations(b) {
+			switch {
+			case isPointerPrefix(la, lb):
+				return la, true
+			case isPointerPrefix(lb, la):
+				return lb, true
+			}
+		}
+	}
+	if p, ok := shiftsIndices(a, b); ok {
+		return p, true
+	}
+	return shiftsIndices(b, a)
+}
+
+// shiftsIndices returns whether the operation a inserts or
+// removes an array element that conflicts with operation b.
+func shiftsIndices(a, b Operation) (string, bool) {
+	for _, e := range arrayElements(a) {
+		for _, l := range touchedLocations(b) {
+			if !strings.HasPrefix(l, e.parent+"/") {
+				continue
+			}
+			seg := l[len(e.parent)+1:]
+			if i := strings.IndexByte(seg, separator); i != -1 {
+				seg = seg[:i]
+			}
+			if n, ok := parseIndex(seg); ok && n >= e.index {
+				return e.parent, true
+			}
+		}
+	}
+	return "", false
+}
+
+// arrayElement is the location of an
+// array and the index of one of its elements.
+type arrayElement struct {
+	parent string
+	index  int
+}
+
+// arrayElements returns the array elements that the operation
+// inserts or removes, the from location of a move being removed
+// from its array. Since the document is unknown, a numeric last
+// segment is assumed to be an index.
+func arrayElements(op Operation) []arrayElement {
+	var elems []arrayElement
+
+	switch op.Type {
+	case OperationMove:
+		if e, ok := elementOf(op.From); ok {
+			elems = append(elems, e)
+		}
+	case OperationAdd, OperationRemove, OperationCopy:
+	default:
+		return nil
+	}
+	if e, ok := elementOf(op.Path); ok {
+		elems = append(elems, e)
+	}
+	return elems
+}
+
+// elementOf returns the array element referenced
+// by the pointer, if its last segment is an index.
+func elementOf(ptr string) (arrayElement, bool) {
+	i := strings.LastIndexByte(ptr, separator)
+	if i == -1 {
+		return arrayElement{}, false
+	}
+	seg := ptr[i+1:]
+	if seg == "-" {
+		// Appending to an array doesn't shift
+		// the indices of the existing elements.
+		return arrayElement{ptr[:i], math.MaxInt}, true
+	}
+	n, ok := parseIndex(seg)
+	if !ok {
+		return arrayElement{}, false
+	}
+	return arrayElement{ptr[:i], n}, true
+}
+
+// touchedLocations returns the locations read
+// or written by the application of the operation.
+func touchedLocations(op Operation) []string {
+	switch op.Type {
+	case OperationMove, OperationCopy:
+		return []string{op.From, op.Path}
+	default:
+		return []string{op.Path}
+	}
+}
+
+// isPointerPrefix returns whether the pointer p
+// is equal to, or an ancestor of, the pointer q.
+func isPointerPrefix(p, q string) bool {
+	if p == emptyPointer || p == q {
+		return true
+	}
+	return strings.HasPrefix(q, p) && q[len(p)] == separator
+}
+
+// parseIndex parses an array index
+// from a pointer reference token.
+func parseIndex(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	for _, c := range []byte(s) {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// sameOperation returns whether both
+// operations have the same effect.
+func sameOperation(a, b Operation) bool {
+	return a.Type == b.Type && a.Path == b.Path && a.From == b.From &&
+		reflect.DeepEqual(a.Value, b.Value)
+}
@@ -0,0 +1,239 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestThreeWayMerge(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		base      string
+		mine      string
+		theirs    string
+		merged    string
+		conflicts []string
+		opts      []Option
+	}{
+		{
+			name:   "disjoint keys",
+			base:   `{"a":1,"b":2,"c":3}`,
+			mine:   `{"a":10,"b":2,"c":3}`,
+			theirs: `{"a":1,"b":2,"d":4}`,
+			merged: `{"a":10,"b":2,"d":4}`,
+		},
+		{
+			name:   "identical changes",
+			base:   `{"a":1,"b":[1,2,3]}`,
+			mine:   `{"a":2,"b":[1]}`,
+			theirs: `{"a":2,"b":[1]}`,
+			merged: `{"a":2,"b":[1]}`,
+		},
+		{
+			name:      "concurrent replacements",
+			base:      `{"a":1,"b":1}`,
+			mine:      `{"a":2,"b":1}`,
+			theirs:    `{"a":3,"b":2}`,
+			merged:    `{"a":1,"b":2}`,
+			conflicts: []string{"/a"},
+		},
+		{
+			name:      "removal of an edited subtree",
+			base:      `{"a":{"b":1,"c":1},"d":0}`,
+			mine:      `{"d":1}`,
+			theirs:    `{"a":{"b":2,"c":1},"d":0}`,
+			merged:    `{"a":{"b":1,"c":1},"d":1}`,
+			conflicts: []string{"/a"},
+		},
+		{
+			name:   "nested edits",
+			base:   `{"a":{"b":1,"c":[1,2]}}`,
+			mine:   `{"a":{"b":2,"c":[1,2]}}`,
+			theirs: `{"a":{"b":1,"c":[1,3,4]}}`,
+			merged: `{"a":{"b":2,"c":[1,3,4]}}`,
+		},
+		{
+			name:   "array replacement and tail removal",
+			base:   `[1,2,3,4]`,
+			mine:   `[0,2,3,4]`,
+			theirs: `[1,2]`,
+			merged: `[0,2]`,
+		},
+		{
+			name:      "concurrent appends",
+			base:      `{"a":[1]}`,
+			mine:      `{"a":[1,2]}`,
+			theirs:    `{"a":[1,3]}`,
+			merged:    `{"a":[1]}`,
+			conflicts: []string{"/a"},
+		},
+		{
+			name:      "removal of an edited element",
+			base:      `[1,2,3]`,
+			mine:      `[1,2]`,
+			theirs:    `[1,2,4]`,
+			merged:    `[1,2,3]`,
+			conflicts: []string{"/2"},
+		},
+		{
+			name:      "root type change",
+			base:      `{"a":1}`,
+			mine:      `[1]`,
+			theirs:    `{"a":2}`,
+			merged:    `{"a":1}`,
+			conflicts: []string{""},
+		},
+		{
+			name:   "ignored concurrent replacements",
+			base:   `{"version":1,"a":1}`,
+			mine:   `{"version":2,"a":2}`,
+			theirs: `{"version":3,"a":1}`,
+			merged: `{"version":1,"a":2}`,
+			opts:   []Option{Ignores("/version")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var base, mine, theirs interface{}
+			for _, v := range []struct {
+				s string
+				i *interface{}
+			}{{tc.base, &base}, {tc.mine, &mine}, {tc.theirs, &theirs}} {
+				if err := json.Unmarshal([]byte(v.s), v.i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			merged, conflicts, err := ThreeWayMerge(base, mine, theirs, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("\n%s", merged)
+
+			var paths []string
+			for _, c := range conflicts {
+				paths = append(paths, c.Path)
+			}
+			if !reflect.DeepEqual(paths, tc.conflicts) {
+				t.Errorf("conflicts mismatch: got %q, want %q", paths, tc.conflicts)
+			}
+			b, err := merged.apply([]byte(tc.base), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.merged), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("merged document mismatch: got %s, want %s", b, tc.merged)
+			}
+		})
+	}
+}
+
+func TestThreeWayMerge_error(t *testing.T) {
+	if _, _, err := ThreeWayMerge(nil, func() {}, nil); err == nil {
+		t.Error("expected non-nil error")
+	}
+	if _, _, err := ThreeWayMerge(nil, nil, func() {}); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+func TestPatch_ConflictsWith(t *testing.T) {
+	p := Patch{
+		{Type: OperationReplace, Path: "/a", Value: 1.0},
+		{Type: OperationRemove, Path: "/b"},
+		{Type: OperationMove, From: "/c", Path: "/d"},
+		{Type: OperationAdd, Path: "/e", Value: "x"},
+	}
+	other := Patch{
+		{Type: OperationReplace, Path: "/a", Value: 2.0},
+		{Type: OperationReplace, Path: "/b/0/name", Value: "y"},
+		{Type: OperationAdd, Path: "/c/z", Value: true},
+		{Type: OperationAdd, Path: "/e", Value: "x"},
+		{Type: OperationReplace, Path: "/f", Value: nil},
+	}
+	var got [][3]string
+	for _, c := range p.ConflictsWith(other) {
+		got = append(got, [3]string{c.Path, c.Mine.Path, c.Theirs.Path})
+	}
+	want := [][3]string{
+		{"/a", "/a", "/a"},
+		{"/b", "/b", "/b/0/name"},
+		{"/c", "/d", "/c/z"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("conflicts mismatch: got %q, want %q", got, want)
+	}
+	// The move of an array element shifts the indices
+	// of the following elements.
+	moved := Patch{{Type: OperationMove, From: "/arr/0", Path: "/k"}}
+	replaced := Patch{{Type: OperationReplace, Path: "/arr/1", Value: 1.0}}
+	for _, c := range [][]Conflict{moved.ConflictsWith(replaced), replaced.ConflictsWith(moved)} {
+		if len(c) != 1 || c[0].Path != "/arr" {
+			t.Errorf("got conflicts %+v, want one conflict at /arr", c)
+		}
+	}
+	if c := p.ConflictsWith(p); len(c) != 0 {
+		t.Errorf("expected no conflicts with itself, got %d", len(c))
+	}
+	if c := p.ConflictsWith(nil); len(c) != 0 {
+		t.Errorf("expected no conflicts with empty patch, got %d", len(c))
+	}
+}
+
+func Test_operationsConflict(t *testing.T) {
+	for _, tc := range []struct {
+		a, b Operation
+		path string
+		ok   bool
+	}{
+		{
+			Operation{Type: OperationMove, From: "/arr/0", Path: "/k"},
+			Operation{Type: OperationReplace, Path: "/arr/1", Value: 1.0},
+			"/arr", true,
+		},
+		{
+			Operation{Type: OperationMove, From: "/arr/2", Path: "/k"},
+			Operation{Type: OperationReplace, Path: "/arr/1", Value: 1.0},
+			"", false,
+		},
+		{
+			Operation{Type: OperationMove, From: "/arr/0", Path: "/k"},
+			Operation{Type: OperationRemove, Path: "/arr/3"},
+			"/arr", true,
+		},
+		{
+			Operation{Type: OperationCopy, From: "/arr/0", Path: "/k"},
+			Operation{Type: OperationReplace, Path: "/arr/1", Value: 1.0},
+			"", false,
+		},
+	} {
+		for _, ops := range [][2]Operation{{tc.a, tc.b}, {tc.b, tc.a}} {
+			if p, ok := operationsConflict(ops[0], ops[1]); p != tc.path || ok != tc.ok {
+				t.Errorf("operationsConflict(%s, %s): got (%q, %t), want (%q, %t)", ops[0], ops[1], p, ok, tc.path, tc.ok)
+			}
+		}
+	}
+}
+
+func Test_isPointerPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		p, q string
+		want bool
+	}{
+		{"", "/a", true},
+		{"/a", "/a", true},
+		{"/a", "/a/b", true},
+		{"/a", "/ab", false},
+		{"/a/b", "/a", false},
+	} {
+		if got := isPointerPrefix(tc.p, tc.q); got != tc.want {
+			t.Errorf("isPointerPrefix(%q, %q): got %t, want %t", tc.p, tc.q, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// mergeObject is an object of a merge patch, as opposed
+// to an object value of the target document, which is
+// merged as a whole.
+type mergeObject map[string]interface{}
+
+// CompareMerge compares the JSON representations of the
+// given values and returns the differences as a JSON Merge
+// Patch (RFC 7386) document, instead of a list of JSON Patch
+// operations.
+//
+// The patch is derived from the operations generated for
+// the given options, such that the locations ignored with
+// the Ignores option are not part of it, for example. The
+// members of an object removed from the target are set to
+// null, and the members added or changed are set to their
+// new value, while the unchanged members are omitted. Since
+// the format cannot express the changes of the elements of
+// an array, a modified array is replaced as a whole by the
+// target array. Likewise, a member whose value is null in
+// the target is set to null, which is indistinguishable
+// from its removal: applying the patch removes the member.
+// If the source and target values aren't both objects and
+// differ, the patch is the target value itself. Since the
+// empty object, which leaves an object unchanged, replaces a
+// value that isn't an object, and null replaces any value,
+// the patch of a source value that isn't an object and has
+// no differences with the target is nil.
+func CompareMerge(source, target interface{}, opts ...Option) ([]byte, error) {
+	var d Differ
+	d.applyOpts(opts...)
+	d.opts.setDefaultCodec()
+
+	src, _, err := marshalUnmarshal(source, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	tgt, tb, err := marshalUnmarshal(target, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	d.targetBytes = tb
+
+	d.Compare(src, tgt)
+	if d.err != nil {
+		return nil, d.err
+	}
+	patch, whole := mergePatch(d.patch, src, tgt)
+	if whole {
+		return d.opts.marshal(tgt)
+	}
+	if len(patch) == 0 && !isObject(src) {
+		return nil, nil
+	}
+	return d.opts.marshal(patch)
+}
+
+// mergePatch returns the merge patch that corresponds to
+// the locations modified by the operations of the patch.
+// It returns true if the patch is the target value itself.
+func mergePatch(p Patch, src, tgt interface{}) (mergeObject, bool) {
+	patch := make(mergeObject)
+
+	for _, op := range p {
+		switch op.Type {
+		case OperationTest, OperationChecksum:
+			continue
+		case OperationMove:
+			if mergeLocation(patch, op.From, src, tgt) {
+				return nil, true
+			}
+		}
+		if mergeLocation(patch, op.Path, src, tgt) {
+			return nil, true
+		}
+	}
+	return patch, false
+}
+
+// mergeLocation marks the location of the given pointer
+// as modified in the merge patch. The members of the objects
+// of both documents are merged recursively, and the first
+// value that isn't an object in either of them is set to its
+// value in the target document, or null if it was removed.
+// It returns true if the values of the root document aren't
+// both objects, and the patch must be the target value.
+func mergeLocation(patch mergeObject, ptr string, src, tgt interface{}) bool {
+	tokens, err := decodePointer(ptr)
+	if err != nil {
+		return false
+	}
+	for _, tok := range tokens {
+		so, ok := src.(map[string]interface{})
+		if !ok {
+			return true
+		}
+		to, ok := tgt.(map[string]interface{})
+		if !ok {
+			return true
+		}
+		tv, ok := to[tok]
+		if !ok {
+			patch[tok] = nil
+			return false
+		}
+		sv, ok := so[tok]
+		if !ok || !isObject(sv) || !isObject(tv) {
+			patch[tok] = tv
+			return false
+		}
+		m, ok := patch[tok].(mergeObject)
+		if !ok {
+			m = make(mergeObject)
+			patch[tok] = m
+		}
+		patch, src, tgt = m, sv, tv
+	}
+	// The operation replaces an object as a whole,
+	// such as with the Rationalize option, whose
+	// members must be merged individually.
+	so, ok := src.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	to, ok := tgt.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	mergeObjects(patch, so, to)
+
+	return false
+}
+
+// mergeObjects merges the differences between
+// two objects in the given merge patch object.
+func mergeObjects(patch mergeObject, src, tgt map[string]interface{}) {
+	for k := range src {
+		if _, ok := tgt[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	for k, tv := range tgt {
+		sv, ok := src[k]
+		if ok && deepEqual(sv, tv) {
+			continue
+		}
+		so, sobj := sv.(map[string]interface{})
+		to, tobj := tv.(map[string]interface{})
+		if !sobj || !tobj {
+			patch[k] = tv
+			continue
+		}
+		m, ok := patch[k].(mergeObject)
+		if !ok {
+			m = make(mergeObject)
+			patch[k] = m
+		}
+		mergeObjects(m, so, to)
+	}
+}
+
+func isObject(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
+
+// ToMergePatch converts the patch to a JSON Merge Patch
+// (RFC 7386) document. The add and replace operations of an
+// object member set the member to their value, and the remove
+// operations set it to null, the later operations prevailing.
+//
+// The conversion is best-effort, and returns an error if the
+// patch contains an operation that the format cannot express,
+// such as a move, copy or test operation, one whose path
+// refers to an array element, or one that sets a member to
+// null, which would remove it. Since the patch doesn't carry
+// the document it applies to, a reference token that is an
+// integer, or "-", is assumed to refer to an array element.
+// Note that the value of an operation that is an object is
+// merged with the existing value of the member, if any,
+// and that its null members are dropped, when the merge
+// patch is applied. An empty patch is converted to an empty
+// object, which leaves an object unchanged, but replaces a
+// document that isn't an object.
+func (p Patch) ToMergePatch() ([]byte, error) {
+	var patch interface{} = make(mergeObject)
+
+	for i, op := range p {
+		var v interface{}
+		switch op.Type {
+		case OperationAdd, OperationReplace:
+			v = op.Value
+		case OperationRemove:
+		default:
+			return nil, mergeError(i, op, "its type is unsupported")
+		}
+		tokens, err := decodePointer(op.Path)
+		if err != nil {
+			return nil, mergeError(i, op, err.Error())
+		}
+		if v == nil && op.Type != OperationRemove && len(tokens) != 0 {
+			// A null member removes the member.
+			return nil, mergeError(i, op, "a null value cannot be expressed")
+		}
+		if len(tokens) == 0 {
+			// A merge patch that isn't an object replaces
+			// the document, and cannot be followed by the
+			// changes of other operations.
+			if op.Type == OperationRemove || isObject(v) || len(p) != 1 {
+				return nil, mergeError(i, op, "the root document cannot be replaced")
+			}
+			patch = v
+			break
+		}
+		m := patch.(mergeObject)
+		for n, tok := range tokens {
+			if isArrayToken(tok) {
+				return nil, mergeError(i, op, "its path refers to an array element")
+			}
+			if n == len(tokens)-1 {
+				m[tok] = v
+				break
+			}
+			switch c := m[tok].(type) {
+			case mergeObject:
+				m = c
+			case map[string]interface{}:
+				// The value of a previous operation is
+				// modified, and is copied to leave the
+				// operation unchanged.
+				o := make(mergeObject, len(c))
+				for k, v := range c {
+					o[k] = v
+				}
+				m[tok] = o
+				m = o
+			default:
+				if _, ok := m[tok]; ok {
+					return nil, mergeError(i, op, "its parent is not an object")
+				}
+				o := make(mergeObject)
+				m[tok] = o
+				m = o
+			}
+		}
+	}
+	return json.Marshal(patch)
+}
+
+func mergeError(i int, op Operation, reason string) error {
+	return fmt.Errorf("jsondiff: operation #%d (%s %q) cannot be converted to a merge patch: %s", i, op.Type, op.Path, reason)
+}
@@ -0,0 +1,292 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCompareMerge(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		src    string
+		tgt    string
+		opts   []Option
+		expect string
+	}{
+		{
+			name:   "identical",
+			src:    `{"a":1,"b":[1,2]}`,
+			tgt:    `{"a":1,"b":[1,2]}`,
+			expect: `{}`,
+		},
+		{
+			name:   "object members",
+			src:    `{"a":1,"b":{"c":"x","d":"y","e":{"f":true}},"g":2}`,
+			tgt:    `{"a":1,"b":{"c":"z","e":{"f":true},"h":[1]},"g":2}`,
+			expect: `{"b":{"c":"z","d":null,"h":[1]}}`,
+		},
+		{
+			name:   "array replaced as a whole",
+			src:    `{"a":{"b":[1,2,{"c":3}]}}`,
+			tgt:    `{"a":{"b":[1,{"c":4}]}}`,
+			expect: `{"a":{"b":[1,{"c":4}]}}`,
+		},
+		{
+			name:   "type change",
+			src:    `{"a":{"b":1},"c":[1]}`,
+			tgt:    `{"a":[1],"c":{"d":1}}`,
+			expect: `{"a":[1],"c":{"d":1}}`,
+		},
+		{
+			name:   "escaped keys",
+			src:    `{"a/b":{"c~d":1,"e":1}}`,
+			tgt:    `{"a/b":{"e":1}}`,
+			expect: `{"a/b":{"c~d":null}}`,
+		},
+		{
+			name:   "null target value",
+			src:    `{"a":1,"b":2}`,
+			tgt:    `{"a":null,"b":2}`,
+			expect: `{"a":null}`,
+		},
+		{
+			name:   "root array",
+			src:    `[1,2]`,
+			tgt:    `[2]`,
+			expect: `[2]`,
+		},
+		{
+			name:   "root scalar",
+			src:    `{"a":1}`,
+			tgt:    `"a"`,
+			expect: `"a"`,
+		},
+		{
+			name:   "ignored member",
+			src:    `{"a":1,"b":{"c":1,"d":1}}`,
+			tgt:    `{"a":2,"b":{"c":2,"d":2}}`,
+			opts:   []Option{Ignores("/b/c")},
+			expect: `{"a":2,"b":{"d":2}}`,
+		},
+		{
+			name:   "factorized move",
+			src:    `{"a":{"b":"long value"},"c":{}}`,
+			tgt:    `{"a":{},"c":{"d":"long value"}}`,
+			opts:   []Option{Factorize()},
+			expect: `{"a":{"b":null},"c":{"d":"long value"}}`,
+		},
+		{
+			name:   "rationalized object",
+			src:    `{"a":{"b":1,"c":2,"d":{"e":1,"f":2}}}`,
+			tgt:    `{"a":{"b":3,"c":4,"d":{"e":1}}}`,
+			opts:   []Option{Rationalize()},
+			expect: `{"a":{"b":3,"c":4,"d":{"f":null}}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var src, tgt, want, got interface{}
+			for _, v := range []struct {
+				s string
+				i *interface{}
+			}{{tc.src, &src}, {tc.tgt, &tgt}, {tc.expect, &want}} {
+				if err := json.Unmarshal([]byte(v.s), v.i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := CompareMerge(src, tgt, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("patch mismatch:\ngot:  %s\nwant: %s", b, tc.expect)
+			}
+			if len(tc.opts) == 0 && tc.name != "null target value" {
+				// Apply the patch as specified by the
+				// section 2 of RFC 7386.
+				if v := applyMergePatch(src, got); !reflect.DeepEqual(v, tgt) {
+					t.Errorf("patched document mismatch:\ngot:  %v\nwant: %v", v, tgt)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareMerge_unchangedRoot(t *testing.T) {
+	for _, v := range []interface{}{nil, 1.0, "a", []interface{}{1.0}} {
+		b, err := CompareMerge(v, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b != nil {
+			t.Errorf("got patch %s for unchanged value %v, want nil", b, v)
+		}
+	}
+	b, err := CompareMerge(map[string]interface{}{}, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{}` {
+		t.Errorf("got patch %s for unchanged object, want {}", b)
+	}
+}
+
+func TestCompareMerge_error(t *testing.T) {
+	if _, err := CompareMerge(func() {}, nil); err == nil {
+		t.Error("expected non-nil error")
+	}
+	if _, err := CompareMerge(nil, func() {}); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+func applyMergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = applyMergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+func TestPatch_ToMergePatch(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		patch  Patch
+		expect string
+	}{
+		{
+			name:   "empty",
+			patch:  nil,
+			expect: `{}`,
+		},
+		{
+			name: "object members",
+			patch: Patch{
+				{Type: OperationReplace, Path: "/a", Value: 2},
+				{Type: OperationRemove, Path: "/b/c"},
+				{Type: OperationAdd, Path: "/b/d", Value: []interface{}{1}},
+			},
+			expect: `{"a":2,"b":{"c":null,"d":[1]}}`,
+		},
+		{
+			name: "later operations prevail",
+			patch: Patch{
+				{Type: OperationAdd, Path: "/a", Value: 1},
+				{Type: OperationRemove, Path: "/a"},
+				{Type: OperationRemove, Path: "/b"},
+				{Type: OperationAdd, Path: "/b", Value: "x"},
+			},
+			expect: `{"a":null,"b":"x"}`,
+		},
+		{
+			name: "nested in added value",
+			patch: Patch{
+				{Type: OperationAdd, Path: "/a", Value: map[string]interface{}{"b": 1}},
+				{Type: OperationAdd, Path: "/a/c", Value: 2},
+			},
+			expect: `{"a":{"b":1,"c":2}}`,
+		},
+		{
+			name: "root scalar",
+			patch: Patch{
+				{Type: OperationReplace, Path: "", Value: "foo"},
+			},
+			expect: `"foo"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.patch.ToMergePatch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.expect), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("patch mismatch:\ngot:  %s\nwant: %s", b, tc.expect)
+			}
+		})
+	}
+	// The value of the operation must be left unchanged.
+	v := map[string]interface{}{"b": 1}
+	if _, err := (Patch{
+		{Type: OperationAdd, Path: "/a", Value: v},
+		{Type: OperationAdd, Path: "/a/c", Value: 2},
+	}).ToMergePatch(); err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != 1 {
+		t.Errorf("operation value modified: %v", v)
+	}
+}
+
+func TestPatch_ToMergePatch_errors(t *testing.T) {
+	for _, tc := range []struct {
+		patch Patch
+		err   string
+	}{
+		{
+			Patch{{Type: OperationMove, From: "/a", Path: "/b"}},
+			`jsondiff: operation #0 (move "/b") cannot be converted to a merge patch: its type is unsupported`,
+		},
+		{
+			Patch{{Type: OperationCopy, From: "/a", Path: "/b"}},
+			`jsondiff: operation #0 (copy "/b") cannot be converted to a merge patch: its type is unsupported`,
+		},
+		{
+			Patch{{Type: OperationAdd, Path: "/a", Value: 1}, {Type: OperationTest, Path: "/a", Value: 1}},
+			`jsondiff: operation #1 (test "/a") cannot be converted to a merge patch: its type is unsupported`,
+		},
+		{
+			Patch{{Type: OperationAdd, Path: "/a/1/b", Value: 1}},
+			`jsondiff: operation #0 (add "/a/1/b") cannot be converted to a merge patch: its path refers to an array element`,
+		},
+		{
+			Patch{{Type: OperationAdd, Path: "/a/-", Value: 1}},
+			`jsondiff: operation #0 (add "/a/-") cannot be converted to a merge patch: its path refers to an array element`,
+		},
+		{
+			Patch{{Type: OperationReplace, Path: "", Value: map[string]interface{}{}}},
+			`jsondiff: operation #0 (replace "") cannot be converted to a merge patch: the root document cannot be replaced`,
+		},
+		{
+			Patch{{Type: OperationRemove, Path: "/a"}, {Type: OperationAdd, Path: "/a/b", Value: 1}},
+			`jsondiff: operation #1 (add "/a/b") cannot be converted to a merge patch: its parent is not an object`,
+		},
+		{
+			Patch{{Type: OperationReplace, Path: "/t~0", Value: nil}},
+			`jsondiff: operation #0 (replace "/t~0") cannot be converted to a merge patch: a null value cannot be expressed`,
+		},
+		{
+			Patch{{Type: OperationAdd, Path: "a", Value: 1}},
+			`jsondiff: operation #0 (add "a") cannot be converted to a merge patch: invalid pointer "a": no leading slash`,
+		},
+	} {
+		_, err := tc.patch.ToMergePatch()
+		if err == nil {
+			t.Errorf("expected non-nil error for patch %s", tc.patch.String())
+			continue
+		}
+		if err.Error() != tc.err {
+			t.Errorf("error mismatch:\ngot:  %s\nwant: %s", err, tc.err)
+		}
+	}
+}
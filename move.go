@@ -0,0 +1,185 @@
+package jsondiff
+
+import "sort"
+
+// matchPermutation returns, for each element of the target
+// array, the index of the equal element of the source array,
+// or nil if the arrays are not a permutation of each other.
+// The equal elements are matched in order, such that the
+// duplicated elements keep their relative order.
+func (d *Differ) matchPermutation(ptr pointer, src, tgt []interface{}) []int {
+	if len(src) != len(tgt) {
+		return nil
+	}
+	norm := d.normalizes()
+	elems := make(map[uint64][]int, len(src))
+
+	for i, v := range src {
+		k := d.sourceDigest(ptr, i, v)
+		elems[k] = append(elems[k], i)
+	}
+	perm := make([]int, len(tgt))
+
+	for j, v := range tgt {
+		k := d.digestElem(ptr, j, v)
+		idx := elems[k]
+		found := -1
+		for n, i := range idx {
+			var eq bool
+			if norm {
+				p := ptr.clone()
+				p.appendIndex(i)
+				eq = d.matches(p.string(), src[i], v)
+			} else {
+				eq = deepEqual(src[i], v)
+			}
+			if eq {
+				found = n
+				break
+			}
+		}
+		if found == -1 {
+			return nil
+		}
+		perm[j] = idx[found]
+		elems[k] = append(idx[:found:found], idx[found+1:]...)
+	}
+	return perm
+}
+
+// longestIncreasing returns whether each index of the
+// permutation belongs to its longest increasing subsequence,
+// which represents the elements that keep their relative
+// order in both arrays.
+func longestIncreasing(perm []int) []bool {
+	var (
+		tails = make([]int, 0, len(perm)) // indices in perm
+		prev  = make([]int, len(perm))
+	)
+	for j, v := range perm {
+		n := sort.Search(len(tails), func(k int) bool {
+			return perm[tails[k]] >= v
+		})
+		if n > 0 {
+			prev[j] = tails[n-1]
+		} else {
+			prev[j] = -1
+		}
+		if n == len(tails) {
+			tails = append(tails, j)
+		} else {
+			tails[n] = j
+		}
+	}
+	stable := make([]bool, len(perm))
+	if len(tails) != 0 {
+		for j := tails[len(tails)-1]; j != -1; j = prev[j] {
+			stable[j] = true
+		}
+	}
+	return stable
+}
+
+// compareMoved compares two arrays whose elements are
+// equal but ordered differently, and generates the move
+// operations that reorder the elements of the source array.
+// The elements that belong to the longest subsequence found
+// in both arrays are not moved, and the others are moved in
+// the order of the target array, each after the element that
+// precedes it in the target array. An element is thus never
+// displaced once moved, and the indices of the operations
+// refer to the array as modified by the preceding moves.
+// It returns false if the arrays are not a permutation of
+// each other, or if one of the elements is ignored, and the
+// arrays must be compared normally.
+func (d *Differ) compareMoved(ptr pointer, src, tgt []interface{}) bool {
+	if d.opts.equivalent {
+		// The arrays are equivalent for the Equivalent
+		// option, which takes precedence.
+		return false
+	}
+	perm := d.matchPermutation(ptr, src, tgt)
+	if perm == nil {
+		return false
+	}
+	if d.opts.hasIgnore && d.ignoresElem(ptr, len(src)) {
+		return false
+	}
+	ptr.snapshot()
+	defer d.restoreToken(d.token)
+
+	d.reorder(ptr, perm, tgt, func(j int) {
+		d.track(ptr.string(), perm[j], j)
+	})
+	if d.opts.foldKeys {
+		d.renameMatchedKeys(ptr, src, tgt, perm, true, emptyPointer)
+	}
+	return true
+}
+
+// reorder generates the move operations that reorder the
+// elements of an array whose index in the target order is
+// given by perm, as described by compareMoved. The values
+// are those of the elements in the target order, and track
+// is called before the element at index j of the target
+// order is moved, if at all.
+func (d *Differ) reorder(ptr pointer, perm []int, values []interface{}, track func(j int)) {
+	stable := longestIncreasing(perm)
+
+	// The current order of the elements, identified
+	// by their index in the target order.
+	cur := make([]int, len(perm))
+	for j, i := range perm {
+		cur[i] = j
+	}
+	n := len(perm)
+
+	for j := range values {
+		track(j)
+		if stable[j] {
+			continue
+		}
+		from := indexOf(cur, j)
+		cur = append(cur[:from], cur[from+1:]...)
+
+		to := 0
+		if j > 0 {
+			to = indexOf(cur, j-1) + 1
+		}
+		cur = append(cur, 0)
+		copy(cur[to+1:], cur[to:])
+		cur[to] = j
+
+		if from == to {
+			continue
+		}
+		d.appendIndex(&ptr, from, n)
+		fp := ptr.copy()
+		ptr.rewind()
+		d.appendIndex(&ptr, to, n-1)
+		d.emit(OperationMove, fp, ptr.copy(), values[j], values[j], 0)
+		ptr.rewind()
+	}
+}
+
+// ignoresElem returns whether one of the n elements
+// of the array located at ptr is ignored.
+func (d *Differ) ignoresElem(ptr pointer, n int) bool {
+	for i := 0; i < n; i++ {
+		p := ptr.clone()
+		p.appendIndex(i)
+		if d.isIgnored(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func indexOf(a []int, v int) int {
+	for i, e := range a {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+}
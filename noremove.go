@@ -0,0 +1,9 @@
+package jsondiff
+
+// DroppedRemovals returns the pointers of the source values
+// whose removal has been dropped from the patch by the NoRemove
+// option, in the order they were compared. The pointers of the
+// array elements are their indices in the source array.
+func (d *Differ) DroppedRemovals() []string {
+	return d.dropped
+}
@@ -0,0 +1,132 @@
+package jsondiff
+
+// Normalize returns a copy of the patch without the operations
+// that have no effect, such as the replacement of a value by an
+// identical value, the move of a value to its own location, or
+// the copy of the value of an object member to its own location,
+// and without the self-canceling operations: an add
+// operation immediately followed by the removal of the same
+// location, under the assumption that the value is created by
+// the operation, as with the patches generated by a Differ. The
+// removal of a location immediately followed by the addition of
+// a value at the same location is collapsed into a replacement.
+//
+// The test operations are preserved, such that the patches of
+// the Invertible option still verify the state of the document,
+// except for the test of the value added by an operation that is
+// canceled. A replacement is known to be identical if its previous
+// value is an equal value, or if it follows a test of the same
+// location with an equal value.
+func (p Patch) Normalize() Patch {
+	out := make(Patch, 0, len(p))
+
+	for _, op := range p {
+		switch op.Type {
+		case OperationReplace:
+			if isNoopReplace(out, op) {
+				continue
+			}
+		case OperationMove:
+			if op.From == op.Path {
+				continue
+			}
+		case OperationCopy:
+			// The copy of an array element to its own
+			// location inserts a duplicate element.
+			if op.From == op.Path && !isElementPointer(op.Path) {
+				continue
+			}
+		case OperationRemove:
+			if i := canceledAdd(out, op.Path); i != -1 {
+				out = out[:i]
+				continue
+			}
+		case OperationAdd:
+			n := len(out)
+			if n == 0 || out[n-1].Type != OperationRemove || out[n-1].Path != op.Path || op.Path == emptyPointer {
+				break
+			}
+			rm := out[n-1]
+			out = out[:n-1]
+
+			op.Type = OperationReplace
+			op.OldValue = rm.OldValue
+			if isNoopReplace(out, op) {
+				continue
+			}
+		}
+		out = append(out, op)
+	}
+	return out
+}
+
+// isNoopReplace returns whether the replace operation,
+// which follows the operations of the patch, replaces
+// a value by an identical value.
+func isNoopReplace(prev Patch, op Operation) bool {
+	if op.OldValue != nil && equalValues(op.OldValue, op.Value) {
+		return true
+	}
+	n := len(prev)
+	if n == 0 {
+		return false
+	}
+	t := prev[n-1]
+	return t.Type == OperationTest && t.Path == op.Path && equalValues(t.Value, op.Value)
+}
+
+// canceledAdd returns the index of the add operation at
+// the end of the patch, possibly followed by a test of its
+// value, that creates the value at the given path, whose
+// removal cancels it, or -1 if there is none.
+func canceledAdd(prev Patch, path string) int {
+	n := len(prev)
+	if n != 0 && prev[n-1].Type == OperationTest && prev[n-1].Path == path {
+		n--
+	}
+	if n == 0 {
+		return -1
+	}
+	if op := prev[n-1]; op.Type == OperationAdd && op.Path == path && path != emptyPointer && !isAppendPointer(path) {
+		return n - 1
+	}
+	return -1
+}
+
+// isAppendPointer returns whether the pointer references
+// the nonexistent element past the end of an array.
+func isAppendPointer(ptr string) bool {
+	return len(ptr) >= 2 && ptr[len(ptr)-2:] == "/-"
+}
+
+// isElementPointer returns whether the last token of the
+// pointer may locate an array element, that is, whether it
+// is an index or "-". The other tokens locate object members.
+func isElementPointer(ptr string) bool {
+	if ptr == emptyPointer {
+		return false
+	}
+	tok := ptr[len(parentPointer(ptr))+1:]
+	if tok == "-" {
+		return true
+	}
+	_, ok := parseIndex(tok)
+	return ok
+}
+
+// equalValues returns whether the JSON representations of
+// the values are equal, the numbers being compared by value.
+func equalValues(a, b interface{}) bool {
+	var opts options
+	opts.setDefaultCodec()
+
+	av, _, err := marshalUnmarshal(a, opts)
+	if err != nil {
+		return false
+	}
+	bv, _, err := marshalUnmarshal(b, opts)
+	if err != nil {
+		return false
+	}
+	return deepEqualNumbers(av, bv)
+}
@@ -0,0 +1,156 @@
+package jsondiff
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPatch_Normalize(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		patch Patch
+		want  Patch
+	}{
+		{
+			"empty",
+			nil,
+			Patch{},
+		},
+		{
+			"identical replacement",
+			Patch{
+				{Type: OperationReplace, Path: "/a", Value: 1.0, OldValue: 1},
+				{Type: OperationReplace, Path: "/b", Value: 2.0, OldValue: 1.0},
+			},
+			Patch{
+				{Type: OperationReplace, Path: "/b", Value: 2.0},
+			},
+		},
+		{
+			"identical replacement after test",
+			Patch{
+				{Type: OperationTest, Path: "/a", Value: map[string]interface{}{"b": 1}},
+				{Type: OperationReplace, Path: "/a", Value: map[string]interface{}{"b": 1.0}},
+			},
+			Patch{
+				{Type: OperationTest, Path: "/a", Value: map[string]interface{}{"b": 1}},
+			},
+		},
+		{
+			"move and copy to the same location",
+			Patch{
+				{Type: OperationMove, From: "/a", Path: "/a"},
+				{Type: OperationCopy, From: "/b", Path: "/b"},
+				{Type: OperationCopy, From: "/b", Path: "/c"},
+				{Type: OperationMove, From: "/d/0", Path: "/d/0"},
+			},
+			Patch{
+				{Type: OperationCopy, From: "/b", Path: "/c"},
+			},
+		},
+		{
+			"copy of an array element to its own location",
+			Patch{
+				{Type: OperationCopy, From: "/0", Path: "/0"},
+				{Type: OperationCopy, From: "/a/1", Path: "/a/1"},
+			},
+			Patch{
+				{Type: OperationCopy, From: "/0", Path: "/0"},
+				{Type: OperationCopy, From: "/a/1", Path: "/a/1"},
+			},
+		},
+		{
+			"add then remove",
+			Patch{
+				{Type: OperationAdd, Path: "/a/1", Value: "x"},
+				{Type: OperationRemove, Path: "/a/1"},
+				{Type: OperationAdd, Path: "/b", Value: "y"},
+				{Type: OperationTest, Path: "/b", Value: "y"},
+				{Type: OperationRemove, Path: "/b"},
+				{Type: OperationAdd, Path: "/c/-", Value: "z"},
+				{Type: OperationRemove, Path: "/c/-"},
+			},
+			Patch{
+				{Type: OperationAdd, Path: "/c/-", Value: "z"},
+				{Type: OperationRemove, Path: "/c/-"},
+			},
+		},
+		{
+			"remove then add",
+			Patch{
+				{Type: OperationTest, Path: "/a", Value: 1.0},
+				{Type: OperationRemove, Path: "/a", OldValue: 1.0},
+				{Type: OperationAdd, Path: "/a", Value: 2.0},
+				{Type: OperationRemove, Path: "/b/0", OldValue: "x"},
+				{Type: OperationAdd, Path: "/b/0", Value: "x"},
+			},
+			Patch{
+				{Type: OperationTest, Path: "/a", Value: 1.0},
+				{Type: OperationReplace, Path: "/a", Value: 2.0},
+			},
+		},
+		{
+			"unrelated operations",
+			Patch{
+				{Type: OperationAdd, Path: "/a", Value: 1.0},
+				{Type: OperationRemove, Path: "/b"},
+				{Type: OperationRemove, Path: "/a/c"},
+				{Type: OperationAdd, Path: "/d", Value: 1.0},
+			},
+			Patch{
+				{Type: OperationAdd, Path: "/a", Value: 1.0},
+				{Type: OperationRemove, Path: "/b"},
+				{Type: OperationRemove, Path: "/a/c"},
+				{Type: OperationAdd, Path: "/d", Value: 1.0},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.patch.Normalize()
+			if len(got) != len(tc.want) {
+				t.Fatalf("got patch:\n%s\nwant:\n%s", &got, &tc.want)
+			}
+			for i, op := range got {
+				w := tc.want[i]
+				if op.Type != w.Type || op.Path != w.Path || op.From != w.From || !equalValues(op.Value, w.Value) {
+					t.Errorf("op #%d mismatch: got %s, want %s", i, op, w)
+				}
+			}
+		})
+	}
+	// The patch is not modified.
+	p := Patch{
+		{Type: OperationRemove, Path: "/a"},
+		{Type: OperationAdd, Path: "/a", Value: 1.0},
+	}
+	p.Normalize()
+	if p[0].Type != OperationRemove || p[1].Type != OperationAdd {
+		t.Errorf("unexpected modification of the patch")
+	}
+	// A normalized patch produces the same document.
+	src := []byte(`{"a":[1,2,3],"b":{"c":"x"}}`)
+	assembled := Patch{
+		{Type: OperationTest, Path: "/b/c", Value: "x"},
+		{Type: OperationRemove, Path: "/b/c"},
+		{Type: OperationAdd, Path: "/b/c", Value: "y"},
+		{Type: OperationAdd, Path: "/a/1", Value: 9.0},
+		{Type: OperationRemove, Path: "/a/1"},
+		{Type: OperationReplace, Path: "/a/0", Value: 1.0, OldValue: 1.0},
+		{Type: OperationAdd, Path: "/a/-", Value: 4.0},
+	}
+	want, err := assembled.Apply(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized := assembled.Normalize()
+	if len(normalized) != 3 {
+		t.Errorf("got %d operations, want 3:\n%s", len(normalized), &normalized)
+	}
+	got, err := normalized.Apply(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got document %s, want %s", got, want)
+	}
+}
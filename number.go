@@ -0,0 +1,212 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// normalizeNumber returns the canonical representation of
+// the value of a JSON number, made of its significant digits,
+// without leading and trailing zeros, followed by an exponent,
+// such that the representations of two numbers are equal if
+// and only if they have the same value, like 1.50 and 15e-1.
+// It returns false if the string isn't a valid JSON number.
+func normalizeNumber(s string) (string, bool) {
+	var neg bool
+	if strings.HasPrefix(s, "-") {
+		neg, s = true, s[1:]
+	}
+	mant, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i != -1 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return "", false
+		}
+		mant, exp = s[:i], e
+	}
+	ip, fp := mant, ""
+	if i := strings.IndexByte(mant, '.'); i != -1 {
+		ip, fp = mant[:i], mant[i+1:]
+		if fp == "" {
+			return "", false
+		}
+	}
+	if ip == "" || !isDigits(ip) || !isDigits(fp) {
+		return "", false
+	}
+	digits := strings.TrimLeft(ip+fp, "0")
+	if digits == "" {
+		return "0", true // -0 == 0
+	}
+	exp -= len(fp)
+	n := len(digits)
+	digits = strings.TrimRight(digits, "0")
+	exp += n - len(digits)
+
+	var sb strings.Builder
+	if neg {
+		sb.WriteByte('-')
+	}
+	sb.WriteString(digits)
+	sb.WriteByte('e')
+	sb.WriteString(strconv.Itoa(exp))
+
+	return sb.String(), true
+}
+
+func isDigits(s string) bool {
+	for _, c := range []byte(s) {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isIntegerNumber returns whether the JSON
+// number is represented in an integer form.
+func isIntegerNumber(n json.Number) bool {
+	return !strings.ContainsAny(string(n), ".eE")
+}
+
+// equalNumbers returns whether the numbers have the same
+// numeric value, and the same integer or decimal form if
+// the IntegerFloatStrict option is enabled. The numbers
+// decoded as float64 values are compared by their shortest
+// decimal representation.
+func (d *Differ) equalNumbers(src, tgt interface{}) bool {
+	sn, ok := numberString(src)
+	if !ok {
+		return false
+	}
+	tn, ok := numberString(tgt)
+	if !ok {
+		return false
+	}
+	if d.opts.intFloat && isIntegerNumber(json.Number(sn)) != isIntegerNumber(json.Number(tn)) {
+		return false
+	}
+	sc, ok := normalizeNumber(sn)
+	if !ok {
+		return false
+	}
+	tc, ok := normalizeNumber(tn)
+
+	return ok && sc == tc
+}
+
+// equalMixedNumbers returns whether a float64 and a
+// json.Number value are equal, that is if the number
+// is represented by the shortest decimal representation
+// of the float, such as 1 for 1.0, but not 1.0 or 1e0.
+func equalMixedNumbers(a, b interface{}) bool {
+	if n, ok := a.(json.Number); ok {
+		a, b = b, n
+	}
+	f, ok := a.(float64)
+	if !ok {
+		return false
+	}
+	n, ok := b.(json.Number)
+
+	return ok && formatFloat(f) == string(n)
+}
+
+// tolerates returns whether the numbers are compared
+// with the tolerance of one of the Epsilon options.
+func (d *Differ) tolerates() bool {
+	return d.opts.epsilon > 0 || d.opts.relEpsilon > 0
+}
+
+// nearlyEqual returns whether the difference between two
+// numbers is lower than or equal to the absolute tolerance
+// of the Epsilon option, or to the relative tolerance of the
+// RelativeEpsilon option, scaled by the largest magnitude of
+// the numbers. The integer and decimal forms of a number are
+// different if the IntegerFloatStrict option is enabled.
+func (d *Differ) nearlyEqual(src, tgt interface{}) bool {
+	if d.opts.intFloat {
+		sn, ok1 := src.(json.Number)
+		tn, ok2 := tgt.(json.Number)
+		if ok1 && ok2 && isIntegerNumber(sn) != isIntegerNumber(tn) {
+			return false
+		}
+	}
+	a, ok := numberFloat(src)
+	if !ok {
+		return false
+	}
+	b, ok := numberFloat(tgt)
+	if !ok {
+		return false
+	}
+	diff := math.Abs(a - b)
+
+	return diff <= d.opts.epsilon || diff <= d.opts.relEpsilon*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// numberFloat returns the value of a JSON number
+// as a float64, which may be rounded.
+func numberFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := strconv.ParseFloat(string(n), 64)
+		return f, err == nil
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f, true
+	case *big.Float:
+		f, _ := n.Float64()
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+// equalBigNumbers returns whether two numbers, at least one
+// of which is a *big.Int or *big.Float value, have the same
+// value. The numbers are compared exactly, and the float64
+// values by their shortest decimal representation.
+func equalBigNumbers(a, b interface{}) bool {
+	if x, ok := a.(*big.Int); ok {
+		if y, ok := b.(*big.Int); ok {
+			return x.Cmp(y) == 0
+		}
+	}
+	x, ok1 := bigRat(a)
+	y, ok2 := bigRat(b)
+	if !ok1 || !ok2 {
+		// The infinite values are only
+		// equal to each other.
+		fa, ok1 := a.(*big.Float)
+		fb, ok2 := b.(*big.Float)
+		return ok1 && ok2 && fa.IsInf() && fa.Cmp(fb) == 0
+	}
+	return x.Cmp(y) == 0
+}
+
+// bigRat returns the exact value of a number, which is
+// infinite if it is an infinite *big.Float value.
+func bigRat(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case *big.Int:
+		return new(big.Rat).SetInt(n), true
+	case *big.Float:
+		if n.IsInf() {
+			return nil, false
+		}
+		r, _ := n.Rat(nil)
+		return r, true
+	case json.Number:
+		return new(big.Rat).SetString(string(n))
+	case float64:
+		return new(big.Rat).SetString(formatFloat(n))
+	default:
+		return nil, false
+	}
+}
@@ -2,6 +2,8 @@ package jsondiff
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 	"unsafe"
 )
@@ -20,6 +22,7 @@ const (
 
 const (
 	fromFieldLen  = len(`,"from":""`)
+	sizeFieldLen  = len(`,"size":`)
 	valueFieldLen = len(`,"value":`)
 	opBaseLen     = len(`{"op":"","path":""}`)
 )
@@ -37,7 +40,16 @@ type Operation struct {
 	Type     string      `json:"op"`
 	From     string      `json:"from,omitempty"`
 	Path     string      `json:"path"`
+
+	// Size is the number of elements of the array, or the
+	// number of keys of the object, removed by the operation.
+	// It is not part of RFC 6902, and is only set when the
+	// WithRemoveSizeGuards option is enabled. Patch.Apply
+	// verifies it before removing the value.
+	Size *int `json:"size,omitempty"`
+
 	valueLen int
+	token    int
 }
 
 // MarshalJSON implements the json.Marshaler interface.
@@ -71,6 +83,83 @@ func (o Operation) MarshalJSON() ([]byte, error) {
 	return json.Marshal(op(o))
 }
 
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// It returns an error if the type of the operation is unknown,
+// or if one of the members required by its type is missing,
+// such as the value of an add operation, or the location a
+// move operation moves the value from.
+func (o *Operation) UnmarshalJSON(b []byte) error {
+	if err := o.unmarshal(b); err != nil {
+		return fmt.Errorf("jsondiff: invalid operation: %w", err)
+	}
+	return nil
+}
+
+// unmarshal unmarshals the operation, and returns
+// the unwrapped errors of the UnmarshalJSON method.
+func (o *Operation) unmarshal(b []byte) error {
+	var op struct {
+		Value json.RawMessage `json:"value"`
+		Type  *string         `json:"op"`
+		From  *string         `json:"from"`
+		Path  *string         `json:"path"`
+		Size  *int            `json:"size"`
+	}
+	if err := json.Unmarshal(b, &op); err != nil {
+		return err
+	}
+	if op.Type == nil {
+		return errors.New("missing op member")
+	}
+	typ := Operation{Type: *op.Type}
+
+	switch typ.Type {
+	case OperationAdd, OperationReplace, OperationRemove, OperationMove,
+		OperationCopy, OperationTest, OperationChecksum, OperationIncrement:
+	default:
+		return fmt.Errorf("unknown type %q", typ.Type)
+	}
+	if op.Path == nil {
+		return fmt.Errorf("missing path member of %s operation", typ.Type)
+	}
+	if typ.hasFrom() && op.From == nil {
+		return fmt.Errorf("missing from member of %s operation", typ.Type)
+	}
+	var v interface{}
+	if typ.marshalWithValue() {
+		if op.Value == nil {
+			return fmt.Errorf("missing value member of %s operation", typ.Type)
+		}
+		if err := json.Unmarshal(op.Value, &v); err != nil {
+			return err
+		}
+	}
+	*o = Operation{
+		Value:    v,
+		Type:     typ.Type,
+		Path:     *op.Path,
+		Size:     op.Size,
+		valueLen: valueLength(v),
+	}
+	if op.From != nil {
+		o.From = *op.From
+	}
+	return nil
+}
+
+// Depth returns the number of reference tokens of the
+// path of the operation, that is, the depth of the value
+// it applies to, the root document being at depth zero.
+// It returns -1 if the path isn't a valid JSON Pointer, such
+// as a path written with the DotBracket notation.
+func (o Operation) Depth() int {
+	tokens, err := parsePointer(o.Path)
+	if err != nil {
+		return -1
+	}
+	return len(tokens)
+}
+
 // jsonLength returns the length in bytes that the
 // operation would occupy when marshaled to JSON.
 func (o Operation) jsonLength() int {
@@ -82,9 +171,23 @@ func (o Operation) jsonLength() int {
 	if o.hasFrom() {
 		l += fromFieldLen + len(o.From)
 	}
+	if o.Size != nil {
+		l += sizeFieldLen + intLength(*o.Size)
+	}
 	return l
 }
 
+// valueSize returns the length in bytes of the JSON
+// representation of the value the operation applies,
+// that is, the old value for remove operations, and
+// the new value for all other types.
+func (o Operation) valueSize() int {
+	if o.Type == OperationRemove {
+		return valueLength(o.OldValue)
+	}
+	return valueLength(o.Value)
+}
+
 func (o Operation) hasFrom() bool {
 	switch o.Type {
 	case OperationCopy, OperationMove:
@@ -96,13 +199,55 @@ func (o Operation) hasFrom() bool {
 
 func (o Operation) marshalWithValue() bool {
 	switch o.Type {
-	case OperationAdd, OperationReplace, OperationTest:
+	case OperationAdd, OperationReplace, OperationTest, OperationChecksum, OperationIncrement:
 		return true
 	default:
 		return false
 	}
 }
 
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// The operations are unmarshaled with the UnmarshalJSON
+// method of the Operation type, and the error returned for
+// an invalid operation reports its index in the patch.
+func (p *Patch) UnmarshalJSON(b []byte) error {
+	var ops []json.RawMessage
+	if err := json.Unmarshal(b, &ops); err != nil {
+		return err
+	}
+	if ops == nil {
+		*p = nil
+		return nil
+	}
+	patch := make(Patch, len(ops))
+	for i, b := range ops {
+		if err := patch[i].unmarshal(b); err != nil {
+			return fmt.Errorf("jsondiff: invalid operation #%d: %w", i, err)
+		}
+	}
+	*p = patch
+
+	return nil
+}
+
+// Filter returns a new patch that holds the operations
+// for which the keep function returns true, in order. Unlike
+// the Ignores option, the operations are selected after their
+// generation, and the function can inspect their type and
+// values. The receiver and its backing array are unchanged.
+// Note that dropping an operation can change the meaning of
+// the operations that follow it, such as the removal of array
+// elements, whose indices depend on the earlier operations.
+func (p Patch) Filter(keep func(Operation) bool) Patch {
+	var patch Patch
+	for _, op := range p {
+		if keep(op) {
+			patch = append(patch, op)
+		}
+	}
+	return patch
+}
+
 func (p *Patch) remove(idx int) Patch {
 	return (*p)[:idx+copy((*p)[idx:], (*p)[idx+1:])]
 }
@@ -163,3 +308,69 @@ func (p *Patch) String() string {
 	}
 	return sb.String()
 }
+
+// StringIndent returns the JSON representation of the patch,
+// indented like json.MarshalIndent with the given prefix and
+// indentation, which is easier to read than the compact form
+// of String, such as in the logs or the failures of tests.
+func (p Patch) StringIndent(prefix, indent string) string {
+	if len(p) == 0 {
+		return ""
+	}
+	b, err := json.MarshalIndent(p, prefix, indent)
+	if err != nil {
+		return "<invalid patch>"
+	}
+	return string(b)
+}
+
+// Describe returns a human-readable representation of the
+// patch, with one operation per line, such as:
+//
+//	REPLACE /a/b: "x" -> "y"
+//	MOVE /c -> /d
+//
+// The old value of a replace operation is shown only if
+// the OldValue field of the operation isn't nil, which is
+// not the case for the operations of a patch unmarshaled
+// from its JSON representation. The output is not meant
+// to be parsed, and may change between versions.
+func (p Patch) Describe() string {
+	sb := strings.Builder{}
+	for i, op := range p {
+		if i != 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(op.describe())
+	}
+	return sb.String()
+}
+
+func (o Operation) describe() string {
+	path := o.Path
+	if path == emptyPointer {
+		path = `""`
+	}
+	typ := strings.ToUpper(o.Type)
+
+	switch {
+	case o.hasFrom():
+		return typ + " " + o.From + " -> " + path
+	case o.Type == OperationReplace && o.OldValue != nil:
+		return typ + " " + path + ": " + describeValue(o.OldValue) + " -> " + describeValue(o.Value)
+	case o.marshalWithValue():
+		return typ + " " + path + ": " + describeValue(o.Value)
+	default:
+		return typ + " " + path
+	}
+}
+
+// describeValue returns the compact JSON
+// representation of a value of an operation.
+func describeValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "<invalid value>"
+	}
+	return string(b)
+}
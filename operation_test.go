@@ -1,6 +1,11 @@
 package jsondiff
 
-import "testing"
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestOperation_MarshalJSON(t *testing.T) {
 	for _, tc := range []struct {
@@ -87,6 +92,14 @@ func TestOperation_MarshalJSON(t *testing.T) {
 			},
 			`{"op":"move","from":"/bar","path":"/baz"}`,
 		},
+		{
+			Operation{
+				Type: OperationRemove,
+				Path: "/foo",
+				Size: new(int),
+			},
+			`{"op":"remove","path":"/foo","size":0}`,
+		},
 	} {
 		b, err := tc.Op.MarshalJSON()
 		if err != nil {
@@ -95,6 +108,140 @@ func TestOperation_MarshalJSON(t *testing.T) {
 		if tc.Out != string(b) {
 			t.Errorf("marshaled patch mismatched, got %q, want %q", string(b), tc.Out)
 		}
+		if l := tc.Op.jsonLength(); !tc.Op.marshalWithValue() && l != len(b) {
+			t.Errorf("json length mismatch, got %d, want %d", l, len(b))
+		}
+	}
+}
+
+func TestPatch_UnmarshalJSON(t *testing.T) {
+	size := 2
+	patch := Patch{
+		{Type: OperationTest, Path: "/a", Value: nil},
+		{Type: OperationReplace, Path: "/a", Value: map[string]interface{}{"b": []interface{}{1.0, "c"}}},
+		{Type: OperationAdd, Path: "/d/-", Value: nil},
+		{Type: OperationRemove, Path: "/e", Size: &size},
+		{Type: OperationMove, From: "/f", Path: "/g"},
+		{Type: OperationCopy, From: "/g", Path: "/h"},
+		{Type: OperationIncrement, Path: "/i", Value: -1.5},
+		{Type: OperationChecksum, Path: "", Value: "sha256:00"},
+	}
+	b, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Patch
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(patch) {
+		t.Fatalf("got %d operations, want %d", len(got), len(patch))
+	}
+	for i, op := range got {
+		want := patch[i]
+		if op.Type != want.Type || op.Path != want.Path || op.From != want.From ||
+			!reflect.DeepEqual(op.Value, want.Value) || !reflect.DeepEqual(op.Size, want.Size) {
+			t.Errorf("op #%d mismatch: got %s, want %s", i, op.String(), want.String())
+		}
+		if l, w := op.jsonLength(), len(op.String()); l != w {
+			t.Errorf("op #%d json length mismatch: got %d, want %d", i, l, w)
+		}
+	}
+	if err := json.Unmarshal([]byte(`null`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("expected nil patch, got %s", got.String())
+	}
+}
+
+func TestPatch_UnmarshalJSON_errors(t *testing.T) {
+	for _, tc := range []struct {
+		json string
+		err  string
+	}{
+		{`[{"path":"/a"}]`, "jsondiff: invalid operation #0: missing op member"},
+		{`[{"op":"test","path":"","value":1},{"op":"merge","path":"/a"}]`, `jsondiff: invalid operation #1: unknown type "merge"`},
+		{`[{"op":"remove"}]`, "jsondiff: invalid operation #0: missing path member of remove operation"},
+		{`[{"op":"move","path":"/a"}]`, "jsondiff: invalid operation #0: missing from member of move operation"},
+		{`[{"op":"copy","path":"/a"}]`, "jsondiff: invalid operation #0: missing from member of copy operation"},
+		{`[{"op":"add","path":"/a"}]`, "jsondiff: invalid operation #0: missing value member of add operation"},
+		{`[{"op":"replace","path":"/a"}]`, "jsondiff: invalid operation #0: missing value member of replace operation"},
+		{`[{"op":"test","path":"/a"}]`, "jsondiff: invalid operation #0: missing value member of test operation"},
+	} {
+		var p Patch
+		err := json.Unmarshal([]byte(tc.json), &p)
+		if err == nil {
+			t.Errorf("expected non-nil error for %s", tc.json)
+			continue
+		}
+		if err.Error() != tc.err {
+			t.Errorf("error mismatch:\ngot:  %s\nwant: %s", err, tc.err)
+		}
+	}
+	for _, s := range []string{`{}`, `[{"op":1,"path":"/a"}]`} {
+		var p Patch
+		if err := json.Unmarshal([]byte(s), &p); err == nil {
+			t.Errorf("expected non-nil error for %s", s)
+		}
+	}
+	var op Operation
+	err := json.Unmarshal([]byte(`{"op":"add","path":"/a"}`), &op)
+	if want := "jsondiff: invalid operation: missing value member of add operation"; err == nil || err.Error() != want {
+		t.Errorf("error mismatch:\ngot:  %v\nwant: %s", err, want)
+	}
+}
+
+func TestPatch_Filter(t *testing.T) {
+	p := Patch{
+		{Type: OperationReplace, Path: "/name", Value: "bar"},
+		{Type: OperationAdd, Path: "/secret/token", Value: "xyz"},
+		{Type: OperationRemove, Path: "/items/0"},
+		{Type: OperationReplace, Path: "/secret", Value: "abc"},
+	}
+	orig := make(Patch, len(p))
+	copy(orig, p)
+
+	fp := p.Filter(func(op Operation) bool {
+		return op.Path != "/secret" && !strings.HasPrefix(op.Path, "/secret/")
+	})
+	want := Patch{p[0], p[2]}
+	if !reflect.DeepEqual(fp, want) {
+		t.Errorf("got filtered patch:\n%s\nwant:\n%s", fp.String(), want.String())
+	}
+	if !reflect.DeepEqual(p, orig) {
+		t.Errorf("receiver modified:\n%s", p.String())
+	}
+	fp[0].Path = "/other"
+	if p[0].Path != "/name" {
+		t.Errorf("filtered patch shares the backing array of the receiver")
+	}
+	if fp := p.Filter(func(Operation) bool { return false }); len(fp) != 0 {
+		t.Errorf("expected empty patch, got %d operations", len(fp))
+	}
+}
+
+func TestOperation_Depth(t *testing.T) {
+	for _, tc := range []struct {
+		path  string
+		depth int
+	}{
+		{"", 0},
+		{"/", 1},
+		{"/a", 1},
+		{"/a/0/b", 3},
+		{"/a~1b", 1},       // key "a/b"
+		{"/a~1b/c~0d", 2},  // keys "a/b", "c~d"
+		{"/~01/~1~1/-", 3}, // keys "~1", "//", "-"
+		{"//", 2},          // keys "", ""
+		{"a/b", -1},
+		{"/a~2", -1},
+		{"/a~", -1},
+	} {
+		op := Operation{Type: OperationRemove, Path: tc.path}
+		if d := op.Depth(); d != tc.depth {
+			t.Errorf("depth of %q: got %d, want %d", tc.path, d, tc.depth)
+		}
 	}
 }
 
@@ -135,6 +282,62 @@ func TestPatch_String_nil(t *testing.T) {
 	}
 }
 
+func TestPatch_StringIndent(t *testing.T) {
+	patch := Patch{
+		{Type: OperationReplace, Path: "/a", Value: []interface{}{1, 2}},
+		{Type: OperationRemove, Path: "/b"},
+	}
+	const expected = `[
+>  {
+>    "value": [
+>      1,
+>      2
+>    ],
+>    "op": "replace",
+>    "path": "/a"
+>  },
+>  {
+>    "op": "remove",
+>    "path": "/b"
+>  }
+>]`
+	if s := patch.StringIndent(">", "  "); s != expected {
+		t.Errorf("indented patch mismatch, got:\n%s\nwant:\n%s", s, expected)
+	}
+	if s := (Patch{}).StringIndent("", "  "); s != "" {
+		t.Errorf("got %q, want empty string", s)
+	}
+	patch = Patch{{Type: OperationAdd, Path: "/c", Value: make(chan int)}}
+	if s := patch.StringIndent("", "  "); s != "<invalid patch>" {
+		t.Errorf("got %q, want invalid patch", s)
+	}
+}
+
+func TestPatch_Describe(t *testing.T) {
+	patch := Patch{
+		{Type: OperationReplace, Path: "/a/b", OldValue: "x", Value: "y"},
+		{Type: OperationReplace, Path: "/a/c", Value: 1.5},
+		{Type: OperationAdd, Path: "", Value: map[string]interface{}{"k": nil}},
+		{Type: OperationRemove, Path: "/d", OldValue: true},
+		{Type: OperationMove, From: "/e", Path: "/f"},
+		{Type: OperationCopy, From: "/g", Path: "/h/-"},
+		{Type: OperationTest, Path: "/i", Value: nil},
+		{Type: OperationAdd, Path: "/j", Value: make(chan int)},
+	}
+	const expected = `REPLACE /a/b: "x" -> "y"
+REPLACE /a/c: 1.5
+ADD "": {"k":null}
+REMOVE /d
+MOVE /e -> /f
+COPY /g -> /h/-
+TEST /i: null
+ADD /j: <invalid value>`
+
+	if s := patch.Describe(); s != expected {
+		t.Errorf("described patch mismatch, got:\n%s\nwant:\n%s", s, expected)
+	}
+}
+
 func TestPatch_jsonLength(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		p := new(Patch)
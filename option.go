@@ -1,5 +1,7 @@
 package jsondiff
 
+import "time"
+
 // An Option changes the default behavior of a Differ.
 type Option func(*Differ)
 
@@ -8,6 +10,18 @@ func Factorize() Option {
 	return func(o *Differ) { o.opts.factorize = true }
 }
 
+// FactorizeCache bounds the number of unchanged values
+// indexed by the factorization of operations to maxEntries,
+// evicting the least recently used ones when the limit is
+// reached. The memory used by the factorization of large
+// documents is thus bounded, at the cost of missing the
+// move and copy operations whose source value was evicted.
+// A value lower than or equal to zero removes the limit.
+// The option has no effect unless Factorize is enabled.
+func FactorizeCache(maxEntries int) Option {
+	return func(o *Differ) { o.opts.cacheSize = maxEntries }
+}
+
 // Rationalize enables rationalization of operations.
 func Rationalize() Option {
 	return func(o *Differ) { o.opts.rationalize = true }
@@ -19,16 +33,73 @@ func Equivalent() Option {
 	return func(o *Differ) { o.opts.equivalent = true }
 }
 
+// SetPaths instructs the Differ to compare the arrays located
+// at pointers that match one of the given patterns as multisets,
+// like the Equivalent option does for all the arrays: no operations
+// are generated for the arrays of equal elements in another order,
+// regardless of the other options that compare the arrays. The
+// arrays whose elements differ are compared normally. The
+// patterns follow the syntax of Ignores.
+func SetPaths(ptrs ...string) Option {
+	return func(o *Differ) { o.opts.sets = compilePatterns(ptrs) }
+}
+
 // LCS uses a Longest Common Subsequence to compare
 // arrays.
 func LCS() Option {
 	return func(o *Differ) { o.opts.lcs = true }
 }
 
+// DetectArrayMoves instructs the Differ to detect the
+// arrays whose elements are equal but ordered differently,
+// and to reorder their elements with move operations rather
+// than replacing them. The elements that keep their relative
+// order are not moved, and the indices of each operation take
+// the preceding moves into account. The arrays that are not a
+// permutation of each other are compared normally, and the
+// Equivalent option takes precedence.
+func DetectArrayMoves() Option {
+	return func(o *Differ) { o.opts.moves = true }
+}
+
+// SameParentMovesOnly restricts the factorization of
+// operations to the values that are moved or copied
+// within the same parent object or array.
+// This option has no effect if used without Factorize.
+func SameParentMovesOnly() Option {
+	return func(o *Differ) { o.opts.sameParent = true }
+}
+
+// NoCopy disables the generation of copy operations by the
+// factorization of operations, for the consumers that don't
+// support them, while the removed values that are added
+// elsewhere are still factorized as move operations. The
+// values that would have been copied are added instead.
+// This option has no effect if used without Factorize.
+func NoCopy() Option {
+	return func(o *Differ) { o.opts.noCopy = true }
+}
+
+// WithCostModel defines the function that returns the cost
+// of an operation, which is consulted instead of the length
+// in bytes of the operations to decide between alternative
+// representations of the same changes: the Rationalize option
+// replaces a series of operations by a single replace operation
+// only if it has a lower cost, and the Factorize option generates
+// move and copy operations only if they don't have a higher cost
+// than the equivalent remove and add operations.
+func WithCostModel(fn func(Operation) float64) Option {
+	return func(o *Differ) { o.opts.costModel = fn }
+}
+
 // Invertible enables the generation of an invertible
 // patch, by preceding each remove and replace operation
 // by a test operation that verifies the value at the
 // path that is being removed/replaced.
+// The elements appended to an array are referenced by their
+// index rather than by the "-" token, and the replacement of
+// a root document of a different type is also preceded by a
+// test operation. See Patch.Invert.
 // Note that copy operations are not invertible, and as
 // such, using this option disable the usage of copy
 // operation in favor of add operations.
@@ -36,6 +107,577 @@ func Invertible() Option {
 	return func(o *Differ) { o.opts.invertible = true }
 }
 
+// PartialMerge instructs the Differ to treat the target
+// document as a partial representation of the source, such
+// as a partial GraphQL response merged into a cached object:
+// the object keys absent from the target are considered not
+// fetched rather than removed, and produce no operation, while
+// the changed values of the keys present in both documents
+// are still compared. The arrays are compared as a whole, and
+// the option disables the Rationalize option, as well as the
+// replacements of the MaxPatchRatio and MaxOps options, which
+// would remove the keys not fetched.
+func PartialMerge() Option {
+	return func(o *Differ) { o.opts.partial = true }
+}
+
+// ConvergentMode instructs the Differ to generate a patch
+// that sets the target values unconditionally with add
+// operations, such that its application to a source document
+// that slightly drifted still converges toward the target.
+// The Factorize, Rationalize and Invertible options are ignored.
+func ConvergentMode() Option {
+	return func(o *Differ) { o.opts.convergent = true }
+}
+
+// TrackElementIdentity instructs the Differ to assign
+// identity tokens to the elements of the compared arrays,
+// to correlate their positions in the source and target
+// documents, and the operations that apply to them, such
+// as to animate list changes. See Differ.Identities.
+// The patch itself is not altered.
+func TrackElementIdentity() Option {
+	return func(o *Differ) { o.opts.identities = true }
+}
+
+// PruneTargetNulls removes the object keys that hold
+// a null value from a copy of the target document prior
+// to the comparison, such that a key that is absent from
+// the source and null in the target produces no operation,
+// and a key that becomes null is removed instead of being
+// replaced. The target value is left untouched.
+func PruneTargetNulls() Option {
+	return func(o *Differ) { o.opts.pruneNulls = true }
+}
+
+// PruneTargetNullElements is similar to PruneTargetNulls,
+// but also removes the null elements of the target arrays.
+func PruneTargetNullElements() Option {
+	return func(o *Differ) {
+		o.opts.pruneNulls = true
+		o.opts.pruneElems = true
+	}
+}
+
+// NullEqualsMissing instructs the Differ to consider that
+// an object key whose value is null is equal to a missing key,
+// such that a key that is null on one side and absent on the
+// other produces no operation. A key whose value changes from
+// or to null is still replaced. Unlike PruneTargetNulls, the
+// source and target values are treated alike.
+func NullEqualsMissing() Option {
+	return func(o *Differ) { o.opts.nullMissing = true }
+}
+
+// CaseInsensitiveKeys instructs the Differ to pair the keys
+// of the compared objects that differ only by the case of their
+// ASCII letters, such as "UserName" and "username", which are
+// compared to each other instead of being removed and added.
+// The source key is moved to the spelling of the target key
+// before the changes of its value, if any, the array elements
+// being matched alike. The keys that differ only by case from
+// another key of the same object are matched exactly, and
+// their pointers are returned by the method AmbiguousKeys.
+func CaseInsensitiveKeys() Option {
+	return func(o *Differ) { o.opts.foldKeys = true }
+}
+
+// ExplicitArrayIndex instructs the Differ to reference the
+// elements appended to an array by their index, such as
+// "/items/4", rather than by the "-" token. The patch is
+// stable for a given pair of documents, but its operations
+// are sensitive to their order: an element appended at an
+// index that exceeds the length of the array cannot be added,
+// and the patch must therefore be applied as a whole, in order.
+func ExplicitArrayIndex() Option {
+	return func(o *Differ) { o.opts.explicitIdx = true }
+}
+
+// StrictPresence ensures that an absent value, a null value,
+// an empty object and an empty array are always considered as
+// four distinct states, and that any transition between two
+// of them generates an operation. It takes precedence over the
+// options that relax the presence of values, such as
+// PruneTargetNulls and NullEqualsMissing.
+func StrictPresence() Option {
+	return func(o *Differ) { o.opts.strict = true }
+}
+
+// DeduplicateValues instructs the Differ to store the large
+// values that are repeated in several operations only once
+// in the values table of the envelope returned by the method
+// Differ.Envelope. See PatchEnvelope for the format details.
+// The patch returned by Differ.Patch is not affected.
+func DeduplicateValues() Option {
+	return func(o *Differ) { o.opts.dedup = true }
+}
+
+// DeterministicOrder guarantees that the operations, and
+// their order, only depend on the compared values, regardless
+// of the iteration order of the maps. The keys of the objects
+// are visited in lexicographical order to index the unchanged
+// values used by the Factorize option.
+func DeterministicOrder() Option {
+	return func(o *Differ) { o.opts.ordered = true }
+}
+
+// PathStyle sets the notation of the path and from locations
+// of the operations, JSONPointer by default. With DotBracket,
+// the patch is meant to be marshaled for a consumer of such
+// paths, and the methods of the Patch type, such as Apply and
+// Invert, which expect JSON Pointers, cannot be used on it,
+// and the Depth method returns -1 for its paths but the root.
+// With the base pointer of Differ.CompareAt, the numeric
+// tokens of the base are written as array indices.
+func PathStyle(n PathNotation) Option {
+	return func(o *Differ) { o.opts.notation = n }
+}
+
+// MetadataOnly instructs the Differ to generate operations
+// without values, whose Value and OldValue fields are nil,
+// for the consumers that only need the number and the types
+// of the changes, and their locations, such as to decide on
+// a rate limit. The patch cannot be applied. Factorization and
+// rationalization are disabled, since they compare the values
+// of the operations, and so are the MaxPatchRatio and the
+// CoalesceTests options. The size of the statistics of the
+// patch doesn't account for the values either.
+func MetadataOnly() Option {
+	return func(o *Differ) { o.opts.metadata = true }
+}
+
+// MaxValueBytes instructs the Differ to replace the values of
+// the operations whose JSON representation exceeds n bytes, such
+// as large base64 blobs, by a placeholder string that indicates
+// their size, like "<elided 42KB>", to inspect a patch or to log
+// it. The patch cannot be applied. See ElidedValueChecksums to
+// identify the elided values.
+func MaxValueBytes(n int) Option {
+	return func(o *Differ) { o.opts.maxValue = n }
+}
+
+// ElidedValueChecksums instructs the Differ to append the
+// checksum of the values elided with the MaxValueBytes option
+// to their placeholder, like "<elided 42KB fnv64a:9f3a1c>",
+// in the format of the Checksum function, such that the
+// changes of an elided value can be told apart.
+func ElidedValueChecksums() Option {
+	return func(o *Differ) { o.opts.elisionSums = true }
+}
+
+// DryRun instructs the Differ to only record the statistics
+// of the operations, available with the method Differ.Stats,
+// instead of generating them. Factorization and rationalization
+// are disabled, since they operate on the generated operations.
+func DryRun() Option {
+	return func(o *Differ) { o.opts.dryRun = true }
+}
+
+// WithOperationHandler instructs the Differ to call fn for
+// each operation, in order, as soon as it is generated, instead
+// of accumulating the operations in the patch, which remains
+// empty. If fn returns an error, the comparison is aborted and
+// the error is returned. The options that rewrite the generated
+// operations cannot be combined with it (see ErrHandlerOptions).
+func WithOperationHandler(fn func(Operation) error) Option {
+	return func(o *Differ) { o.opts.handler = fn }
+}
+
+// IntegerFloatStrict guarantees that json.Number values
+// that represent the same numeric value, but differ by their
+// integer or decimal form, such as 1 and 1.0, are never
+// considered equal, and that a replace operation carrying
+// the exact representation of the target number is generated.
+// It matters with the options that compare numbers by value,
+// such as NumericValueEquality and Epsilon. The option is only
+// meaningful when JSON numbers are decoded as json.Number,
+// using the UseNumber method of a json.Decoder (see
+// UnmarshalFunc), since this distinction is lost when they
+// are decoded as float64 values.
+func IntegerFloatStrict() Option {
+	return func(o *Differ) {
+		o.opts.intFloat = true
+		o.hasher.intFloat = true
+	}
+}
+
+// NumericValueEquality instructs the Differ to compare the
+// json.Number values by their numeric value rather than by
+// their representation, such that numbers that only differ
+// by their representation, like 1.50 and 1.5, 1e2 and 100,
+// or -0 and 0, produce no operation. The numbers are also
+// hashed by value. Combined with the IntegerFloatStrict
+// option, the integer and decimal forms of a number remain
+// different. As IntegerFloatStrict, this option requires
+// the numbers to be decoded as json.Number values.
+func NumericValueEquality() Option {
+	return func(o *Differ) {
+		o.opts.numbers = true
+		o.hasher.numbers = true
+	}
+}
+
+// Epsilon instructs the Differ to consider the numbers whose
+// absolute difference is lower than or equal to e as equal,
+// such that the numbers that only differ by a rounding error,
+// like 0.1+0.2 and 0.3, produce no operation. The strings and
+// booleans are not affected. The numbers are not hashed, such
+// that the arrays of nearly equal elements are equivalent for
+// the Equivalent option. Since the tolerance is not transitive,
+// the elements are paired greedily, in order. Combined with the
+// RelativeEpsilon option, the numbers are equal if they are
+// within either of the tolerances.
+func Epsilon(e float64) Option {
+	return func(o *Differ) { o.opts.epsilon = e }
+}
+
+// RelativeEpsilon is similar to Epsilon, but the tolerance
+// is relative to the magnitude of the compared numbers: the
+// numbers are equal if their absolute difference is lower than
+// or equal to e times the largest of their absolute values,
+// such as 1e-9 to ignore the differences beyond the ninth
+// significant digit.
+func RelativeEpsilon(e float64) Option {
+	return func(o *Differ) { o.opts.relEpsilon = e }
+}
+
+// MaxPatchRatio instructs the Differ to replace the patch
+// with a single replace operation of the whole document if
+// the estimated size of the patch exceeds the given ratio of
+// the size of the JSON representation of the target document,
+// such as 1.5 for 150%. The option has no effect in dry run
+// mode, and when the Ignores or IgnoreKeysAnywhere options are
+// used, since the replacement would overwrite the ignored values,
+// as well as with the NoRemove and PartialMerge options, since
+// it would remove the values absent from the target.
+func MaxPatchRatio(ratio float64) Option {
+	return func(o *Differ) { o.opts.maxRatio = ratio }
+}
+
+// MaxOps instructs the Differ to replace an object or an
+// array with a single replace operation of its target value
+// when the comparison of its members or elements generates
+// more than n operations. The budget is verified when the
+// comparison of each object and array completes, such that
+// the smallest value whose operations exceed it is replaced,
+// and its replacement counts as a single operation of its
+// parent. Like MaxPatchRatio, the option has no effect in
+// dry run mode, and with the Ignores, IgnoreKeysAnywhere, NoRemove
+// and PartialMerge options. A value is not replaced either if one
+// of its operations moves a value located outside of it, whose
+// removal would be lost. A value lower than or equal to zero has
+// no effect.
+func MaxOps(n int) Option {
+	return func(o *Differ) { o.opts.maxOps = n }
+}
+
+// SortByPath reorders the operations of the patch by path,
+// the indices of the array elements being compared numerically,
+// for the consumers that expect a stable order, such as to store
+// the patches. An operation is never moved before another one it
+// depends on, such as the removal of an array element that shifts
+// the index it references, or the move of the value it modifies,
+// and the dependent operations keep their relative order, such
+// that the order is only partially sorted in this case.
+func SortByPath() Option {
+	return func(o *Differ) { o.opts.sortPaths = true }
+}
+
+// NoRemove instructs to generate patches that never remove
+// data: the object keys and array elements absent from the
+// target produce no operation, and their pointers are available
+// with the method Differ.DroppedRemovals. The arrays are compared
+// index by index, regardless of the other array options.
+func NoRemove() Option {
+	return func(o *Differ) { o.opts.noRemove = true }
+}
+
+// PreserveNumberFormat instructs to emit the numbers of the
+// operations with their original representation in the JSON
+// documents, as json.RawMessage values, rather than with the
+// one of the float64 they are decoded into, such that a patch
+// is faithful to the documents, like 1.50 or 1000000 instead
+// of 1.5 or 1e+06 with some marshalers. It applies to the numbers
+// that are the values of the operations, not those nested in
+// the arrays and objects, and only if the JSON documents are
+// available, as with the CompareJSON and CompareFiles functions.
+// The numbers are otherwise marshaled as usual.
+func PreserveNumberFormat() Option {
+	return func(o *Differ) { o.opts.rawNumbers = true }
+}
+
+// GroupArrayOps reorders the operations of the patch such
+// that all the operations applied to the elements of a given
+// array are contiguous, while preserving their relative order.
+// An operation is never moved before another operation it
+// depends on, such as the insertion of an element that shifts
+// the index it references.
+func GroupArrayOps() Option {
+	return func(o *Differ) { o.opts.groupArrays = true }
+}
+
+// EqualFunc registers a function that compares the values
+// located at pointers that match the given pattern, instead
+// of their deep equality, such as timestamps that must be
+// compared to the second. If fn returns true, the values
+// produce no operation, and they are compared normally
+// otherwise. The pattern follows the syntax of Ignores. The
+// option can be used several times, and the function of
+// the first matching pattern is used. Note that the function
+// doesn't affect the hashes of the values used to pair the
+// array elements with the Equivalent and Factorize options.
+func EqualFunc(ptr string, fn func(a, b interface{}) bool) Option {
+	return func(o *Differ) {
+		o.opts.equalFuncs = append(o.opts.equalFuncs, equalFunc{
+			pattern: compilePattern(ptr),
+			equal:   fn,
+		})
+	}
+}
+
+// CoalesceTests instructs the Differ to merge the test
+// operations generated by the Invertible option for the
+// consecutive replacements of several members of the same
+// object into a single test operation of the object, which
+// precedes the replace operations, if the patch becomes
+// smaller. The test of the object fails if any of its members
+// doesn't match, and the patch remains invertible. The option
+// has no effect without the Invertible option, or when some
+// values are ignored with the Ignores, OnlyPaths or
+// IgnoreKeysAnywhere options, since the test of the object
+// would verify them.
+func CoalesceTests() Option {
+	return func(o *Differ) { o.opts.coalesce = true }
+}
+
+// WithRemoveSizeGuards adds to the remove operations of
+// arrays and objects a non-standard size field, that holds
+// the number of elements or keys of the removed value, so
+// that a cooperating consumer can verify that the value did
+// not change before removing it, which is cheaper than a
+// complete test of the value (see Invertible).
+func WithRemoveSizeGuards() Option {
+	return func(o *Differ) { o.opts.sizeGuards = true }
+}
+
+// WithSizeMetrics enables the recording of the length in
+// bytes of the JSON representation of the old and new
+// values of each operation, returned by the SizeMetrics
+// method of the Differ, to identify the changes that
+// dominate the size of a patch.
+func WithSizeMetrics() Option {
+	return func(o *Differ) { o.opts.sizeMetric = true }
+}
+
+// WithResultChecksum instructs the Differ to append to the
+// patch an operation of type OperationChecksum, whose value
+// is the checksum of the target document, so that a consumer
+// can verify that the application of the patch reconstructed
+// the target document correctly, by comparing the value of
+// the operation with the result of the Checksum function.
+// The operation is not part of RFC 6902.
+func WithResultChecksum() Option {
+	return func(o *Differ) { o.opts.checksum = true }
+}
+
+// WithStateHashes instructs the Differ to compute the
+// checksums of the source and target documents, which are
+// recorded as the pre-state and post-state hashes of the
+// envelope returned by the Differ.Envelope method, to
+// verify a chain of patches, such as events of an event
+// sourced system. See PatchEnvelope for details.
+func WithStateHashes() Option {
+	return func(o *Differ) { o.opts.stateHashes = true }
+}
+
+// MaxDepth limits the nesting depth of the compared values.
+// The depth of the values is verified iteratively before the
+// comparison, which is aborted with ErrMaxDepth if one of
+// them is nested deeper than n arrays/objects. This protects
+// the recursive comparison against adversarial inputs. See
+// ReplaceBeyondMaxDepth to replace the deepest values instead.
+func MaxDepth(n int) Option {
+	return func(o *Differ) { o.opts.maxDepth = n }
+}
+
+// ReplaceBeyondMaxDepth changes the behavior of the MaxDepth
+// option, such that the comparison is never aborted. Instead,
+// the arrays/objects nested within n other arrays/objects are
+// not compared, and are replaced as a whole by a single replace
+// operation if they differ. The depth of the values isn't verified
+// before the comparison, and the option has no effect if
+// MaxDepth isn't set.
+func ReplaceBeyondMaxDepth() Option {
+	return func(o *Differ) { o.opts.truncDepth = true }
+}
+
+// WithTimeout limits the duration of a comparison, which
+// is aborted with the ErrTimeout error once it is exceeded,
+// in which case no operations are generated. The elapsed
+// time is verified periodically when an object or array is
+// visited, and not during the comparison of the elements of
+// an array by the LCS option for example. Consequently, a
+// comparison may run slightly longer than the duration.
+// A duration lower than or equal to zero has no effect.
+func WithTimeout(dur time.Duration) Option {
+	return func(o *Differ) { o.opts.timeout = dur }
+}
+
+// Parallel instructs the Differ to compare the values of
+// the keys of large objects, which have at least 128 keys,
+// with n goroutines, the operations being merged in the
+// order of the keys, such that the patch is identical to
+// the one of a sequential comparison. The option has no
+// effect with the Factorize, TrackElementIdentity and
+// WithOperationHandler options, whose state is shared by
+// the whole comparison. Note that the functions of the other
+// options, such as WithCostModel or WithHasher, may be called
+// concurrently. A value lower than or equal to one disables
+// the option.
+func Parallel(n int) Option {
+	return func(o *Differ) { o.opts.parallel = n }
+}
+
+// NegativeArrayIndices instructs the Differ to reference the
+// elements located in the last k positions of an array with
+// an index relative to the end of the array, represented as a
+// negative integer, such as "/items/-1" for the last element.
+//
+// This notation is not part of RFC 6901, and the patch must be
+// applied by a cooperating consumer, which resolves a negative
+// index -n to the position len-n, where len is the length of
+// the array at the time the operation is applied. Note that the
+// pointers of the Ignores option must use the same notation for
+// the elements of the tail region.
+func NegativeArrayIndices(k int) Option {
+	return func(o *Differ) { o.opts.tailIndices = k }
+}
+
+// DecodeBase64JSON instructs the Differ to compare the
+// decoded values of the strings holding base64-encoded JSON
+// documents, located at pointers that match one of the patterns
+// (see Ignores). The operations that apply to a decoded value
+// have a non-standard path, such as "/token/~b64/claims/sub".
+func DecodeBase64JSON(ptrs ...string) Option {
+	return func(o *Differ) { o.opts.base64 = compilePatterns(ptrs) }
+}
+
+// DeltaFields instructs the Differ to generate an operation
+// of type OperationIncrement, whose value is the difference
+// between the target and source numbers, instead of a replace
+// operation, for the numbers located at pointers that match
+// one of the given patterns (see Ignores). Unlike absolute
+// values, the deltas of counters compose under concurrent
+// increments, but require a cooperating applier that adds
+// the delta to the current value, such as Patch.Apply, since
+// the operation is not part of RFC 6902. The delta of
+// json.Number values is computed exactly, using their decimal
+// representation, whereas the delta of float64 values is rounded
+// to the nearest float, and adding it to the source number may
+// not yield the target number exactly.
+func DeltaFields(ptrs ...string) Option {
+	return func(o *Differ) { o.opts.deltas = compilePatterns(ptrs) }
+}
+
+// AppendOnly instructs the Differ to compare the arrays
+// located at pointers that match one of the given patterns
+// as append-only logs, under the assumption that the target
+// array is the source array followed by new elements. The
+// elements of the common prefix are verified to be unchanged,
+// without being compared to generate operations, and only the
+// new elements are added (see Ignores for the patterns).
+// If the assumption does not hold, the arrays are compared
+// normally, unless the StrictAppendOnly option is enabled.
+func AppendOnly(ptrs ...string) Option {
+	return func(o *Differ) { o.opts.appendOnly = compilePatterns(ptrs) }
+}
+
+// WithArrayAlignment instructs the Differ to compare the
+// arrays located at pointers that match the given pattern
+// using the alignment of their elements returned by align,
+// as a list of pairs of indices of matching elements in the
+// source and target arrays. The matched elements are compared
+// to each other, while the elements of the source array that
+// are not matched are removed, and those of the target array
+// are added. The pattern follows the syntax of Ignores. The
+// pairs must be in range and strictly increasing in both
+// arrays, otherwise the arrays are compared normally. The
+// option can be repeated to register several functions, in
+// which case the first matching pattern applies.
+func WithArrayAlignment(ptr string, align func(src, tgt []interface{}) [][2]int) Option {
+	return func(o *Differ) {
+		o.opts.aligners = append(o.opts.aligners, arrayAligner{
+			pattern: compilePattern(ptr),
+			align:   align,
+		})
+	}
+}
+
+// MatchByKey instructs the Differ to compare the arrays
+// located at pointers that match the given pattern by pairing
+// their elements, which must be objects, that have the same
+// value for the member key, regardless of their positions.
+// The paired elements are reordered with move operations if
+// their order differs, and compared to each other, while the
+// elements that are not paired are removed or added. The
+// pattern follows the syntax of Ignores. The arrays whose
+// elements are not all objects with a distinct scalar value
+// for the member are compared normally. The option can be
+// repeated to register several patterns, in which case the
+// first matching pattern applies.
+func MatchByKey(ptr, key string) Option {
+	return func(o *Differ) {
+		o.opts.keys = append(o.opts.keys, keyMatcher{
+			pattern: compilePattern(ptr),
+			key:     key,
+		})
+	}
+}
+
+// StrictAppendOnly instructs the Differ to abort the
+// comparison with the ErrAppendOnly error when an array
+// that matches one of the patterns of the AppendOnly option
+// has been modified otherwise than by appending new elements.
+func StrictAppendOnly() Option {
+	return func(o *Differ) { o.opts.strictLogs = true }
+}
+
+// WithScalarDecoder registers a function that decodes the
+// strings located at pointers that match the given pattern
+// to their canonical representation, such as encoded IDs. Two
+// strings whose canonical representations are equal produce no
+// operation, and the canonical representations are also used to
+// hash the values (see Equivalent and Factorize). If one of the
+// strings cannot be decoded, they are compared as-is. The pattern
+// follows the syntax of Ignores. The option can be used several
+// times, and the decoder of the first matching pattern is used.
+func WithScalarDecoder(ptr string, decode func(string) (canonical string, ok bool)) Option {
+	return func(o *Differ) {
+		o.opts.scalars = append(o.opts.scalars, scalarDecoder{
+			pattern: compilePattern(ptr),
+			decode:  decode,
+		})
+		o.hasher.scalars = o.opts.scalars
+	}
+}
+
+// WithHasher replaces the hash function that the Differ
+// uses to find equal values, such as the unchanged values
+// copied by the Factorize option, the elements of the arrays
+// compared by the Equivalent and LCS options, or the values
+// deduplicated by the DeduplicateValues option. It allows to
+// use domain knowledge to hash values more cheaply, such as
+// the identifier of objects that always have a unique one.
+//
+// The values that are equal for the Differ, including with
+// the options that normalize values, such as Epsilon or
+// WithScalarDecoder, must have equal digests. The values
+// whose digests are equal may differ, and are compared to
+// confirm their equality.
+func WithHasher(h Hasher) Option {
+	return func(o *Differ) { o.hasher.custom = h }
+}
+
 // MarshalFunc allows to define the function/package
 // used to marshal objects to JSON.
 // The prototype of fn must match the one of the
@@ -53,6 +695,21 @@ func MarshalFunc(fn marshalFunc) Option {
 func UnmarshalFunc(fn unmarshalFunc) Option {
 	return func(o *Differ) {
 		o.opts.unmarshal = fn
+		o.opts.useNumber = false
+	}
+}
+
+// UseNumber instructs the Differ to decode the JSON numbers
+// as json.Number values rather than float64 values, using the
+// UseNumber method of a json.Decoder, such that the integers
+// that cannot be represented exactly by a float64, like large
+// identifiers, are compared without loss of precision, and that
+// the operations carry their exact representation. It replaces
+// the function set with the UnmarshalFunc option, if any.
+func UseNumber() Option {
+	return func(o *Differ) {
+		o.opts.unmarshal = unmarshalUseNumber
+		o.opts.useNumber = true
 	}
 }
 
@@ -76,16 +733,97 @@ func InPlaceCompaction() Option {
 
 // Ignores defines the list of values that are ignored
 // by the diff generation, represented as a list of JSON
-// Pointer strings (RFC 6901).
+// Pointer strings (RFC 6901). The pointers are patterns,
+// in which a segment equal to "*" matches any single segment,
+// and "**" any number of them, also used by the other options
+// that take pointers. Relative JSON Pointers, such as
+// "0/metadata/labels", are resolved against the root of the
+// compared documents.
 func Ignores(ptrs ...string) Option {
 	return func(o *Differ) {
 		if len(ptrs) == 0 {
 			return
 		}
 		o.opts.ignores = make(map[string]struct{}, len(ptrs))
+		o.opts.ignoreGlob = nil
+		o.opts.ignoreRules = makeRules(ptrs)
 		for _, ptr := range ptrs {
+			ptr, ok := resolveOptionPointer(ptr)
+			if !ok {
+				continue
+			}
+			if p := compilePattern(ptr); p.wildcard {
+				o.opts.ignoreGlob = append(o.opts.ignoreGlob, p)
+				continue
+			}
 			o.opts.ignores[ptr] = struct{}{}
 		}
 		o.opts.hasIgnore = true
 	}
 }
+
+// OnlyPaths restricts the comparison to the values located
+// at the given JSON Pointers, or within them, such that the
+// differences located elsewhere produce no operation. The
+// values outside the paths are not compared, and those that
+// contain the paths are only traversed to reach them: the
+// operations that would replace, add, or remove one of these
+// values are not generated, since they would also change the
+// values outside the paths. The Ignores option takes precedence.
+// Like for the Ignores option, the paths may be relative JSON
+// Pointers, resolved against the root of the compared documents.
+func OnlyPaths(ptrs ...string) Option {
+	return func(o *Differ) {
+		if len(ptrs) == 0 {
+			return
+		}
+		o.opts.only = make([]string, 0, len(ptrs))
+		o.opts.onlyRules = makeRules(ptrs)
+		for _, ptr := range ptrs {
+			if ptr, ok := resolveOptionPointer(ptr); ok {
+				o.opts.only = append(o.opts.only, ptr)
+			}
+		}
+		o.opts.hasIgnore = true
+	}
+}
+
+// TrackIgnores instructs the Differ to record which of the
+// pointers of the Ignores and OnlyPaths options match at least
+// one of the values compared, such that the misconfigured rules,
+// like a pointer with a typo, can be detected with the method
+// Differ.UnusedIgnores.
+func TrackIgnores() Option {
+	return func(o *Differ) { o.opts.trackRules = true }
+}
+
+// resolveOptionPointer returns the absolute pointer of
+// the given pointer of an option, which may be relative
+// to the root of the compared documents, or false if it
+// references no value of the documents.
+func resolveOptionPointer(ptr string) (string, bool) {
+	if !isRelativePointer(ptr) {
+		return ptr, true
+	}
+	return resolveRelativePointer(emptyPointer, ptr)
+}
+
+// IgnoreKeysAnywhere defines a list of object keys that
+// are ignored by the diff generation at any depth of the
+// documents, regardless of the location of the objects.
+// Unlike the Ignores option, the keys are matched by name.
+// The array elements that differ only by these keys are
+// matched, such as with the LCS and Equivalent options, but
+// the keys are still part of the values that are added or
+// replaced as a whole.
+func IgnoreKeysAnywhere(keys ...string) Option {
+	return func(o *Differ) {
+		if len(keys) == 0 {
+			return
+		}
+		o.opts.ignoreKeys = make(map[string]struct{}, len(keys))
+		for _, k := range keys {
+			o.opts.ignoreKeys[k] = struct{}{}
+		}
+	}
+}
@@ -27,6 +27,8 @@ func TestDiffer_applyOpts(t *testing.T) {
 		InPlaceCompaction(),
 		Ignores(ignoredPaths...),
 		LCS(),
+		PruneTargetNullElements(),
+		MaxDepth(42),
 	)
 	if d.opts.factorize != true {
 		t.Errorf("factorize option is not enabled")
@@ -60,6 +62,12 @@ func TestDiffer_applyOpts(t *testing.T) {
 	if d.opts.lcs != true {
 		t.Errorf("lcs option is not enabled")
 	}
+	if d.opts.pruneNulls != true || d.opts.pruneElems != true {
+		t.Errorf("prune nulls option is not enabled")
+	}
+	if d.opts.maxDepth != 42 {
+		t.Errorf("max depth mismatch, got %d, want 42", d.opts.maxDepth)
+	}
 }
 
 func cmpFuncs(x, y any) bool {
@@ -68,3 +76,34 @@ func cmpFuncs(x, y any) bool {
 	// https://go.dev/ref/spec#Comparison_operators
 	return fmt.Sprintf("%p", x) == fmt.Sprintf("%p", y)
 }
+
+func TestIgnores_patterns(t *testing.T) {
+	d := Differ{}
+	d.applyOpts(Ignores("/a/b", "/items/*/updatedAt", "/**/meta"))
+
+	if len(d.opts.ignores) != 1 {
+		t.Errorf("got %d exact pointers, want 1", len(d.opts.ignores))
+	}
+	if len(d.opts.ignoreGlob) != 2 {
+		t.Errorf("got %d patterns, want 2", len(d.opts.ignoreGlob))
+	}
+	for _, tc := range []struct {
+		ptr  string
+		want bool
+	}{
+		{"/a/b", true},
+		{"/a/b/c", false},
+		{"/items/0/updatedAt", true},
+		{"/items/0/createdAt", false},
+		{"/items/updatedAt", false},
+		{"/meta", true},
+		{"/x/y/meta", true},
+		{"/x/metadata", false},
+	} {
+		var p pointer
+		p.buf = append(p.buf, tc.ptr...)
+		if got := d.isIgnored(p); got != tc.want {
+			t.Errorf("%s: got %t, want %t", tc.ptr, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package jsondiff
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelThreshold is the minimum number of keys of
+// an object whose members are compared concurrently
+// when the Parallel option is enabled.
+const parallelThreshold = 128
+
+// keyResult represents the result of the comparison
+// of the values of a key present in both objects, which
+// were compared by a worker. The operations, ambiguous
+// keys and dropped removals are the ranges delimited by ops,
+// amb and drp in the corresponding lists of the worker.
+type keyResult struct {
+	w       *Differ
+	ops     [2]int
+	amb     [2]int
+	drp     [2]int
+	err     error
+	differs bool
+}
+
+// parallelizes returns whether the members of an object
+// that has n keys must be compared concurrently. The global
+// state of the factorization, of the element identities, and
+// the operation handler require a sequential comparison.
+func (d *Differ) parallelizes(n int) bool {
+	return d.opts.parallel > 1 && n >= parallelThreshold &&
+		!d.opts.factorize &&
+		!d.opts.identities &&
+		d.opts.handler == nil
+}
+
+// worker returns a new Differ that compares the members
+// of an object on behalf of d, with its own storage. The
+// objects it compares are not parallelized any further.
+func (d *Differ) worker() *Differ {
+	w := &Differ{
+		opts:     d.opts,
+		hasher:   d.hasher,
+		deadline: d.deadline,
+		ctx:      d.ctx,
+		index:    d.index,
+		indexed:  d.indexed,
+	}
+	w.opts.parallel = 0
+	// The digests of the values must be equal
+	// to those of the index, if any.
+	w.hasher.mh = maphash.Hash{}
+	w.hasher.mh.SetSeed(d.hasher.mh.Seed())
+	w.hasher.ptr = pointer{}
+
+	return w
+}
+
+// diffParallel compares the values of the keys present in
+// both objects concurrently, with the number of workers of
+// the Parallel option. The results are indexed like keys,
+// and are merged in the same order by mergeKey, such that
+// the patch is identical to the one of a sequential comparison.
+func (d *Differ) diffParallel(ptr pointer, keys []string, cmpSet map[string]uint8, aliases map[string]string, src, tgt map[string]interface{}, doc string) []keyResult {
+	var (
+		results = make([]keyResult, len(keys))
+		workers = make([]*Differ, min(d.opts.parallel, len(keys)))
+		next    int64
+		wg      sync.WaitGroup
+	)
+	for n := range workers {
+		w := d.worker()
+		workers[n] = w
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			p := pointer{buf: append([]byte(nil), ptr.buf...), base: ptr.base}
+			p.snapshot()
+
+			for w.err == nil {
+				i := int(atomic.AddInt64(&next, 1) - 1)
+				if i >= len(keys) {
+					return
+				}
+				k := keys[i]
+				if cmpSet[k] != 1<<0|1<<1 {
+					continue
+				}
+				if d.opts.ignoreKeys != nil {
+					if _, ok := d.opts.ignoreKeys[k]; ok {
+						continue
+					}
+				}
+				sv := src[k]
+				if sk, ok := aliases[k]; ok {
+					sv = src[sk]
+				}
+				ops, amb, drp := len(w.patch), len(w.ambiguous), len(w.dropped)
+
+				p.appendKey(k)
+				if w.opts.rationalize {
+					w.diff(p, sv, tgt[k], findKey(doc, p.base.key))
+				} else {
+					w.diff(p, sv, tgt[k], doc)
+				}
+				p.rewind()
+
+				results[i] = keyResult{
+					w:       w,
+					ops:     [2]int{ops, len(w.patch)},
+					amb:     [2]int{amb, len(w.ambiguous)},
+					drp:     [2]int{drp, len(w.dropped)},
+					err:     w.err,
+					differs: w.differs,
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, w := range workers {
+		d.stats.merge(w.stats)
+		d.mergeRuleHits(w)
+	}
+	return results
+}
+
+// mergeKey appends the operations that result from the
+// comparison of the values of a key by a worker, unless
+// the comparison has already been aborted, or a difference
+// found, like the sequential comparison would do.
+func (d *Differ) mergeKey(r keyResult) {
+	if d.differs || d.err != nil || r.w == nil {
+		return
+	}
+	d.patch = append(d.patch, r.w.patch[r.ops[0]:r.ops[1]]...)
+	d.ambiguous = append(d.ambiguous, r.w.ambiguous[r.amb[0]:r.amb[1]]...)
+	d.dropped = append(d.dropped, r.w.dropped[r.drp[0]:r.drp[1]]...)
+	d.differs = r.differs
+	d.err = r.err
+}
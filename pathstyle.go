@@ -0,0 +1,124 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// PathNotation represents the notation of the
+// locations of the operations of a patch.
+type PathNotation int
+
+const (
+	// JSONPointer denotes the JSON Pointers of RFC 6901,
+	// such as "/items/0/name", required by RFC 6902.
+	JSONPointer PathNotation = iota
+
+	// DotBracket denotes the paths of the property accessors
+	// of JavaScript, such as "items[0].name". The keys that are
+	// identifiers, made of ASCII letters, digits, '_' and '$',
+	// and that don't start with a digit, follow a dot, or start
+	// the path, and the other keys are written between brackets
+	// as JSON strings, such as `a["b.c"]` or `a["0"]`. The array
+	// indices are written between brackets, and the element that
+	// follows the last element of an array, represented by the
+	// "-" token of a pointer, is written "[-]". The root of the
+	// document is represented by an empty path.
+	DotBracket
+)
+
+// renderDotBracket replaces the JSON Pointers of the operations
+// of the patch by their dot-bracket notation. The numeric tokens
+// are array indices if the value they belong to is an array in
+// the source document, or in the target document if the location
+// doesn't exist in the former. The documents are located at the
+// base pointer of the Differ.CompareAt method, whose first base
+// tokens prefix the pointers, and are not looked up in them.
+func renderDotBracket(p Patch, base int, src, tgt interface{}) {
+	for i := range p {
+		op := &p[i]
+		op.Path = dotBracketPath(op.Path, base, src, tgt)
+		if op.hasFrom() {
+			op.From = dotBracketPath(op.From, base, src, tgt)
+		}
+	}
+}
+
+// dotBracketPath returns the dot-bracket notation of the pointer,
+// whose numeric tokens are resolved against the documents, except
+// the first base tokens, which locate the documents in larger ones
+// that are unknown, and whose numeric tokens are assumed to be
+// array indices.
+func dotBracketPath(ptr string, base int, docs ...interface{}) string {
+	tokens, err := decodePointer(ptr)
+	if err != nil || len(tokens) == 0 {
+		return ptr
+	}
+	var sb strings.Builder
+
+	for i, t := range tokens {
+		// The kind of the value is given by the
+		// first document where the location exists.
+		array, known := false, false
+		if i < base {
+			array = t == "-" || isDigits(t)
+		}
+		for j, doc := range docs {
+			if i < base {
+				break
+			}
+			switch v := doc.(type) {
+			case []interface{}:
+				if !known {
+					array, known = true, true
+				}
+				docs[j] = nil
+				if n, ok := parseIndex(t); ok && n < len(v) {
+					docs[j] = v[n]
+				}
+			case map[string]interface{}:
+				known = true
+				docs[j] = v[t]
+			default:
+				docs[j] = nil
+			}
+		}
+		switch {
+		case array && t == "-":
+			sb.WriteString("[-]")
+		case array && isDigits(t):
+			sb.WriteByte('[')
+			sb.WriteString(t)
+			sb.WriteByte(']')
+		case isIdentifier(t):
+			if i != 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(t)
+		default:
+			b, _ := json.Marshal(t)
+			sb.WriteByte('[')
+			sb.Write(b)
+			sb.WriteByte(']')
+		}
+	}
+	return sb.String()
+}
+
+// isIdentifier returns whether the key is an identifier of
+// JavaScript made of ASCII characters, which can follow a dot.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range []byte(s) {
+		switch {
+		case c == '_' || c == '$':
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i != 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
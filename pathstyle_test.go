@@ -0,0 +1,74 @@
+package jsondiff
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_dotBracketPath(t *testing.T) {
+	src := map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"name": "x"}},
+		"0":     map[string]interface{}{"1": true},
+		"a.b":   1.0,
+	}
+	tgt := map[string]interface{}{
+		"new": []interface{}{1.0},
+	}
+	for _, tc := range []struct {
+		ptr  string
+		want string
+	}{
+		{"", ""},
+		{"/items", "items"},
+		{"/items/0/name", "items[0].name"},
+		{"/items/-", "items[-]"},
+		{"/items/1", "items[1]"},
+		{"/0/1", `["0"]["1"]`},
+		{"/a.b", `["a.b"]`},
+		{"/x~1y/_id/$ref", `["x/y"]._id.$ref`},
+		{"/new/0", "new[0]"},
+		{"/missing/0", `missing["0"]`},
+		{"/q\"[]", `["q\"[]"]`},
+		{"/a1/1a", `a1["1a"]`},
+		{"/", `[""]`},
+	} {
+		if got := dotBracketPath(tc.ptr, 0, src, tgt); got != tc.want {
+			t.Errorf("%q: got %s, want %s", tc.ptr, got, tc.want)
+		}
+	}
+}
+
+func TestPathStyle(t *testing.T) {
+	src := `{"items":[{"name":"a","tags":["x"]},{"name":"b"}],"meta":{"k.v":1,"old":"some long value"}}`
+	tgt := `{"items":[{"name":"c","tags":["x","y"]}],"meta":{"k.v":2,"new":"some long value"}}`
+
+	patch, err := CompareJSON([]byte(src), []byte(tgt), PathStyle(DotBracket), Factorize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"op":"remove","path":"items[1]"}
+{"value":"c","op":"replace","path":"items[0].name"}
+{"value":"y","op":"add","path":"items[0].tags[-]"}
+{"value":2,"op":"replace","path":"meta[\"k.v\"]"}
+{"op":"move","from":"meta.old","path":"meta.new"}`
+	if s := patch.String(); s != want {
+		t.Errorf("got patch:\n%s\nwant:\n%s", s, want)
+	}
+	patch, err = CompareJSON([]byte(src), []byte(tgt), PathStyle(JSONPointer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch[0].Path != "/items/1" {
+		t.Errorf("got path %q, want JSON Pointer", patch[0].Path)
+	}
+	d := new(Differ).WithOpts(PathStyle(DotBracket))
+	d.CompareAt("/items/0", map[string]interface{}{"0": 1.0, "a": []interface{}{1.0}}, map[string]interface{}{"0": 2.0, "a": []interface{}{2.0}})
+	if p := d.Patch(); p.String() != `{"value":2,"op":"replace","path":"items[0][\"0\"]"}
+{"value":2,"op":"replace","path":"items[0].a[0]"}` {
+		t.Errorf("got patch at base:\n%s", p.String())
+	}
+	handler := WithOperationHandler(func(Operation) error { return nil })
+	if _, err := CompareJSON([]byte(src), []byte(tgt), PathStyle(DotBracket), handler); !errors.Is(err, ErrHandlerOptions) {
+		t.Errorf("got error %v, want %v", err, ErrHandlerOptions)
+	}
+}
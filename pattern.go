@@ -0,0 +1,107 @@
+package jsondiff
+
+import "strings"
+
+const (
+	anySegment  = "*"
+	anySegments = "**"
+)
+
+// pattern represents a compiled JSON Pointer pattern.
+// A segment equal to "*" matches exactly one segment of
+// a pointer, and a segment equal to "**" matches zero or
+// more segments. The other segments must match exactly,
+// in their escaped form.
+type pattern struct {
+	raw      string
+	segments []string
+	wildcard bool
+}
+
+// compilePattern compiles the given JSON Pointer pattern.
+func compilePattern(s string) pattern {
+	p := pattern{raw: s}
+	if s == "" {
+		return p
+	}
+	p.segments = strings.Split(s[1:], string(separator))
+
+	for _, seg := range p.segments {
+		if seg == anySegment || seg == anySegments {
+			p.wildcard = true
+			break
+		}
+	}
+	return p
+}
+
+// compilePatterns compiles a list of JSON Pointer patterns.
+func compilePatterns(ptrs []string) []pattern {
+	if len(ptrs) == 0 {
+		return nil
+	}
+	patterns := make([]pattern, len(ptrs))
+	for i, s := range ptrs {
+		patterns[i] = compilePattern(s)
+	}
+	return patterns
+}
+
+// match returns whether the pattern matches the pointer.
+func (p *pattern) match(ptr string) bool {
+	if !p.wildcard {
+		return ptr == p.raw
+	}
+	var (
+		i, pos     int // current pattern segment and pointer offset
+		star, mark = -1, 0
+	)
+	for pos < len(ptr) {
+		seg, next := nextSegment(ptr, pos)
+
+		switch {
+		case i < len(p.segments) && p.segments[i] == anySegments:
+			// Try to match zero segments first, and
+			// remember the position to backtrack.
+			star, mark = i, pos
+			i++
+		case i < len(p.segments) && (p.segments[i] == anySegment || p.segments[i] == seg):
+			i++
+			pos = next
+		case star != -1:
+			// Backtrack, and let the last "**"
+			// segment consume one more segment.
+			i = star + 1
+			_, mark = nextSegment(ptr, mark)
+			pos = mark
+		default:
+			return false
+		}
+	}
+	for i < len(p.segments) && p.segments[i] == anySegments {
+		i++
+	}
+	return i == len(p.segments)
+}
+
+// nextSegment returns the segment located at the given
+// offset of the pointer, which is expected to point to
+// a separator, and the offset of the following segment.
+func nextSegment(ptr string, pos int) (string, int) {
+	end := strings.IndexByte(ptr[pos+1:], separator)
+	if end == -1 {
+		return ptr[pos+1:], len(ptr)
+	}
+	end += pos + 1
+	return ptr[pos+1 : end], end
+}
+
+// matchAny returns whether one of the patterns matches the pointer.
+func matchAny(patterns []pattern, ptr string) bool {
+	for i := range patterns {
+		if patterns[i].match(ptr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package jsondiff
+
+import "testing"
+
+func Test_pattern_match(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		ptr     string
+		match   bool
+	}{
+		{"", "", true},
+		{"", "/a", false},
+		{"/a/b", "/a/b", true},
+		{"/a/b", "/a/c", false},
+		{"/a/*", "/a/b", true},
+		{"/a/*", "/a", false},
+		{"/a/*", "/a/b/c", false},
+		{"/*/b", "/0/b", true},
+		{"/*/b", "/0/c", false},
+		{"/items/*/updatedAt", "/items/3/updatedAt", true},
+		{"/items/*/updatedAt", "/items/3/createdAt", false},
+		{"/**", "", true},
+		{"/**", "/a/b/c", true},
+		{"/**/id", "/id", true},
+		{"/**/id", "/a/b/id", true},
+		{"/**/id", "/a/b/id/c", false},
+		{"/a/**/z", "/a/z", true},
+		{"/a/**/z", "/a/b/c/z", true},
+		{"/a/**/z", "/a/b/z/c", false},
+		{"/a/**/b/*", "/a/x/b/b/c", true},
+		{"/a/**", "/a", true},
+		{"/a/**", "/b", false},
+		{"/a~1b/*", "/a~1b/c", true},
+		{"/a/*", "/a/", true},
+	} {
+		p := compilePattern(tc.pattern)
+		if m := p.match(tc.ptr); m != tc.match {
+			t.Errorf("pattern %q, pointer %q: got %t, want %t", tc.pattern, tc.ptr, m, tc.match)
+		}
+	}
+}
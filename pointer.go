@@ -27,6 +27,10 @@ var (
 type segment struct {
 	key string
 	idx int
+
+	// depth is the number of reference
+	// tokens of the pointer.
+	depth int
 }
 
 // pointer represents an RFC 6901 JSON Pointer.
@@ -55,14 +59,31 @@ func (p *pointer) isRoot() bool {
 
 func (p *pointer) appendKey(key string) {
 	p.buf = append(p.buf, separator)
-	p.base = segment{key: key}
+	p.base = segment{key: key, depth: p.base.depth + 1}
 	p.appendEscapeKey(key)
 }
 
 func (p *pointer) appendIndex(idx int) {
 	p.buf = append(p.buf, separator)
 	p.buf = strconv.AppendInt(p.buf, int64(idx), 10)
-	p.base = segment{idx: idx}
+	p.base = segment{idx: idx, depth: p.base.depth + 1}
+}
+
+// appendRaw appends a segment to the pointer as-is,
+// without escaping it.
+func (p *pointer) appendRaw(seg string) {
+	p.buf = append(p.buf, separator)
+	p.buf = append(p.buf, seg...)
+	p.base = segment{key: seg, depth: p.base.depth + 1}
+}
+
+// appendTailIndex appends the index of an element
+// of an array of length n, relative to the end of
+// the array, represented as a negative integer.
+func (p *pointer) appendTailIndex(idx, n int) {
+	p.buf = append(p.buf, separator)
+	p.buf = strconv.AppendInt(p.buf, int64(idx-n), 10)
+	p.base = segment{idx: idx, depth: p.base.depth + 1}
 }
 
 func (p *pointer) snapshot() {
@@ -77,9 +98,16 @@ func (p *pointer) rewind() {
 
 func (p *pointer) reset() {
 	p.buf = p.buf[:0]
+	p.base = segment{}
 	p.sep = 0
 }
 
+// depth returns the number of reference tokens
+// of the pointer, zero for the root document.
+func (p *pointer) depth() int {
+	return p.base.depth
+}
+
 func (p *pointer) appendEscapeKey(k string) {
 	for _, c := range []byte(k) {
 		switch c {
@@ -93,6 +121,57 @@ func (p *pointer) appendEscapeKey(k string) {
 	}
 }
 
+// parentPointer returns the pointer of the parent
+// of the value located at the given pointer.
+// The parent of the root pointer is itself.
+func parentPointer(p string) string {
+	i := strings.LastIndexByte(p, separator)
+	if i == -1 {
+		return emptyPointer
+	}
+	return p[:i]
+}
+
+// isRelativePointer returns whether the string is a relative
+// JSON Pointer, made of a non-negative integer prefix, which is
+// the number of levels to go up from the location the pointer
+// is relative to, followed by a JSON Pointer, such as "0/a/b",
+// or "1". The index manipulations and the "#" suffix of the
+// relative pointers are not supported.
+func isRelativePointer(s string) bool {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 || i > 1 && s[0] == '0' {
+		// Leading zeros are forbidden.
+		return false
+	}
+	return i == len(s) || s[i] == separator
+}
+
+// resolveRelativePointer returns the absolute pointer of the
+// location referenced by the relative pointer rel from the
+// location of the base pointer, or false if rel goes up more
+// levels than the depth of base.
+func resolveRelativePointer(base, rel string) (string, bool) {
+	i := strings.IndexByte(rel, separator)
+	if i == -1 {
+		i = len(rel)
+	}
+	n, err := strconv.Atoi(rel[:i])
+	if err != nil {
+		return "", false
+	}
+	for ; n > 0; n-- {
+		if base == emptyPointer {
+			return "", false
+		}
+		base = parentPointer(base)
+	}
+	return base + rel[i:], true
+}
+
 var (
 	errLeadingSlash             = errors.New("no leading slash")
 	errIncompleteEscapeSequence = errors.New("incomplete escape sequence")
@@ -211,3 +211,52 @@ func BenchmarkEscapeKey(b *testing.B) {
 		}
 	})
 }
+
+func Test_parentPointer(t *testing.T) {
+	for _, tc := range []struct {
+		ptr, parent string
+	}{
+		{"", ""},
+		{"/", ""},
+		{"/a", ""},
+		{"/a/b", "/a"},
+		{"/a/0/-", "/a/0"},
+		{"/a~1b/c", "/a~1b"},
+	} {
+		if p := parentPointer(tc.ptr); p != tc.parent {
+			t.Errorf("got %q, want %q", p, tc.parent)
+		}
+	}
+}
+
+func Test_resolveRelativePointer(t *testing.T) {
+	for _, tc := range []struct {
+		base, rel string
+		abs       string
+		ok        bool
+	}{
+		{"", "0", "", true},
+		{"", "0/a/b", "/a/b", true},
+		{"", "0/", "/", true},
+		{"", "1", "", false},
+		{"", "1/a", "", false},
+		{"/a/b", "0", "/a/b", true},
+		{"/a/b", "1/c", "/a/c", true},
+		{"/a/b", "2/c~1d", "/c~1d", true},
+		{"/a/b", "3", "", false},
+		{"/a~1b/0", "1", "/a~1b", true},
+	} {
+		if !isRelativePointer(tc.rel) {
+			t.Errorf("expected %q to be a relative pointer", tc.rel)
+		}
+		abs, ok := resolveRelativePointer(tc.base, tc.rel)
+		if abs != tc.abs || ok != tc.ok {
+			t.Errorf("resolve %q from %q: got (%q, %t), want (%q, %t)", tc.rel, tc.base, abs, ok, tc.abs, tc.ok)
+		}
+	}
+	for _, s := range []string{"", "/", "/0", "01", "00/a", "1#", "0+1/a", "a/b", "-1"} {
+		if isRelativePointer(s) {
+			t.Errorf("expected %q not to be a relative pointer", s)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package jsondiff
+
+import "sync"
+
+// A Pool is a set of Differ instances that may be individually
+// retrieved and recycled, such that the storage allocated by a
+// comparison is reused by the next ones. All the instances of a
+// pool are configured with the same options. A Pool is safe for
+// use by multiple goroutines simultaneously, but a Differ returned
+// by Get must not be shared across goroutines while it is in use.
+// The zero value is a pool of Differ instances with no options.
+// A Pool must not be copied after first use.
+type Pool struct {
+	pool sync.Pool
+	opts []Option
+}
+
+// NewPool returns a pool of Differ instances
+// configured with the given options.
+func NewPool(opts ...Option) *Pool {
+	return &Pool{opts: opts}
+}
+
+// Get returns a Differ from the pool, or a new one
+// configured with the options of the pool if it is empty.
+func (p *Pool) Get() *Differ {
+	if d, ok := p.pool.Get().(*Differ); ok {
+		return d
+	}
+	return new(Differ).WithOpts(p.opts...)
+}
+
+// Put resets the Differ and adds it to the pool. The Differ,
+// and the patch it returned, must not be used after the call.
+// The options of the Differ must not have been changed since
+// it was returned by Get.
+func (p *Pool) Put(d *Differ) {
+	if d == nil {
+		return
+	}
+	d.Reset()
+	d.targetBytes = nil
+	p.pool.Put(d)
+}
@@ -0,0 +1,63 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	pool := NewPool(Rationalize(), Factorize())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				src := map[string]interface{}{"a": []interface{}{"x", "y"}, "n": float64(i)}
+				tgt := map[string]interface{}{"a": []interface{}{"y"}, "n": float64(j)}
+
+				want, err := Compare(src, tgt, Rationalize(), Factorize())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				d := pool.Get()
+				if j%2 == 0 {
+					// Rationalize marshals the target, which must not
+					// reuse the bytes of a previous comparison.
+					d.Compare(src, tgt)
+				} else {
+					b, _ := json.Marshal(tgt)
+					d.targetBytes = b
+					d.Compare(src, tgt)
+				}
+				got := d.Patch()
+				if got.String() != want.String() {
+					t.Errorf("got patch:\n%s\nwant:\n%s", got.String(), want.String())
+				}
+				pool.Put(d)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestPool_zeroValue(t *testing.T) {
+	var pool Pool
+
+	d := pool.Get()
+	d.Compare(map[string]interface{}{"a": 1.0}, map[string]interface{}{"a": 2.0})
+	patch := d.Patch()
+	if got, want := patch.String(), `{"value":2,"op":"replace","path":"/a"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	pool.Put(d)
+	pool.Put(nil)
+
+	d = pool.Get()
+	if len(d.Patch()) != 0 || d.err != nil {
+		t.Errorf("expected a reset differ")
+	}
+}
@@ -0,0 +1,78 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// preserveNumbers replaces the numbers of the operations
+// by their original representation in the JSON documents,
+// the values by those of the target document, and the
+// previous values and the values of the tests by those of
+// the source document. Since the pointers of the operations
+// that follow the modification of an array may not locate
+// the number in the original document, a representation is
+// only retained if it has the same value as the number.
+func (d *Differ) preserveNumbers() {
+	for i := range d.patch {
+		op := &d.patch[i]
+
+		switch op.Type {
+		case OperationTest:
+			op.Value = rawNumber(d.rawSource, op.Path, op.Value)
+		case OperationMove, OperationCopy:
+			op.Value = rawNumber(d.rawSource, op.From, op.Value)
+		default:
+			op.Value = rawNumber(d.rawTarget, op.Path, op.Value)
+		}
+		op.OldValue = rawNumber(d.rawSource, op.Path, op.OldValue)
+	}
+}
+
+// rawNumber returns the representation of the number v
+// located at path in the JSON document b, or v itself if
+// it isn't a float64, or if the document doesn't have the
+// same number at this location.
+func rawNumber(b []byte, path string, v interface{}) interface{} {
+	f, ok := v.(float64)
+	if !ok || b == nil {
+		return v
+	}
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return v
+	}
+	r := gjson.GetBytes(b, gjsonPath(tokens))
+	if r.Type != gjson.Number {
+		return v
+	}
+	if n, err := strconv.ParseFloat(r.Raw, 64); err != nil || n != f {
+		return v
+	}
+	return json.RawMessage(r.Raw)
+}
+
+// gjsonPath returns the path of the gjson package that
+// locates the value referenced by the pointer tokens. Unlike
+// the paths of the sjson package, the numeric tokens index
+// the arrays, and are the keys of the objects.
+func gjsonPath(tokens []string) string {
+	if len(tokens) == 0 {
+		return "@this"
+	}
+	var sb strings.Builder
+	for i, t := range tokens {
+		if i != 0 {
+			sb.WriteByte('.')
+		}
+		if t != "" && isDigits(t) {
+			sb.WriteString(t)
+		} else {
+			sb.WriteString(gjson.Escape(rfc6901Unescaper.Replace(t)))
+		}
+	}
+	return sb.String()
+}
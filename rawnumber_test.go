@@ -0,0 +1,95 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPreserveNumberFormat(t *testing.T) {
+	src := `{"a":1.50,"b":[1000000,2,3],"c":{"d":1E2},"e":5}`
+	tgt := `{"a":2.500,"b":[2,3,1000000e0],"c":{"d":1e2,"f":[1.0]},"e":"5"}`
+
+	for _, tc := range []struct {
+		opts []Option
+		want string
+	}{
+		{
+			[]Option{PreserveNumberFormat()},
+			`[{"value":2.500,"op":"replace","path":"/a"},{"value":2,"op":"replace","path":"/b/0"},{"value":3,"op":"replace","path":"/b/1"},{"value":1000000e0,"op":"replace","path":"/b/2"},{"value":[1],"op":"add","path":"/c/f"},{"value":"5","op":"replace","path":"/e"}]`,
+		},
+		{
+			[]Option{PreserveNumberFormat(), Invertible()},
+			`[{"value":1.50,"op":"test","path":"/a"},{"value":2.500,"op":"replace","path":"/a"},{"value":1000000,"op":"test","path":"/b/0"},{"value":2,"op":"replace","path":"/b/0"},{"value":2,"op":"test","path":"/b/1"},{"value":3,"op":"replace","path":"/b/1"},{"value":3,"op":"test","path":"/b/2"},{"value":1000000e0,"op":"replace","path":"/b/2"},{"value":[1],"op":"add","path":"/c/f"},{"value":5,"op":"test","path":"/e"},{"value":"5","op":"replace","path":"/e"}]`,
+		},
+		{
+			// The numbers are marshaled without the option.
+			[]Option{Invertible()},
+			`[{"value":1.5,"op":"test","path":"/a"},{"value":2.5,"op":"replace","path":"/a"},{"value":1000000,"op":"test","path":"/b/0"},{"value":2,"op":"replace","path":"/b/0"},{"value":2,"op":"test","path":"/b/1"},{"value":3,"op":"replace","path":"/b/1"},{"value":3,"op":"test","path":"/b/2"},{"value":1000000,"op":"replace","path":"/b/2"},{"value":[1],"op":"add","path":"/c/f"},{"value":5,"op":"test","path":"/e"},{"value":"5","op":"replace","path":"/e"}]`,
+		},
+	} {
+		patch, err := CompareJSON([]byte(src), []byte(tgt), tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("got patch:\n%s\nwant:\n%s", b, tc.want)
+		}
+	}
+	// The representations are only available when
+	// the JSON documents are compared.
+	var si, ti interface{}
+	if err := json.Unmarshal([]byte(src), &si); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(tgt), &ti); err != nil {
+		t.Fatal(err)
+	}
+	patch, err := Compare(si, ti, PreserveNumberFormat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range patch {
+		if _, ok := op.Value.(json.RawMessage); ok {
+			t.Errorf("unexpected raw value of operation %s", op)
+		}
+	}
+	patch, err = CompareJSON([]byte(`1.0`), []byte(` 2.00 `), PreserveNumberFormat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := patch.String(); s != `{"value":2.00,"op":"replace","path":""}` {
+		t.Errorf("got patch %s", s)
+	}
+}
+
+func Test_rawNumber(t *testing.T) {
+	doc := []byte(`{"a":[1.0,2],"0":{"b.c":3.25}}`)
+
+	for _, tc := range []struct {
+		path string
+		val  interface{}
+		want interface{}
+	}{
+		{"/a/0", 1.0, json.RawMessage("1.0")},
+		{"/0/b.c", 3.25, json.RawMessage("3.25")},
+		// The value at the location differs, such
+		// as after the modification of an array.
+		{"/a/0", 2.0, 2.0},
+		{"/a/2", 2.0, 2.0},
+		{"/a", 1.0, 1.0},
+		{"/a/0", "1.0", "1.0"},
+	} {
+		got := rawNumber(doc, tc.path, tc.val)
+		if b, ok := got.(json.RawMessage); ok {
+			if w, ok := tc.want.(json.RawMessage); !ok || string(b) != string(w) {
+				t.Errorf("%s: got %s, want %v", tc.path, b, tc.want)
+			}
+		} else if got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,616 @@
+package jsondiff
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	numberType        = reflect.TypeOf(json.Number(""))
+)
+
+// CompareReflect is similar to Compare, but converts the given
+// values to the Go types recognized by the json.Unmarshal function
+// by walking them with reflection, instead of marshaling them to
+// JSON and unmarshaling the result, which saves the encoding and
+// decoding of large structures. The conversion follows the rules
+// of the encoding/json package: the exported fields of the structs
+// are named after their json tags, including the omitempty and
+// string options, the fields of the embedded structs are promoted,
+// and the types that implement the json.Marshaler interface, such
+// as time.Time, or encoding.TextMarshaler, are represented by their
+// marshaled form, which is decoded with the function of the
+// UnmarshalFunc option, if any.
+//
+// Since the Rationalize option requires the JSON representation
+// of the target value, it is marshaled once converted, which has
+// a cost that CompareJSON doesn't have.
+func CompareReflect(source, target interface{}, opts ...Option) (Patch, error) {
+	var d Differ
+	d.applyOpts(opts...)
+
+	return compareReflect(&d, source, target)
+}
+
+func compareReflect(d *Differ, src, tgt interface{}) (Patch, error) {
+	d.opts.setDefaultCodec()
+
+	si, err := reflectValue(src, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	ti, err := reflectValue(tgt, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	d.targetBytes = nil
+	if d.opts.rationalize {
+		if d.targetBytes, err = d.opts.marshal(ti); err != nil {
+			return nil, err
+		}
+	}
+	d.Compare(si, ti)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.patch, nil
+}
+
+// reflector converts Go values to the representation
+// that the json.Unmarshal function would produce for
+// their JSON encoding.
+type reflector struct {
+	useNumber bool
+	unmarshal unmarshalFunc
+
+	// visiting holds the pointers and maps of the
+	// current path, to detect the cyclic values.
+	visiting map[uintptr]struct{}
+}
+
+// reflectValue returns the representation of the value
+// as the Go types recognized by the json.Unmarshal function.
+func reflectValue(v interface{}, opts options) (interface{}, error) {
+	r := reflector{
+		useNumber: opts.useNumber,
+		unmarshal: opts.unmarshal,
+	}
+	if r.useNumber {
+		r.unmarshal = unmarshalUseNumber
+	}
+	return r.value(reflect.ValueOf(v))
+}
+
+func (r *reflector) value(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, nil
+	}
+	t := v.Type()
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(t).Implements(marshalerType) {
+		return r.marshaler(v.Addr())
+	}
+	if t.Implements(marshalerType) {
+		if v.Kind() == reflect.Interface && v.IsNil() {
+			return nil, nil
+		}
+		return r.marshaler(v)
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(t).Implements(textMarshalerType) {
+		return r.textMarshaler(v.Addr())
+	}
+	if t.Implements(textMarshalerType) {
+		if v.Kind() == reflect.Interface && v.IsNil() {
+			return nil, nil
+		}
+		return r.textMarshaler(v)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if r.useNumber {
+			return json.Number(strconv.FormatInt(v.Int(), 10)), nil
+		}
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if r.useNumber {
+			return json.Number(strconv.FormatUint(v.Uint(), 10)), nil
+		}
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return r.float(v)
+	case reflect.String:
+		if t == numberType {
+			return r.number(v)
+		}
+		return v.String(), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return r.value(v.Elem())
+	case reflect.Pointer:
+		if err := r.enter(v); err != nil {
+			return nil, err
+		}
+		defer r.leave(v)
+
+		return r.value(v.Elem())
+	case reflect.Struct:
+		return r.structValue(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if err := r.enter(v); err != nil {
+			return nil, err
+		}
+		defer r.leave(v)
+
+		return r.mapValue(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if isByteSlice(t) {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+		return r.sliceValue(v)
+	case reflect.Array:
+		return r.sliceValue(v)
+	default:
+		return nil, &json.UnsupportedTypeError{Type: t}
+	}
+}
+
+// enter marks the pointer or map as part of the current
+// path, and returns an error if it already is.
+func (r *reflector) enter(v reflect.Value) error {
+	if r.visiting == nil {
+		r.visiting = make(map[uintptr]struct{})
+	}
+	p := v.Pointer()
+	if _, ok := r.visiting[p]; ok {
+		return &json.UnsupportedValueError{
+			Value: v,
+			Str:   fmt.Sprintf("encountered a cycle via %s", v.Type()),
+		}
+	}
+	r.visiting[p] = struct{}{}
+
+	return nil
+}
+
+func (r *reflector) leave(v reflect.Value) {
+	delete(r.visiting, v.Pointer())
+}
+
+func (r *reflector) marshaler(v reflect.Value) (interface{}, error) {
+	b, err := v.Interface().(json.Marshaler).MarshalJSON()
+	if err != nil {
+		return nil, &json.MarshalerError{Type: v.Type(), Err: err}
+	}
+	var i interface{}
+	if err := r.unmarshal(b, &i); err != nil {
+		return nil, &json.MarshalerError{Type: v.Type(), Err: err}
+	}
+	return i, nil
+}
+
+func (r *reflector) textMarshaler(v reflect.Value) (interface{}, error) {
+	b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return nil, &json.MarshalerError{Type: v.Type(), Err: err}
+	}
+	return string(b), nil
+}
+
+func (r *reflector) float(v reflect.Value) (interface{}, error) {
+	f := v.Float()
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, &json.UnsupportedValueError{
+			Value: v,
+			Str:   strconv.FormatFloat(f, 'g', -1, v.Type().Bits()),
+		}
+	}
+	if r.useNumber {
+		return json.Number(formatJSONFloat(f, v.Type().Bits())), nil
+	}
+	if v.Kind() == reflect.Float32 {
+		// The shortest representation of the float32
+		// value is decoded as a float64 value that
+		// differs from its conversion.
+		return strconv.ParseFloat(formatJSONFloat(f, 32), 64)
+	}
+	return f, nil
+}
+
+func (r *reflector) number(v reflect.Value) (interface{}, error) {
+	s := v.String()
+	if s == "" {
+		s = "0"
+	}
+	if !json.Valid([]byte(s)) {
+		return nil, fmt.Errorf("json: invalid number literal %q", s)
+	}
+	if r.useNumber {
+		return json.Number(s), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func (r *reflector) structValue(v reflect.Value) (interface{}, error) {
+	fields := cachedFields(v.Type())
+	m := make(map[string]interface{}, len(fields))
+
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		var (
+			e   interface{}
+			err error
+		)
+		if f.quoted {
+			e, err = r.quoted(fv)
+		} else {
+			e, err = r.value(fv)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[f.name] = e
+	}
+	return m, nil
+}
+
+// quoted returns the representation of a field
+// with the string option of the json tag, whose
+// encoding is itself encoded as a JSON string.
+func (r *reflector) quoted(v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if v.Type() == numberType {
+			if s := v.String(); s != "" {
+				return s, nil
+			}
+			return "0", nil
+		}
+		b, err := json.Marshal(v.String())
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, &json.UnsupportedValueError{
+				Value: v,
+				Str:   strconv.FormatFloat(f, 'g', -1, v.Type().Bits()),
+			}
+		}
+		return formatJSONFloat(f, v.Type().Bits()), nil
+	default:
+		return r.value(v)
+	}
+}
+
+func (r *reflector) mapValue(v reflect.Value) (interface{}, error) {
+	m := make(map[string]interface{}, v.Len())
+
+	it := v.MapRange()
+	for it.Next() {
+		k, err := mapKey(it.Key())
+		if err != nil {
+			return nil, err
+		}
+		e, err := r.value(it.Value())
+		if err != nil {
+			return nil, err
+		}
+		m[k] = e
+	}
+	return m, nil
+}
+
+func (r *reflector) sliceValue(v reflect.Value) (interface{}, error) {
+	s := make([]interface{}, v.Len())
+	for i := range s {
+		e, err := r.value(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		s[i] = e
+	}
+	return s, nil
+}
+
+// mapKey returns the object key of the map key,
+// in the order of precedence of the encoding/json
+// package.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		b, err := tm.MarshalText()
+		if err != nil {
+			return "", &json.MarshalerError{Type: k.Type(), Err: err}
+		}
+		return string(b), nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	default:
+		return "", &json.UnsupportedTypeError{Type: k.Type()}
+	}
+}
+
+// isByteSlice returns whether the slice type is encoded
+// as a base64 string, which is the case of the slices of
+// bytes whose elements don't implement a marshaler.
+func isByteSlice(t reflect.Type) bool {
+	e := t.Elem()
+	if e.Kind() != reflect.Uint8 {
+		return false
+	}
+	p := reflect.PointerTo(e)
+	return !p.Implements(marshalerType) && !p.Implements(textMarshalerType)
+}
+
+// formatJSONFloat formats the float like the encoding/json
+// package, which chooses the exponent format for the
+// very small and very large numbers only.
+func formatJSONFloat(f float64, bits int) string {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b := strconv.AppendFloat(nil, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return string(b)
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// reflectField represents an encoded field of a struct,
+// which may be promoted from an embedded struct.
+type reflectField struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	quoted    bool
+}
+
+// fieldByIndex returns the field of the struct located
+// at the given index sequence. It returns false if one
+// of the embedded structs of the sequence is a nil
+// pointer, in which case the field is omitted.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+var fieldCache sync.Map // map[reflect.Type][]reflectField
+
+func cachedFields(t reflect.Type) []reflectField {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]reflectField)
+	}
+	f, _ := fieldCache.LoadOrStore(t, structFields(t))
+	return f.([]reflectField)
+}
+
+// structFields returns the fields of the struct type that
+// are encoded by the encoding/json package. The fields of the
+// embedded structs are visited in breadth-first order, and a
+// name shared by several fields is resolved like the Go rules
+// for embedding, amended by the json tags: the shallowest field
+// wins, then the tagged one, and the conflicting fields that
+// remain are omitted.
+func structFields(t reflect.Type) []reflectField {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	var (
+		fields  []reflectField
+		current []embedded
+		next    = []embedded{{typ: t}}
+		visited = make(map[reflect.Type]bool)
+	)
+	for len(next) > 0 {
+		current, next = next, nil
+
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous {
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, embedded{typ: ft, index: index})
+					continue
+				}
+				f := reflectField{
+					name:      name,
+					index:     index,
+					tagged:    name != "",
+					omitEmpty: hasTagOption(opts, "omitempty"),
+				}
+				if f.name == "" {
+					f.name = sf.Name
+				}
+				if hasTagOption(opts, "string") {
+					switch ft.Kind() {
+					case reflect.Bool,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64,
+						reflect.String:
+						f.quoted = true
+					}
+				}
+				fields = append(fields, f)
+			}
+		}
+	}
+	return dominantFields(fields)
+}
+
+// dominantFields returns the fields whose names
+// are not shared by a field that dominates them.
+func dominantFields(fields []reflectField) []reflectField {
+	byName := make(map[string][]reflectField, len(fields))
+	for _, f := range fields {
+		byName[f.name] = append(byName[f.name], f)
+	}
+	out := fields[:0]
+	for _, f := range fields {
+		fs := byName[f.name]
+		if len(fs) == 1 {
+			out = append(out, f)
+			continue
+		}
+		if d, ok := dominantField(fs); ok && sameIndex(d.index, f.index) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// dominantField returns the field that dominates
+// the others fields with the same name, if any.
+func dominantField(fs []reflectField) (reflectField, bool) {
+	depth := len(fs[0].index)
+	for _, f := range fs[1:] {
+		depth = min(depth, len(f.index))
+	}
+	var (
+		dom   reflectField
+		count int
+	)
+	for _, f := range fs {
+		if len(f.index) != depth {
+			continue
+		}
+		switch {
+		case count == 0, f.tagged && !dom.tagged:
+			dom, count = f, 1
+		case f.tagged == dom.tagged:
+			count++
+		}
+	}
+	return dom, count == 1
+}
+
+func sameIndex(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTagOption(opts, name string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type temperature float64
+
+func (c temperature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"unit": "C", "value": float64(c)})
+}
+
+type version struct{ major, minor int }
+
+func (v *version) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("v%d.%d", v.major, v.minor)), nil
+}
+
+type mapKeyID int
+
+func (k mapKeyID) MarshalText() ([]byte, error) {
+	return []byte("id-" + string(rune('a'+k))), nil
+}
+
+type Meta struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type spec struct {
+	Replicas int `json:"replicas"`
+}
+
+type Shadowed struct {
+	Name  string `json:"name"`
+	Extra string
+}
+
+type reflectConfig struct {
+	Meta
+	*spec
+	Shadowed `json:"shadowed"`
+
+	ID        int64             `json:"id,string"`
+	Enabled   *bool             `json:"enabled,omitempty"`
+	Ratio     float32           `json:"ratio"`
+	Tags      []string          `json:"tags"`
+	Data      []byte            `json:"data,omitempty"`
+	Ports     [2]uint16         `json:"ports"`
+	Created   time.Time         `json:"created"`
+	Addr      net.IP            `json:"addr"`
+	Temp      temperature       `json:"temp"`
+	TempPtr   *temperature      `json:"tempPtr"`
+	Version   version           `json:"version"`
+	Any       interface{}       `json:"any"`
+	Number    json.Number       `json:"number"`
+	Limits    map[int]string    `json:"limits,omitempty"`
+	ByID      map[mapKeyID]bool `json:"byId,omitempty"`
+	Quoted    *string           `json:"quoted,string"`
+	Skipped   string            `json:"-"`
+	Dash      string            `json:"-,"`
+	Untagged  string
+	unexposed string
+}
+
+func TestReflectValue(t *testing.T) {
+	var (
+		yes    = true
+		temp   = temperature(21.5)
+		quoted = "foo"
+		now    = time.Now()
+	)
+	for _, v := range []interface{}{
+		nil,
+		true,
+		42,
+		uint8(7),
+		-1.5,
+		float32(0.1),
+		float32(1e-7),
+		1e21,
+		"foo",
+		json.Number("12.50"),
+		[]int{1, 2, 3},
+		[]byte("hello"),
+		map[string]interface{}{"a": []interface{}{1, "b", nil}},
+		map[int]float64{3: 1, -1: 2},
+		(*reflectConfig)(nil),
+		reflectConfig{},
+		&reflectConfig{
+			Meta:     Meta{Name: "web", Labels: map[string]string{"app": "web"}},
+			spec:     &spec{Replicas: 3},
+			Shadowed: Shadowed{Name: "inner", Extra: "x"},
+			ID:       9007199254740993,
+			Enabled:  &yes,
+			Ratio:    0.3,
+			Tags:     []string{"a", "b"},
+			Data:     []byte{0, 1, 2},
+			Ports:    [2]uint16{80, 443},
+			Created:  now,
+			Addr:     net.ParseIP("10.0.0.1"),
+			Temp:     temp,
+			TempPtr:  &temp,
+			Version:  version{1, 2},
+			Any:      []interface{}{map[string]int{"x": 1}},
+			Number:   json.Number("1e3"),
+			Limits:   map[int]string{1: "one"},
+			ByID:     map[mapKeyID]bool{1: true},
+			Quoted:   &quoted,
+			Skipped:  "skipped",
+			Dash:     "dash",
+			Untagged: "untagged",
+		},
+	} {
+		for _, opts := range []options{
+			{marshal: json.Marshal, unmarshal: json.Unmarshal},
+			{marshal: json.Marshal, unmarshal: unmarshalUseNumber, useNumber: true},
+		} {
+			want, _, err := marshalUnmarshal(v, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := reflectValue(v, opts)
+			if err != nil {
+				t.Fatalf("%T: %s", v, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%T (use number: %t): got %#v, want %#v", v, opts.useNumber, got, want)
+			}
+		}
+	}
+}
+
+func TestReflectValue_embeddingConflicts(t *testing.T) {
+	type A struct {
+		X int
+		Y int `json:"y"`
+	}
+	type B struct {
+		X int
+		Y int
+		Z int
+	}
+	type C struct {
+		A
+		B
+		Z int `json:"Z"`
+	}
+	v := C{A: A{X: 1, Y: 2}, B: B{X: 3, Y: 4, Z: 5}, Z: 6}
+
+	want, _, err := marshalUnmarshal(v, options{marshal: json.Marshal, unmarshal: json.Unmarshal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reflectValue(v, options{unmarshal: json.Unmarshal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReflectValue_errors(t *testing.T) {
+	type node struct {
+		Next *node `json:"next"`
+	}
+	n := &node{}
+	n.Next = n
+
+	var (
+		typeErr  *json.UnsupportedTypeError
+		valueErr *json.UnsupportedValueError
+	)
+	opts := options{unmarshal: json.Unmarshal}
+
+	if _, err := reflectValue(map[string]interface{}{"c": make(chan int)}, opts); !errors.As(err, &typeErr) {
+		t.Errorf("got error %v, want unsupported type error", err)
+	}
+	if _, err := reflectValue(n, opts); !errors.As(err, &valueErr) {
+		t.Errorf("got error %v, want unsupported value error", err)
+	}
+	if _, err := reflectValue([]float64{1, math.Inf(1)}, opts); !errors.As(err, &valueErr) {
+		t.Errorf("got error %v, want unsupported value error", err)
+	}
+}
+
+func TestCompareReflect(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := &reflectConfig{
+		Meta:    Meta{Name: "web"},
+		spec:    &spec{Replicas: 1},
+		Tags:    []string{"a", "b", "c"},
+		Created: created,
+		Addr:    net.ParseIP("10.0.0.1"),
+		Temp:    20,
+	}
+	tgt := &reflectConfig{
+		Meta:    Meta{Name: "web", Labels: map[string]string{"app": "web"}},
+		spec:    &spec{Replicas: 3},
+		Tags:    []string{"a", "c"},
+		Created: created.Add(time.Hour),
+		Addr:    net.ParseIP("10.0.0.2"),
+		Temp:    21.5,
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{Factorize(), Rationalize()},
+		{Invertible(), LCS()},
+		{UseNumber()},
+	} {
+		want, err := Compare(src, tgt, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patch, err := CompareReflect(src, tgt, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if patch.String() != want.String() {
+			t.Errorf("got patch:\n%s\nwant:\n%s", patch.String(), want.String())
+		}
+	}
+	// The timestamps that differ only by their
+	// monotonic clock reading are equal.
+	now := time.Now()
+	patch, err := CompareReflect(
+		reflectConfig{Created: now},
+		reflectConfig{Created: now.Round(0)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("expected empty patch, got:\n%s", patch.String())
+	}
+}
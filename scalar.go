@@ -0,0 +1,45 @@
+package jsondiff
+
+// scalarDecoder associates a pattern of pointers with
+// the function that decodes the strings located at the
+// matching pointers to their canonical representation.
+type scalarDecoder struct {
+	pattern pattern
+	decode  func(string) (string, bool)
+}
+
+// decodeScalar returns the canonical representation of the
+// string located at the given pointer, using the decoder of
+// the first matching pattern. It returns false if no pattern
+// matches, or if the decoder failed.
+func decodeScalar(decoders []scalarDecoder, ptr, s string) (string, bool) {
+	for i := range decoders {
+		if decoders[i].pattern.match(ptr) {
+			return decoders[i].decode(s)
+		}
+	}
+	return "", false
+}
+
+// equalScalars returns whether two strings located at
+// the given pointer have the same canonical representation.
+// If one of the strings cannot be decoded, they are not.
+func (d *Differ) equalScalars(ptr string, src, tgt interface{}) bool {
+	ss, ok := src.(string)
+	if !ok {
+		return false
+	}
+	ts, ok := tgt.(string)
+	if !ok {
+		return false
+	}
+	sc, ok := decodeScalar(d.opts.scalars, ptr, ss)
+	if !ok {
+		return false
+	}
+	tc, ok := decodeScalar(d.opts.scalars, ptr, ts)
+	if !ok {
+		return false
+	}
+	return sc == tc
+}
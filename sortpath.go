@@ -0,0 +1,151 @@
+package jsondiff
+
+import (
+	"container/heap"
+	"math"
+	"strings"
+)
+
+// sortOperationsByPath reorders the operations of the patch
+// by path, comparing the array indices numerically. Like for
+// groupArrayOperations, an operation is never moved before
+// another operation it conflicts with, such that the patch
+// remains applicable: the operations are sorted topologically,
+// the dependent operations keeping their relative order, and
+// the operation with the lowest path being picked first among
+// those whose preceding dependencies are all placed.
+func sortOperationsByPath(p Patch) Patch {
+	if len(p) < 2 {
+		return p
+	}
+	// deps holds, for each operation, the number of
+	// preceding operations it depends on, and next the
+	// following operations that depend on it.
+	deps := make([]int, len(p))
+	next := make([][]int, len(p))
+
+	for i := range p {
+		for j := i + 1; j < len(p); j++ {
+			if _, ok := operationsConflict(p[i], p[j]); ok {
+				deps[j]++
+				next[i] = append(next[i], j)
+			}
+		}
+	}
+	q := &pathQueue{patch: p}
+	for i, n := range deps {
+		if n == 0 {
+			q.idx = append(q.idx, i)
+		}
+	}
+	heap.Init(q)
+
+	out := make(Patch, 0, len(p))
+	for q.Len() != 0 {
+		i := heap.Pop(q).(int)
+		out = append(out, p[i])
+
+		for _, j := range next[i] {
+			if deps[j]--; deps[j] == 0 {
+				heap.Push(q, j)
+			}
+		}
+	}
+	return append(p[:0], out...)
+}
+
+// pathQueue is a priority queue of the indices of the
+// operations of a patch, ordered by path, then by index.
+type pathQueue struct {
+	patch Patch
+	idx   []int
+}
+
+func (q *pathQueue) Len() int { return len(q.idx) }
+
+func (q *pathQueue) Less(i, j int) bool {
+	a, b := q.idx[i], q.idx[j]
+	if c := comparePointers(q.patch[a].Path, q.patch[b].Path); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+func (q *pathQueue) Swap(i, j int) { q.idx[i], q.idx[j] = q.idx[j], q.idx[i] }
+
+func (q *pathQueue) Push(x any) { q.idx = append(q.idx, x.(int)) }
+
+func (q *pathQueue) Pop() any {
+	n := len(q.idx) - 1
+	i := q.idx[n]
+	q.idx = q.idx[:n]
+	return i
+}
+
+// comparePointers compares two pointers token by token,
+// such that a pointer sorts before its descendants, and the
+// tokens that are array indices, including the "-" token
+// that follows all of them, are compared numerically, while
+// the other tokens are compared lexicographically, and sort
+// after the indices.
+func comparePointers(p, q string) int {
+	for {
+		if p == q {
+			return 0
+		}
+		if p == emptyPointer {
+			return -1
+		}
+		if q == emptyPointer {
+			return 1
+		}
+		var tp, tq string
+		tp, p = nextToken(p)
+		tq, q = nextToken(q)
+
+		if c := compareTokens(tp, tq); c != 0 {
+			return c
+		}
+	}
+}
+
+// nextToken returns the first reference token
+// of the pointer, and the rest of the pointer.
+func nextToken(p string) (string, string) {
+	p = p[1:] // leading separator
+	if i := strings.IndexByte(p, separator); i != -1 {
+		return p[:i], p[i:]
+	}
+	return p, emptyPointer
+}
+
+func compareTokens(a, b string) int {
+	ia, aok := tokenIndex(a)
+	ib, bok := tokenIndex(b)
+
+	switch {
+	case aok && bok:
+		switch {
+		case ia < ib:
+			return -1
+		case ia > ib:
+			return 1
+		}
+		return 0
+	case aok:
+		return -1
+	case bok:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// tokenIndex returns the array index represented
+// by the reference token, if any, the "-" token
+// being greater than all the indices.
+func tokenIndex(s string) (int, bool) {
+	if s == "-" {
+		return math.MaxInt, true
+	}
+	return parseIndex(s)
+}
@@ -0,0 +1,89 @@
+package jsondiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_sortOperationsByPath(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		patch Patch
+		want  []string
+	}{
+		{
+			name: "independent operations",
+			patch: Patch{
+				{Type: OperationReplace, Path: "/b/10"},
+				{Type: OperationAdd, Path: "/a~1b", Value: 1},
+				{Type: OperationReplace, Path: "/b/9"},
+				{Type: OperationRemove, Path: "/a"},
+			},
+			want: []string{"/a", "/a~1b", "/b/9", "/b/10"},
+		},
+		{
+			name: "shifted indices",
+			patch: Patch{
+				{Type: OperationRemove, Path: "/list/0"},
+				{Type: OperationReplace, Path: "/list/3", Value: 1},
+				{Type: OperationRemove, Path: "/list/1"},
+				{Type: OperationReplace, Path: "/a", Value: 1},
+			},
+			want: []string{"/a", "/list/0", "/list/3", "/list/1"},
+		},
+		{
+			name: "moved values",
+			patch: Patch{
+				{Type: OperationMove, From: "/z", Path: "/y"},
+				{Type: OperationReplace, Path: "/y/k", Value: 1},
+				{Type: OperationAdd, Path: "/z", Value: 1},
+				{Type: OperationReplace, Path: "/b", Value: 1},
+			},
+			want: []string{"/b", "/y", "/y/k", "/z"},
+		},
+		{
+			name: "tested values",
+			patch: Patch{
+				{Type: OperationTest, Path: "/b", Value: 1},
+				{Type: OperationReplace, Path: "/b", Value: 2},
+				{Type: OperationTest, Path: "/a", Value: 1},
+				{Type: OperationRemove, Path: "/a"},
+			},
+			want: []string{"/a", "/a", "/b", "/b"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var paths []string
+			for _, op := range sortOperationsByPath(tc.patch) {
+				paths = append(paths, op.Path)
+			}
+			if !reflect.DeepEqual(paths, tc.want) {
+				t.Errorf("got paths %q, want %q", paths, tc.want)
+			}
+		})
+	}
+}
+
+func Test_comparePointers(t *testing.T) {
+	for _, tc := range []struct {
+		p, q string
+		want int
+	}{
+		{"", "", 0},
+		{"", "/a", -1},
+		{"/a", "/a/b", -1},
+		{"/a/2", "/a/10", -1},
+		{"/a/10", "/a/-", -1},
+		{"/a/-", "/a/b", -1},
+		{"/a/b", "/a/a", 1},
+		{"/a~1b", "/a/b", 1},
+		{"//", "/", 1},
+	} {
+		if got := comparePointers(tc.p, tc.q); got != tc.want {
+			t.Errorf("comparePointers(%q, %q): got %d, want %d", tc.p, tc.q, got, tc.want)
+		}
+		if got := comparePointers(tc.q, tc.p); got != -tc.want {
+			t.Errorf("comparePointers(%q, %q): got %d, want %d", tc.q, tc.p, got, -tc.want)
+		}
+	}
+}
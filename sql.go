@@ -0,0 +1,315 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SQLDialect represents the dialect of the
+// SQL expressions rendered by Patch.SQLUpdate.
+type SQLDialect int
+
+// Supported SQL dialects.
+const (
+	// PostgreSQL renders the patch as calls of
+	// the functions and operators of the jsonb type.
+	PostgreSQL SQLDialect = iota
+
+	// MySQL renders the patch as calls of the JSON
+	// functions of MySQL 8.0.14 or later versions.
+	MySQL
+)
+
+// placeholder marks the position of a bound argument
+// in an expression, until it is rendered in the syntax
+// of the dialect.
+const placeholder = '\x00'
+
+var errSQLInvalidColumn = errors.New("jsondiff: invalid SQL column")
+
+// SQLUpdate renders the patch as a parameterized SQL
+// expression that evaluates to the value of the given
+// JSON column after the application of the operations,
+// suitable for use in the SET clause of an UPDATE
+// statement. The column is rendered verbatim, and must
+// be quoted by the caller if required. The values and
+// the paths of the operations are returned as bound
+// arguments, in the order of their placeholders.
+//
+// Since the document is unknown, a numeric reference
+// token of the location of an add operation is assumed
+// to be the index of an array element. The test
+// operations cannot be evaluated by an expression and
+// are reported as an error.
+func (p Patch) SQLUpdate(dialect SQLDialect, column string) (expr string, args []interface{}, err error) {
+	if column == "" || strings.IndexByte(column, placeholder) != -1 {
+		return "", nil, errSQLInvalidColumn
+	}
+	var r sqlRenderer
+	switch dialect {
+	case PostgreSQL:
+		r = postgresRenderer{}
+	case MySQL:
+		r = mysqlRenderer{}
+	default:
+		return "", nil, fmt.Errorf("jsondiff: unknown SQL dialect %d", dialect)
+	}
+	e := sqlExpr{sql: column}
+
+	for _, op := range p {
+		path, err := parsePointer(op.Path)
+		if err != nil {
+			return "", nil, fmt.Errorf("jsondiff: invalid path %q: %w", op.Path, err)
+		}
+		unescapeTokens(path)
+
+		switch op.Type {
+		case OperationAdd, OperationReplace:
+			b, err := json.Marshal(op.Value)
+			if err != nil {
+				return "", nil, err
+			}
+			v := r.value(string(b))
+			if op.Type == OperationAdd {
+				e = r.add(e, path, v)
+			} else {
+				e = r.replace(e, path, v)
+			}
+		case OperationRemove:
+			if len(path) == 0 {
+				return "", nil, errors.New("jsondiff: cannot render removal of the root value")
+			}
+			e = r.remove(e, path)
+		case OperationMove, OperationCopy:
+			from, err := parsePointer(op.From)
+			if err != nil {
+				return "", nil, fmt.Errorf("jsondiff: invalid from %q: %w", op.From, err)
+			}
+			unescapeTokens(from)
+			e = r.transfer(e, from, path, op.Type == OperationMove)
+		default:
+			return "", nil, fmt.Errorf("jsondiff: cannot render %s operation as SQL", op.Type)
+		}
+	}
+	return r.render(e), e.args, nil
+}
+
+func unescapeTokens(tokens []string) {
+	for i, t := range tokens {
+		tokens[i] = rfc6901Unescaper.Replace(t)
+	}
+}
+
+// sqlExpr represents a SQL expression and the
+// arguments bound to its placeholders, in order.
+type sqlExpr struct {
+	sql  string
+	args []interface{}
+}
+
+// sqlf formats an expression by replacing each %s
+// verb of the format with the SQL of the corresponding
+// sub-expression, whose arguments are appended in order.
+func sqlf(format string, subs ...sqlExpr) sqlExpr {
+	var (
+		e     sqlExpr
+		sb    strings.Builder
+		parts = strings.Split(format, "%s")
+	)
+	for i, p := range parts {
+		sb.WriteString(p)
+		if i < len(subs) {
+			sb.WriteString(subs[i].sql)
+			e.args = append(e.args, subs[i].args...)
+		}
+	}
+	e.sql = sb.String()
+
+	return e
+}
+
+// arg returns an expression made of a single
+// placeholder bound to the value, with an
+// optional cast suffix.
+func arg(v interface{}, cast string) sqlExpr {
+	return sqlExpr{
+		sql:  string(placeholder) + cast,
+		args: []interface{}{v},
+	}
+}
+
+// sqlRenderer renders the operations of a patch as
+// the functions of a dialect. Each method returns a
+// new expression that applies the operation to the
+// value of the expression e.
+type sqlRenderer interface {
+	value(json string) sqlExpr
+	add(e sqlExpr, path []string, v sqlExpr) sqlExpr
+	replace(e sqlExpr, path []string, v sqlExpr) sqlExpr
+	remove(e sqlExpr, path []string) sqlExpr
+	transfer(e sqlExpr, from, path []string, move bool) sqlExpr
+	render(e sqlExpr) string
+}
+
+// subquery is the format of the expression of a move
+// or copy operation, which binds the current value to
+// the alias v, to reference it twice without repeating
+// the expression that computes it.
+const subquery = "(SELECT %s FROM (SELECT %s AS v) AS t)"
+
+// isArrayToken returns whether the reference token
+// is assumed to designate an array element.
+func isArrayToken(s string) bool {
+	_, ok := parseIndex(s)
+	return ok || s == "-"
+}
+
+type postgresRenderer struct{}
+
+func (postgresRenderer) path(path []string) sqlExpr {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, t := range path {
+		if i != 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('"')
+		for _, c := range []byte(t) {
+			if c == '"' || c == '\\' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(c)
+		}
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+
+	return arg(sb.String(), "::text[]")
+}
+
+func (postgresRenderer) value(json string) sqlExpr {
+	return arg(json, "::jsonb")
+}
+
+func (r postgresRenderer) add(e sqlExpr, path []string, v sqlExpr) sqlExpr {
+	if len(path) == 0 {
+		return v
+	}
+	last := path[len(path)-1]
+	if !isArrayToken(last) {
+		return sqlf("jsonb_set(%s, %s, %s, true)", e, r.path(path), v)
+	}
+	if last == "-" {
+		// Insert after the last element.
+		p := append(path[:len(path)-1:len(path)-1], "-1")
+		return sqlf("jsonb_insert(%s, %s, %s, true)", e, r.path(p), v)
+	}
+	return sqlf("jsonb_insert(%s, %s, %s)", e, r.path(path), v)
+}
+
+func (r postgresRenderer) replace(e sqlExpr, path []string, v sqlExpr) sqlExpr {
+	if len(path) == 0 {
+		return v
+	}
+	return sqlf("jsonb_set(%s, %s, %s, false)", e, r.path(path), v)
+}
+
+func (r postgresRenderer) remove(e sqlExpr, path []string) sqlExpr {
+	return sqlf("(%s #- %s)", e, r.path(path))
+}
+
+func (r postgresRenderer) transfer(e sqlExpr, from, path []string, move bool) sqlExpr {
+	v := sqlExpr{sql: "v"}
+	val := sqlf("(v #> %s)", r.path(from))
+	if move {
+		v = r.remove(v, from)
+	}
+	return sqlf(subquery, r.add(v, path, val), e)
+}
+
+func (postgresRenderer) render(e sqlExpr) string {
+	var (
+		sb strings.Builder
+		n  int
+	)
+	for _, c := range []byte(e.sql) {
+		if c == placeholder {
+			n++
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(n))
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+type mysqlRenderer struct{}
+
+func (mysqlRenderer) path(path []string) sqlExpr {
+	var sb strings.Builder
+	sb.WriteByte('$')
+	for _, t := range path {
+		if n, ok := parseIndex(t); ok {
+			sb.WriteByte('[')
+			sb.WriteString(strconv.Itoa(n))
+			sb.WriteByte(']')
+			continue
+		}
+		sb.WriteString(`."`)
+		for _, c := range []byte(t) {
+			if c == '"' || c == '\\' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(c)
+		}
+		sb.WriteByte('"')
+	}
+	return arg(sb.String(), "")
+}
+
+func (mysqlRenderer) value(json string) sqlExpr {
+	return sqlf("CAST(%s AS JSON)", arg(json, ""))
+}
+
+func (r mysqlRenderer) add(e sqlExpr, path []string, v sqlExpr) sqlExpr {
+	if len(path) == 0 {
+		return v
+	}
+	last := path[len(path)-1]
+	switch {
+	case last == "-":
+		return sqlf("JSON_ARRAY_APPEND(%s, %s, %s)", e, r.path(path[:len(path)-1]), v)
+	case isArrayToken(last):
+		return sqlf("JSON_ARRAY_INSERT(%s, %s, %s)", e, r.path(path), v)
+	default:
+		return sqlf("JSON_SET(%s, %s, %s)", e, r.path(path), v)
+	}
+}
+
+func (r mysqlRenderer) replace(e sqlExpr, path []string, v sqlExpr) sqlExpr {
+	if len(path) == 0 {
+		return v
+	}
+	return sqlf("JSON_REPLACE(%s, %s, %s)", e, r.path(path), v)
+}
+
+func (r mysqlRenderer) remove(e sqlExpr, path []string) sqlExpr {
+	return sqlf("JSON_REMOVE(%s, %s)", e, r.path(path))
+}
+
+func (r mysqlRenderer) transfer(e sqlExpr, from, path []string, move bool) sqlExpr {
+	v := sqlExpr{sql: "v"}
+	val := sqlf("JSON_EXTRACT(v, %s)", r.path(from))
+	if move {
+		v = r.remove(v, from)
+	}
+	return sqlf(subquery, r.add(v, path, val), e)
+}
+
+func (mysqlRenderer) render(e sqlExpr) string {
+	return strings.ReplaceAll(e.sql, string(placeholder), "?")
+}
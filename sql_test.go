@@ -0,0 +1,110 @@
+package jsondiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPatch_SQLUpdate(t *testing.T) {
+	patch := Patch{
+		{Type: OperationReplace, Path: "/a", Value: "x"},
+		{Type: OperationAdd, Path: "/b/1", Value: []interface{}{1, 2}},
+		{Type: OperationAdd, Path: "/b/-", Value: true},
+		{Type: OperationAdd, Path: "/c~1d", Value: map[string]interface{}{"e": nil}},
+		{Type: OperationRemove, Path: "/f/0"},
+		{Type: OperationMove, From: "/g", Path: "/h"},
+		{Type: OperationCopy, From: `/i"j`, Path: "/k"},
+	}
+	for _, tc := range []struct {
+		name    string
+		dialect SQLDialect
+		expr    string
+		args    []interface{}
+	}{
+		{
+			"postgresql",
+			PostgreSQL,
+			`(SELECT jsonb_set(v, $1::text[], (v #> $2::text[]), true) FROM (SELECT ` +
+				`(SELECT jsonb_set((v #- $3::text[]), $4::text[], (v #> $5::text[]), true) FROM (SELECT ` +
+				`(jsonb_set(jsonb_insert(jsonb_insert(jsonb_set(doc, $6::text[], $7::jsonb, false), ` +
+				`$8::text[], $9::jsonb), $10::text[], $11::jsonb, true), $12::text[], $13::jsonb, true) #- $14::text[]) ` +
+				`AS v) AS t) AS v) AS t)`,
+			[]interface{}{
+				`{"k"}`, `{"i\"j"}`,
+				`{"g"}`, `{"h"}`, `{"g"}`,
+				`{"a"}`, `"x"`,
+				`{"b","1"}`, `[1,2]`,
+				`{"b","-1"}`, `true`,
+				`{"c/d"}`, `{"e":null}`,
+				`{"f","0"}`,
+			},
+		},
+		{
+			"mysql",
+			MySQL,
+			`(SELECT JSON_SET(v, ?, JSON_EXTRACT(v, ?)) FROM (SELECT ` +
+				`(SELECT JSON_SET(JSON_REMOVE(v, ?), ?, JSON_EXTRACT(v, ?)) FROM (SELECT ` +
+				`JSON_REMOVE(JSON_SET(JSON_ARRAY_APPEND(JSON_ARRAY_INSERT(JSON_REPLACE(doc, ?, CAST(? AS JSON)), ` +
+				`?, CAST(? AS JSON)), ?, CAST(? AS JSON)), ?, CAST(? AS JSON)), ?) ` +
+				`AS v) AS t) AS v) AS t)`,
+			[]interface{}{
+				`$."k"`, `$."i\"j"`,
+				`$."g"`, `$."h"`, `$."g"`,
+				`$."a"`, `"x"`,
+				`$."b"[1]`, `[1,2]`,
+				`$."b"`, `true`,
+				`$."c/d"`, `{"e":null}`,
+				`$."f"[0]`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, args, err := patch.SQLUpdate(tc.dialect, "doc")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expr != tc.expr {
+				t.Errorf("expression mismatch:\ngot:  %s\nwant: %s", expr, tc.expr)
+			}
+			if !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("arguments mismatch:\ngot:  %q\nwant: %q", args, tc.args)
+			}
+		})
+	}
+}
+
+func TestPatch_SQLUpdate_root(t *testing.T) {
+	patch := Patch{{Type: OperationReplace, Path: "", Value: []interface{}{}}}
+
+	for dialect, want := range map[SQLDialect]string{
+		PostgreSQL: "$1::jsonb",
+		MySQL:      "CAST(? AS JSON)",
+	} {
+		expr, args, err := patch.SQLUpdate(dialect, "doc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expr != want || len(args) != 1 || args[0] != "[]" {
+			t.Errorf("got (%s, %q), want %s", expr, args, want)
+		}
+	}
+}
+
+func TestPatch_SQLUpdate_errors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		patch   Patch
+		dialect SQLDialect
+		column  string
+	}{
+		{"test operation", Patch{{Type: OperationTest, Path: "/a", Value: 1}}, PostgreSQL, "doc"},
+		{"root removal", Patch{{Type: OperationRemove, Path: ""}}, MySQL, "doc"},
+		{"invalid path", Patch{{Type: OperationRemove, Path: "a"}}, MySQL, "doc"},
+		{"unknown dialect", nil, SQLDialect(42), "doc"},
+		{"empty column", nil, PostgreSQL, ""},
+	} {
+		if _, _, err := tc.patch.SQLUpdate(tc.dialect, tc.column); err == nil {
+			t.Errorf("%s: expected non-nil error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package jsondiff
+
+// PatchStats represents the statistics of a patch.
+type PatchStats struct {
+	Adds     int
+	Removes  int
+	Replaces int
+	Moves    int
+	Copies   int
+	Tests    int
+
+	// Size is the estimated length in bytes
+	// of the JSON representation of the patch.
+	Size int
+}
+
+// Operations returns the total number of operations.
+func (s PatchStats) Operations() int {
+	return s.Adds + s.Removes + s.Replaces + s.Moves + s.Copies + s.Tests
+}
+
+// Stats returns the number of operations of each type of
+// the patch, and the length in bytes of its JSON representation,
+// which is computed without marshaling the operations.
+func (p Patch) Stats() PatchStats {
+	var s PatchStats
+	for _, op := range p {
+		s.add(op)
+	}
+	return s
+}
+
+func (s *PatchStats) add(op Operation) {
+	switch op.Type {
+	case OperationAdd:
+		s.Adds++
+	case OperationRemove:
+		s.Removes++
+	case OperationReplace:
+		s.Replaces++
+	case OperationMove:
+		s.Moves++
+	case OperationCopy:
+		s.Copies++
+	case OperationTest:
+		s.Tests++
+	}
+	if s.Operations() > 1 {
+		s.Size++ // comma separator
+	} else {
+		s.Size += len("[]")
+	}
+	if op.marshalWithValue() {
+		op.valueLen = valueLength(op.Value)
+	}
+	s.Size += op.jsonLength()
+}
+
+// merge adds the statistics of another
+// patch to those of the patch.
+func (s *PatchStats) merge(o PatchStats) {
+	if o.Operations() == 0 {
+		return
+	}
+	if s.Operations() == 0 {
+		*s = o
+		return
+	}
+	s.Adds += o.Adds
+	s.Removes += o.Removes
+	s.Replaces += o.Replaces
+	s.Moves += o.Moves
+	s.Copies += o.Copies
+	s.Tests += o.Tests
+	s.Size += o.Size - len("[]") + 1 // comma separator
+}
+
+// OperationSize represents the length in bytes of the JSON
+// representation of the values changed by an operation.
+type OperationSize struct {
+	// OldSize is the length of the value
+	// removed or replaced by the operation.
+	OldSize int
+
+	// NewSize is the length of the value
+	// carried by the operation.
+	NewSize int
+}
+
+// operationSize returns the sizes of the values of the
+// operation. The values of the move and copy operations
+// are not part of their representation, and the sizes of
+// these operations are zero.
+func operationSize(op Operation) OperationSize {
+	var s OperationSize
+	switch op.Type {
+	case OperationAdd, OperationTest:
+		s.NewSize = valueLength(op.Value)
+	case OperationRemove:
+		s.OldSize = valueLength(op.OldValue)
+	case OperationReplace:
+		s.OldSize = valueLength(op.OldValue)
+		s.NewSize = valueLength(op.Value)
+	}
+	return s
+}
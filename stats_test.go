@@ -0,0 +1,129 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiffer_Stats(t *testing.T) {
+	src := map[string]interface{}{
+		"a": "foo",
+		"b": []interface{}{1.0, 2.0, 3.0},
+		"c": map[string]interface{}{"d": true, "e": nil},
+	}
+	tgt := map[string]interface{}{
+		"a": "bar",
+		"b": []interface{}{1.0},
+		"c": map[string]interface{}{"d": false, "f": "new"},
+		"g": []interface{}{"x", map[string]interface{}{"y": 1.5}},
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{Invertible()},
+	} {
+		d := (&Differ{}).WithOpts(opts...)
+		d.Compare(src, tgt)
+
+		want := PatchStats{Adds: 2, Removes: 3, Replaces: 2}
+		if d.opts.invertible {
+			want.Tests = 5
+		}
+		b, err := json.Marshal(d.Patch())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Size = len(b)
+
+		if s := d.Stats(); s != want {
+			t.Errorf("got stats %+v, want %+v", s, want)
+		}
+		dr := (&Differ{}).WithOpts(append(opts, DryRun(), Factorize(), Rationalize())...)
+		dr.Compare(src, tgt)
+
+		if l := len(dr.Patch()); l != 0 {
+			t.Errorf("expected empty patch in dry-run mode, got %d operations", l)
+		}
+		if s := dr.Stats(); s != want {
+			t.Errorf("dry-run: got stats %+v, want %+v", s, want)
+		}
+		if n := dr.Stats().Operations(); n != len(d.Patch()) {
+			t.Errorf("got %d operations, want %d", n, len(d.Patch()))
+		}
+		dr.Reset()
+		if s := dr.Stats(); s != (PatchStats{}) {
+			t.Errorf("expected empty stats after reset, got %+v", s)
+		}
+	}
+}
+
+func TestPatch_Stats(t *testing.T) {
+	var p Patch
+	err := json.Unmarshal([]byte(`[
+		{"op":"test","path":"/a","value":"foo"},
+		{"op":"replace","path":"/a","value":"bar"},
+		{"op":"add","path":"/b/-","value":{"c":[1,2,"x"]}},
+		{"op":"remove","path":"/d/0"},
+		{"op":"move","from":"/e","path":"/f"},
+		{"op":"copy","from":"/f","path":"/g~1h"}
+	]`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PatchStats{
+		Adds:     1,
+		Removes:  1,
+		Replaces: 1,
+		Moves:    1,
+		Copies:   1,
+		Tests:    1,
+		Size:     len(b),
+	}
+	if s := p.Stats(); s != want {
+		t.Errorf("got stats %+v, want %+v", s, want)
+	}
+	if s := Patch(nil).Stats(); s != (PatchStats{}) {
+		t.Errorf("expected empty stats for empty patch, got %+v", s)
+	}
+}
+
+func TestDiffer_SizeMetrics(t *testing.T) {
+	src := map[string]interface{}{
+		"a": "foo",
+		"b": []interface{}{1.0, 2.0, 3.0},
+		"c": map[string]interface{}{"d": "long value"},
+	}
+	tgt := map[string]interface{}{
+		"a": "barbaz",
+		"b": []interface{}{1.0, 2.0},
+		"e": map[string]interface{}{"d": "long value"},
+		"f": true,
+	}
+	d := (&Differ{}).WithOpts(WithSizeMetrics(), Factorize())
+	d.Compare(src, tgt)
+
+	want := map[string]OperationSize{
+		OperationReplace: {OldSize: len(`"foo"`), NewSize: len(`"barbaz"`)},
+		OperationRemove:  {OldSize: len(`3`)},
+		OperationMove:    {},
+		OperationAdd:     {NewSize: len(`true`)},
+	}
+	if len(d.Patch()) != len(want) {
+		t.Fatalf("got %d operations, want %d:\n%s", len(d.Patch()), len(want), d.Patch())
+	}
+	sizes := d.SizeMetrics()
+	if len(sizes) != len(d.Patch()) {
+		t.Fatalf("got %d sizes, want %d", len(sizes), len(d.Patch()))
+	}
+	for i, op := range d.Patch() {
+		if sizes[i] != want[op.Type] {
+			t.Errorf("op %s: got sizes %+v, want %+v", op, sizes[i], want[op.Type])
+		}
+	}
+	if s := (&Differ{}).SizeMetrics(); s != nil {
+		t.Errorf("expected nil sizes without option, got %v", s)
+	}
+}
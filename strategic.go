@@ -0,0 +1,210 @@
+package jsondiff
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Directives of the Kubernetes strategic merge patch format.
+// https://github.com/kubernetes/community/blob/master/contributors/devel/sig-api-machinery/strategic-merge-patch.md
+const (
+	smpPatchDirective = "$patch"
+	smpDeleteValue    = "delete"
+	smpSetOrderPrefix = "$setElementOrder/"
+)
+
+var errStrategicNotObject = errors.New("jsondiff: strategic merge patch requires JSON objects")
+
+// mergeKey associates the pattern of the pointers of a
+// list of objects with the key that identifies its elements.
+type mergeKey struct {
+	pattern pattern
+	key     string
+}
+
+// StrategicMergePatch compares the JSON representations of
+// the given values, which must be JSON objects, and returns
+// the differences as a Kubernetes strategic merge patch.
+//
+// The keys map associates the pointers of lists of objects
+// with the name of the key that identifies their elements,
+// such as "/spec/containers" with "name". The pointers are
+// patterns, such as "/spec/containers/*/env" (see Ignores).
+// Such lists are merged by key: the patch lists the changes of
+// the elements that exist in both values and the new elements,
+// as well as the removal of the others with a "delete"
+// directive, and the order of the target elements with a
+// "$setElementOrder" directive.
+// The other lists are replaced as a whole. Note that the format
+// cannot represent the replacement of a value by null, which is
+// indistinguishable from the removal of the key.
+func StrategicMergePatch(source, target interface{}, keys map[string]string, opts ...Option) ([]byte, error) {
+	var d Differ
+	d.applyOpts(opts...)
+	d.opts.setDefaultCodec()
+
+	src, _, err := marshalUnmarshal(source, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	tgt, _, err := marshalUnmarshal(target, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	so, ok := src.(map[string]interface{})
+	if !ok {
+		return nil, errStrategicNotObject
+	}
+	to, ok := tgt.(map[string]interface{})
+	if !ok {
+		return nil, errStrategicNotObject
+	}
+	// Sort the pointers of the lists, to
+	// make the first match deterministic.
+	ptrs := make([]string, 0, len(keys))
+	for p := range keys {
+		ptrs = append(ptrs, p)
+	}
+	sortStrings(ptrs)
+
+	mks := make([]mergeKey, len(ptrs))
+	for i, p := range ptrs {
+		mks[i] = mergeKey{
+			pattern: compilePattern(p),
+			key:     keys[p],
+		}
+	}
+	return d.opts.marshal(strategicObjects(mks, emptyPointer, so, to))
+}
+
+// strategicObjects returns the strategic merge patch
+// of two objects located at the given pointer.
+func strategicObjects(mks []mergeKey, ptr string, src, tgt map[string]interface{}) map[string]interface{} {
+	patch := make(map[string]interface{})
+
+	for k := range src {
+		if _, ok := tgt[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	for k, tv := range tgt {
+		sv, ok := src[k]
+		if !ok {
+			patch[k] = tv
+			continue
+		}
+		if deepEqual(sv, tv) {
+			continue
+		}
+		p := ptr + string(separator) + rfc6901Escaper.Replace(k)
+
+		switch sv := sv.(type) {
+		case map[string]interface{}:
+			if to, ok := tv.(map[string]interface{}); ok {
+				patch[k] = strategicObjects(mks, p, sv, to)
+				continue
+			}
+		case []interface{}:
+			ta, ok := tv.([]interface{})
+			if !ok {
+				break
+			}
+			key, ok := findMergeKey(mks, p)
+			if !ok {
+				break
+			}
+			list, order, ok := strategicList(mks, p, key, sv, ta)
+			if !ok {
+				break
+			}
+			if len(list) != 0 {
+				patch[k] = list
+			}
+			patch[smpSetOrderPrefix+k] = order
+			continue
+		}
+		patch[k] = tv
+	}
+	return patch
+}
+
+// strategicList returns the strategic merge patch of two
+// lists of objects whose elements are identified by the
+// given key, and the order directive of the target list.
+// It returns false if one of the elements is not an object
+// with a unique scalar value for the key, in which case the
+// list must be replaced as a whole.
+func strategicList(mks []mergeKey, ptr, key string, src, tgt []interface{}) ([]interface{}, []interface{}, bool) {
+	sidx, ok := indexByMergeKey(src, key)
+	if !ok {
+		return nil, nil, false
+	}
+	tidx, ok := indexByMergeKey(tgt, key)
+	if !ok {
+		return nil, nil, false
+	}
+	var (
+		list  []interface{}
+		order = make([]interface{}, 0, len(tgt))
+	)
+	for _, e := range tgt {
+		te := e.(map[string]interface{})
+		kv := te[key]
+		order = append(order, map[string]interface{}{key: kv})
+
+		i, ok := sidx[kv]
+		if !ok {
+			list = append(list, te)
+			continue
+		}
+		if se := src[i].(map[string]interface{}); !deepEqual(se, te) {
+			p := ptr + string(separator) + strconv.Itoa(i)
+			m := strategicObjects(mks, p, se, te)
+			m[key] = kv
+			list = append(list, m)
+		}
+	}
+	for _, e := range src {
+		kv := e.(map[string]interface{})[key]
+		if _, ok := tidx[kv]; !ok {
+			list = append(list, map[string]interface{}{
+				key:               kv,
+				smpPatchDirective: smpDeleteValue,
+			})
+		}
+	}
+	return list, order, true
+}
+
+// indexByMergeKey returns the indices of the elements of
+// the list indexed by their value for the given key.
+func indexByMergeKey(list []interface{}, key string) (map[interface{}]int, bool) {
+	idx := make(map[interface{}]int, len(list))
+
+	for i, e := range list {
+		o, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		kv, ok := o[key]
+		if !ok || isContainer(kv) {
+			return nil, false
+		}
+		if _, dup := idx[kv]; dup {
+			return nil, false
+		}
+		idx[kv] = i
+	}
+	return idx, true
+}
+
+// findMergeKey returns the key that identifies the
+// elements of the list located at the given pointer.
+func findMergeKey(mks []mergeKey, ptr string) (string, bool) {
+	for i := range mks {
+		if mks[i].pattern.match(ptr) {
+			return mks[i].key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,125 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStrategicMergePatch(t *testing.T) {
+	keys := map[string]string{
+		"/spec/containers":       "name",
+		"/spec/containers/*/env": "name",
+	}
+	for _, tc := range []struct {
+		name   string
+		src    string
+		tgt    string
+		expect string
+	}{
+		{
+			name:   "identical",
+			src:    `{"a":1,"spec":{"containers":[{"name":"a"}]}}`,
+			tgt:    `{"a":1,"spec":{"containers":[{"name":"a"}]}}`,
+			expect: `{}`,
+		},
+		{
+			name:   "object fields",
+			src:    `{"metadata":{"name":"app","labels":{"a":"1","b":"2"}},"x":[1,2]}`,
+			tgt:    `{"metadata":{"name":"app","labels":{"a":"3","c":"4"}},"x":[2]}`,
+			expect: `{"metadata":{"labels":{"a":"3","b":null,"c":"4"}},"x":[2]}`,
+		},
+		{
+			name: "list merged by key",
+			src: `{"spec":{"containers":[
+				{"name":"app","image":"app:1","ports":[80]},
+				{"name":"sidecar","image":"proxy:1"}
+			]}}`,
+			tgt: `{"spec":{"containers":[
+				{"name":"app","image":"app:2","ports":[80]},
+				{"name":"logger","image":"log:1"}
+			]}}`,
+			expect: `{"spec":{
+				"$setElementOrder/containers":[{"name":"app"},{"name":"logger"}],
+				"containers":[
+					{"name":"app","image":"app:2"},
+					{"name":"logger","image":"log:1"},
+					{"name":"sidecar","$patch":"delete"}
+				]
+			}}`,
+		},
+		{
+			name: "nested list merged by key",
+			src: `{"spec":{"containers":[
+				{"name":"app","env":[{"name":"A","value":"1"},{"name":"B","value":"2"}]}
+			]}}`,
+			tgt: `{"spec":{"containers":[
+				{"name":"app","env":[{"name":"B","value":"3"},{"name":"A","value":"1"}]}
+			]}}`,
+			expect: `{"spec":{
+				"$setElementOrder/containers":[{"name":"app"}],
+				"containers":[{
+					"name":"app",
+					"$setElementOrder/env":[{"name":"B"},{"name":"A"}],
+					"env":[{"name":"B","value":"3"}]
+				}]
+			}}`,
+		},
+		{
+			name: "reordered list",
+			src:  `{"spec":{"containers":[{"name":"a"},{"name":"b"}]}}`,
+			tgt:  `{"spec":{"containers":[{"name":"b"},{"name":"a"}]}}`,
+			expect: `{"spec":{
+				"$setElementOrder/containers":[{"name":"b"},{"name":"a"}]
+			}}`,
+		},
+		{
+			name:   "list without merge key is replaced",
+			src:    `{"spec":{"containers":[{"name":"a"},{"image":"b"}]}}`,
+			tgt:    `{"spec":{"containers":[{"name":"a"}]}}`,
+			expect: `{"spec":{"containers":[{"name":"a"}]}}`,
+		},
+		{
+			name:   "duplicate merge keys",
+			src:    `{"spec":{"containers":[{"name":"a"},{"name":"a"}]}}`,
+			tgt:    `{"spec":{"containers":[{"name":"a"}]}}`,
+			expect: `{"spec":{"containers":[{"name":"a"}]}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var src, tgt, want, got interface{}
+			for _, v := range []struct {
+				s string
+				i *interface{}
+			}{{tc.src, &src}, {tc.tgt, &tgt}, {tc.expect, &want}} {
+				if err := json.Unmarshal([]byte(v.s), v.i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := StrategicMergePatch(src, tgt, keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("patch mismatch:\ngot:  %s\nwant: %s", b, tc.expect)
+			}
+		})
+	}
+}
+
+func TestStrategicMergePatch_error(t *testing.T) {
+	for _, tc := range [][2]interface{}{
+		{[]interface{}{}, map[string]interface{}{}},
+		{map[string]interface{}{}, "foo"},
+	} {
+		if _, err := StrategicMergePatch(tc[0], tc[1], nil); err == nil {
+			t.Error("expected non-nil error")
+		}
+	}
+	if _, err := StrategicMergePatch(func() {}, nil, nil); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
@@ -0,0 +1,85 @@
+package jsondiff
+
+import (
+	"context"
+	"time"
+)
+
+// deadlineCheckInterval is the number of containers visited
+// between two verifications of the deadline of a comparison,
+// which amortizes the cost of reading the clock.
+const deadlineCheckInterval = 64
+
+// startDeadline sets the deadline of the
+// comparison if the WithTimeout option is set.
+func (d *Differ) startDeadline() {
+	d.ticks = 0
+	if d.opts.timeout > 0 {
+		d.deadline = time.Now().Add(d.opts.timeout)
+	}
+}
+
+// expired returns whether the comparison must be aborted
+// because its deadline is exceeded, or its context is done.
+// It is called at the boundaries of the objects and arrays,
+// and reads the clock and the context periodically, such that
+// the comparison is aborted once the next check takes place.
+func (d *Differ) expired() bool {
+	if d.opts.timeout <= 0 && d.ctx == nil {
+		return false
+	}
+	if d.err != nil {
+		return d.interrupted()
+	}
+	if d.ticks++; d.ticks%deadlineCheckInterval != 0 {
+		return false
+	}
+	if d.ctx != nil {
+		if err := d.ctx.Err(); err != nil {
+			d.err = err
+			return true
+		}
+	}
+	if d.opts.timeout > 0 && time.Now().After(d.deadline) {
+		d.err = ErrTimeout
+		return true
+	}
+	return false
+}
+
+// interrupted returns whether the comparison has been
+// aborted by its timeout or the cancellation of its context.
+func (d *Differ) interrupted() bool {
+	return d.err != nil && (d.err == ErrTimeout || d.ctx != nil && d.err == d.ctx.Err())
+}
+
+// abort discards the partial results
+// of an interrupted comparison.
+func (d *Differ) abort() {
+	d.patch = d.patch[:0]
+	d.stats = PatchStats{}
+	d.idents = d.idents[:0]
+	d.ambiguous = d.ambiguous[:0]
+	d.dropped = d.dropped[:0]
+	d.token = 0
+}
+
+// CompareContext is similar to Compare, but the comparison
+// is aborted once the given context is done, in which case
+// no operations are generated, and the error of the context
+// is returned. Like for the WithTimeout option, the context
+// is verified periodically when an object or array is visited.
+// It also returns the other errors of the comparison, such as
+// ErrMaxDepth.
+func (d *Differ) CompareContext(ctx context.Context, src, tgt interface{}) error {
+	if err := ctx.Err(); err != nil {
+		d.err = err
+		return err
+	}
+	d.ctx = ctx
+	defer func() { d.ctx = nil }()
+
+	d.Compare(src, tgt)
+
+	return d.err
+}
@@ -0,0 +1,190 @@
+package jsondiff
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Unified returns a textual representation of the patch
+// similar to a unified diff, for command-line tools. The
+// consecutive operations that apply to the members of the
+// same parent are grouped under a header line that holds
+// the pointer of their parent, such as:
+//
+//	@@ /spec/containers/0 @@
+//	~ image: "nginx:latest" -> "nginx:1.19.5-alpine"
+//	- ports: [80]
+//	+ args: ["-g","daemon off;"]
+//
+// Each operation is rendered on a line of its own, which
+// starts with a "+" for the added, moved and copied values,
+// a "-" for the removed values, a "~" for the replaced values,
+// and a "=" for the values only tested, followed by the last
+// reference token of its path, unescaped. The old value of
+// the remove and replace operations is that of the OldValue
+// field of the operation, or of the test operation of the
+// same path that precedes it, such as with the Invertible
+// option, and isn't shown otherwise. The tokens and pointers
+// that are empty, or hold spaces, colons, quotes or characters
+// that aren't printable are quoted. The output is not meant to
+// be parsed, and may change between versions.
+func (p Patch) Unified() string {
+	return p.UnifiedColors(Colors{})
+}
+
+// ColorString is similar to Unified, but the lines are
+// colored with the ANSI escape sequences of TermColors,
+// for the output of a terminal. See TerminalColors to
+// disable the colors when the output isn't a terminal.
+func (p Patch) ColorString() string {
+	return p.UnifiedColors(TermColors)
+}
+
+// UnifiedColors is similar to Unified, but each line is
+// enclosed by the sequence of the given colors that matches
+// the type of its operation, and the ANSI reset sequence.
+// The lines are not colored if the sequence is empty, and
+// the zero value of Colors renders the patch like Unified.
+func (p Patch) UnifiedColors(c Colors) string {
+	var (
+		sb     strings.Builder
+		parent string
+	)
+	for i := 0; i < len(p); i++ {
+		op := p[i]
+		old := op.OldValue
+
+		if op.Type == OperationTest && i+1 < len(p) && p[i+1].Path == op.Path {
+			if next := p[i+1]; next.Type == OperationReplace || next.Type == OperationRemove {
+				// The test verifies the old value
+				// of the following operation.
+				i++
+				op, old = next, next.OldValue
+				if old == nil {
+					old = p[i-1].Value
+				}
+			}
+		}
+		if sb.Len() != 0 {
+			sb.WriteByte('\n')
+		}
+		if pp := parentPointer(op.Path); sb.Len() == 0 || pp != parent {
+			writeColored(&sb, c.Header, "@@ "+unifiedString(pp)+" @@")
+			sb.WriteByte('\n')
+			parent = pp
+		}
+		var sign, color string
+
+		switch op.Type {
+		case OperationAdd:
+			sign, color = "+", c.Add
+		case OperationMove, OperationCopy:
+			sign, color = "+", c.Move
+		case OperationRemove:
+			sign, color = "-", c.Remove
+		case OperationReplace:
+			sign, color = "~", c.Replace
+		case OperationTest:
+			sign, color = "=", c.Test
+		default:
+			sign = op.Type
+		}
+		line := sign
+		if op.Path != emptyPointer {
+			line += " " + unifiedString(rfc6901Unescaper.Replace(op.Path[len(parent)+1:]))
+		}
+		var detail string
+		switch {
+		case op.Type == OperationMove:
+			detail = "(moved from " + unifiedString(op.From) + ")"
+		case op.Type == OperationCopy:
+			detail = "(copied from " + unifiedString(op.From) + ")"
+		case op.Type == OperationRemove && old != nil:
+			detail = describeValue(old)
+		case op.Type == OperationReplace && old != nil:
+			detail = describeValue(old) + " -> " + describeValue(op.Value)
+		case op.marshalWithValue():
+			detail = describeValue(op.Value)
+		}
+		if detail != "" {
+			if op.Path != emptyPointer {
+				line += ":"
+			}
+			line += " " + detail
+		}
+		writeColored(&sb, color, line)
+	}
+	return sb.String()
+}
+
+// unifiedString returns the string as is, or quoted if it
+// is empty, or holds characters that would be ambiguous
+// on a line of the output of Patch.Unified.
+func unifiedString(s string) string {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsPrint(r) || unicode.IsSpace(r) || r == ':' || r == '"'
+	})
+	if s == "" || i != -1 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// Colors represents the escape sequences that color the
+// lines of the output of Patch.UnifiedColors, by type of
+// operation. The Move sequence colors both the move and
+// copy operations, and the Header sequence the lines that
+// hold the pointers of the parents of the operations.
+type Colors struct {
+	Add     string
+	Remove  string
+	Replace string
+	Move    string
+	Test    string
+	Header  string
+}
+
+// colorReset is the ANSI sequence that
+// resets the attributes of the text.
+const colorReset = "\x1b[0m"
+
+// TermColors are the ANSI colors used by Patch.ColorString,
+// green for the added values, red for the removed values,
+// yellow for the replaced values, and cyan for the moved or
+// copied values.
+var TermColors = Colors{
+	Add:     "\x1b[32m",
+	Remove:  "\x1b[31m",
+	Replace: "\x1b[33m",
+	Move:    "\x1b[36m",
+	Header:  "\x1b[1m",
+}
+
+// TerminalColors returns TermColors if the given file is
+// a terminal, and the NO_COLOR environment variable is not
+// set, or the zero value of Colors otherwise. The result is
+// meant to be passed to Patch.UnifiedColors, such as:
+//
+//	fmt.Println(patch.UnifiedColors(jsondiff.TerminalColors(os.Stdout)))
+func TerminalColors(f *os.File) Colors {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || f == nil {
+		return Colors{}
+	}
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return Colors{}
+	}
+	return TermColors
+}
+
+func writeColored(sb *strings.Builder, color, line string) {
+	if color == "" {
+		sb.WriteString(line)
+		return
+	}
+	sb.WriteString(color)
+	sb.WriteString(line)
+	sb.WriteString(colorReset)
+}
@@ -0,0 +1,115 @@
+package jsondiff
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPatch_Unified(t *testing.T) {
+	patch := Patch{
+		{Type: OperationTest, Path: "/spec/image", Value: "nginx:latest"},
+		{Type: OperationReplace, Path: "/spec/image", Value: "nginx:1.19.5-alpine"},
+		{Type: OperationRemove, Path: "/spec/ports", OldValue: []interface{}{80.0}},
+		{Type: OperationAdd, Path: "/spec/args", Value: []interface{}{"-g", "daemon off;"}},
+		{Type: OperationRemove, Path: "/metadata/a~1b"},
+		{Type: OperationReplace, Path: "/metadata/key: value", Value: 2.0},
+		{Type: OperationMove, From: "/spec/x", Path: "/spec/y"},
+		{Type: OperationCopy, From: "/spec/y", Path: "/spec/list/-"},
+		{Type: OperationAdd, Path: "/", Value: nil},
+		{Type: OperationTest, Path: "/~0tilde", Value: true},
+		{Type: OperationReplace, Path: "", OldValue: map[string]interface{}{}, Value: []interface{}{}},
+	}
+	const expected = `@@ /spec @@
+~ image: "nginx:latest" -> "nginx:1.19.5-alpine"
+- ports: [80]
++ args: ["-g","daemon off;"]
+@@ /metadata @@
+- a/b
+~ "key: value": 2
+@@ /spec @@
++ y: (moved from /spec/x)
+@@ /spec/list @@
++ -: (copied from /spec/y)
+@@ "" @@
++ "": null
+= ~tilde: true
+~ {} -> []`
+
+	if s := patch.Unified(); s != expected {
+		t.Errorf("unified patch mismatch, got:\n%s\nwant:\n%s", s, expected)
+	}
+	if s := (Patch{}).Unified(); s != "" {
+		t.Errorf("got %q, want empty string", s)
+	}
+}
+
+func TestPatch_Unified_invertible(t *testing.T) {
+	src := map[string]interface{}{"a": map[string]interface{}{"b": "x", "c": 1.0}}
+	tgt := map[string]interface{}{"a": map[string]interface{}{"b": "y"}}
+
+	patch, err := CompareWithoutMarshal(src, tgt, Invertible())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `@@ /a @@
+~ b: "x" -> "y"
+- c: 1`
+
+	if s := patch.Unified(); s != expected {
+		t.Errorf("unified patch mismatch, got:\n%s\nwant:\n%s", s, expected)
+	}
+}
+
+func TestPatch_ColorString(t *testing.T) {
+	patch := Patch{
+		{Type: OperationAdd, Path: "/a", Value: 1.0},
+		{Type: OperationRemove, Path: "/b", OldValue: 2.0},
+		{Type: OperationReplace, Path: "/c", OldValue: 3.0, Value: 4.0},
+		{Type: OperationMove, From: "/d", Path: "/e"},
+		{Type: OperationTest, Path: "/f", Value: 5.0},
+	}
+	const expected = "\x1b[1m@@ \"\" @@\x1b[0m\n" +
+		"\x1b[32m+ a: 1\x1b[0m\n" +
+		"\x1b[31m- b: 2\x1b[0m\n" +
+		"\x1b[33m~ c: 3 -> 4\x1b[0m\n" +
+		"\x1b[36m+ e: (moved from /d)\x1b[0m\n" +
+		"= f: 5"
+
+	if s := patch.ColorString(); s != expected {
+		t.Errorf("colored patch mismatch, got %q, want %q", s, expected)
+	}
+	c := Colors{Add: "<add>", Test: "<test>"}
+	const custom = "@@ \"\" @@\n" +
+		"<add>+ a: 1\x1b[0m\n" +
+		"- b: 2\n" +
+		"~ c: 3 -> 4\n" +
+		"+ e: (moved from /d)\n" +
+		"<test>= f: 5\x1b[0m"
+
+	if s := patch.UnifiedColors(c); s != custom {
+		t.Errorf("colored patch mismatch, got %q, want %q", s, custom)
+	}
+	if s := patch.UnifiedColors(Colors{}); s != patch.Unified() {
+		t.Errorf("expected uncolored patch, got %q", s)
+	}
+}
+
+func TestTerminalColors(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if c := TerminalColors(f); c != (Colors{}) {
+		t.Errorf("expected no colors for a regular file, got %+v", c)
+	}
+	if c := TerminalColors(nil); c != (Colors{}) {
+		t.Errorf("expected no colors for a nil file, got %+v", c)
+	}
+	t.Setenv("NO_COLOR", "1")
+
+	if c := TerminalColors(os.Stdout); c != (Colors{}) {
+		t.Errorf("expected no colors with NO_COLOR, got %+v", c)
+	}
+}
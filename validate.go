@@ -0,0 +1,45 @@
+package jsondiff
+
+import "fmt"
+
+// Validate checks that the operations of the patch follow the
+// structural rules of RFC 6902, independently of the document
+// it applies to. The type of each operation must be known, its
+// path, and the from location of the move and copy operations,
+// must be valid JSON pointers, and a move operation must not
+// move a value into one of its own children. The error returned
+// for an invalid operation reports its index and the rule it
+// violates.
+//
+// Since a nil value represents the JSON null value, and an
+// empty from location the root document, the presence of the
+// value and from members cannot be checked for a patch built
+// in Go, and is checked instead when a patch is unmarshaled
+// from its JSON representation.
+func (p Patch) Validate() error {
+	for i, op := range p {
+		switch op.Type {
+		case OperationAdd, OperationReplace, OperationRemove, OperationMove,
+			OperationCopy, OperationTest, OperationChecksum, OperationIncrement:
+		default:
+			return validationError(i, op, "its type is unknown")
+		}
+		if _, err := parsePointer(op.Path); err != nil {
+			return validationError(i, op, "its path is not a valid pointer: "+err.Error())
+		}
+		if !op.hasFrom() {
+			continue
+		}
+		if _, err := parsePointer(op.From); err != nil {
+			return validationError(i, op, "its from location is not a valid pointer: "+err.Error())
+		}
+		if op.Type == OperationMove && op.From != op.Path && isPointerPrefix(op.From, op.Path) {
+			return validationError(i, op, "its from location is a proper prefix of its path")
+		}
+	}
+	return nil
+}
+
+func validationError(i int, op Operation, reason string) error {
+	return fmt.Errorf("jsondiff: operation #%d (%s %q) is invalid: %s", i, op.Type, op.Path, reason)
+}
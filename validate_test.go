@@ -0,0 +1,61 @@
+package jsondiff
+
+import "testing"
+
+func TestPatch_Validate(t *testing.T) {
+	valid := Patch{
+		{Type: OperationTest, Path: "/a", Value: nil},
+		{Type: OperationReplace, Path: "", Value: map[string]interface{}{}},
+		{Type: OperationAdd, Path: "/a~1b/-", Value: 1},
+		{Type: OperationRemove, Path: "/c/0"},
+		{Type: OperationMove, From: "/d", Path: "/d"},
+		{Type: OperationMove, From: "/d", Path: "/de"},
+		{Type: OperationMove, From: "/d/e", Path: "/d"},
+		{Type: OperationCopy, From: "", Path: "/f"},
+		{Type: OperationCopy, From: "/f", Path: "/f/g"},
+		{Type: OperationIncrement, Path: "/h", Value: 1},
+		{Type: OperationChecksum, Path: "", Value: "sha256:00"},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected nil error, got %s", err)
+	}
+	for _, tc := range []struct {
+		op  Operation
+		err string
+	}{
+		{
+			Operation{Type: "merge", Path: "/a"},
+			`jsondiff: operation #1 (merge "/a") is invalid: its type is unknown`,
+		},
+		{
+			Operation{Type: OperationAdd, Path: "a", Value: 1},
+			`jsondiff: operation #1 (add "a") is invalid: its path is not a valid pointer: no leading slash`,
+		},
+		{
+			Operation{Type: OperationRemove, Path: "/a~"},
+			`jsondiff: operation #1 (remove "/a~") is invalid: its path is not a valid pointer: incomplete escape sequence`,
+		},
+		{
+			Operation{Type: OperationCopy, From: "/a~2", Path: "/b"},
+			`jsondiff: operation #1 (copy "/b") is invalid: its from location is not a valid pointer: invalid escape sequence`,
+		},
+		{
+			Operation{Type: OperationMove, From: "/a", Path: "/a/b"},
+			`jsondiff: operation #1 (move "/a/b") is invalid: its from location is a proper prefix of its path`,
+		},
+		{
+			Operation{Type: OperationMove, From: "", Path: "/a"},
+			`jsondiff: operation #1 (move "/a") is invalid: its from location is a proper prefix of its path`,
+		},
+	} {
+		p := Patch{{Type: OperationTest, Path: "", Value: 1}, tc.op}
+		err := p.Validate()
+		if err == nil {
+			t.Errorf("expected non-nil error for operation %s", tc.op.String())
+			continue
+		}
+		if err.Error() != tc.err {
+			t.Errorf("error mismatch:\ngot:  %s\nwant: %s", err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package jsondiff
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoYAMLUnmarshal is the error returned by CompareYAML
+// when no function is set with the UnmarshalFunc option to
+// decode the YAML documents.
+var ErrNoYAMLUnmarshal = errors.New("jsondiff: no YAML unmarshal function")
+
+// CompareYAML compares the given YAML documents and returns
+// the differences relative to the former as a list of JSON
+// Patch operations. The documents are decoded with the function
+// of the UnmarshalFunc option, such as the Unmarshal function of
+// a YAML package, which the package doesn't depend on:
+//
+//	patch, err := jsondiff.CompareYAML(src, tgt, jsondiff.UnmarshalFunc(yaml.Unmarshal))
+//
+// The decoded values are converted to the representation of the
+// JSON documents: the maps with interface keys become objects,
+// the integers become float64 numbers, and the timestamps become
+// strings, in the RFC 3339 format. An error is returned if a map
+// has a key that isn't a string, such as an integer key, or if a
+// value has no JSON representation.
+func CompareYAML(source, target []byte, opts ...Option) (Patch, error) {
+	var d Differ
+	d.applyOpts(opts...)
+
+	if d.opts.unmarshal == nil || d.opts.useNumber {
+		return nil, ErrNoYAMLUnmarshal
+	}
+	si, err := d.opts.decodeYAML(source)
+	if err != nil {
+		return nil, &DecodeError{Side: SourceDocument, Err: err}
+	}
+	ti, err := d.opts.decodeYAML(target)
+	if err != nil {
+		return nil, &DecodeError{Side: TargetDocument, Err: err}
+	}
+	return d.CompareWithError(si, ti)
+}
+
+func (o *options) decodeYAML(b []byte) (interface{}, error) {
+	var v interface{}
+	if err := o.unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return fromYAML(pointer{}, v)
+}
+
+// fromYAML converts the value decoded from a YAML document
+// located at ptr to the representation of a JSON document.
+func fromYAML(ptr pointer, v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case nil, bool, string, float64:
+		return t, nil
+	case map[string]interface{}:
+		for k, e := range t {
+			p := ptr.clone()
+			p.appendKey(k)
+			c, err := fromYAML(p, e)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = c
+		}
+		return t, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			s, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string key %v of type %T at %q", k, k, ptr.string())
+			}
+			p := ptr.clone()
+			p.appendKey(s)
+			c, err := fromYAML(p, e)
+			if err != nil {
+				return nil, err
+			}
+			m[s] = c
+		}
+		return m, nil
+	case []interface{}:
+		for i, e := range t {
+			p := ptr.clone()
+			p.appendIndex(i)
+			c, err := fromYAML(p, e)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = c
+		}
+		return t, nil
+	case int:
+		return float64(t), nil
+	case int64:
+		return float64(t), nil
+	case uint64:
+		return float64(t), nil
+	case uint:
+		return float64(t), nil
+	case int32:
+		return float64(t), nil
+	case float32:
+		return float64(t), nil
+	case time.Time:
+		return t.Format(time.RFC3339Nano), nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T at %q", v, ptr.string())
+	}
+}
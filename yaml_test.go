@@ -0,0 +1,96 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// yamlUnmarshal mimics the values decoded by a YAML package
+// from JSON documents, which are valid YAML documents: the
+// objects are maps with interface keys, the integers are int
+// values, and the strings prefixed by "ts:" are timestamps.
+func yamlUnmarshal(b []byte, v any) error {
+	var i interface{}
+	if err := unmarshalUseNumber(b, &i); err != nil {
+		return err
+	}
+	*(v.(*interface{})) = toYAML(i)
+	return nil
+}
+
+func toYAML(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(t))
+		for k, e := range t {
+			m[k] = toYAML(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range t {
+			t[i] = toYAML(e)
+		}
+		return t
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := t.Float64()
+		return f
+	case string:
+		if s, ok := strings.CutPrefix(t, "ts:"); ok {
+			ts, _ := time.Parse(time.RFC3339, s)
+			return ts
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func TestCompareYAML(t *testing.T) {
+	src := `{"a":{"b":1,"c":[1,2]},"d":"ts:2024-01-02T03:04:05Z","e":1.5}`
+	tgt := `{"a":{"b":2,"c":[1,2,3]},"d":"ts:2024-01-02T03:04:06Z","e":1.5}`
+
+	patch, err := CompareYAML([]byte(src), []byte(tgt), UnmarshalFunc(yamlUnmarshal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"value":2,"op":"replace","path":"/a/b"}
+{"value":3,"op":"add","path":"/a/c/-"}
+{"value":"2024-01-02T03:04:06Z","op":"replace","path":"/d"}`
+	if s := patch.String(); s != want {
+		t.Errorf("got patch:\n%s\nwant:\n%s", s, want)
+	}
+	if _, err := CompareYAML([]byte(src), []byte(tgt)); !errors.Is(err, ErrNoYAMLUnmarshal) {
+		t.Errorf("got error %v, want %v", err, ErrNoYAMLUnmarshal)
+	}
+	if _, err := CompareYAML([]byte(src), []byte(tgt), UseNumber()); !errors.Is(err, ErrNoYAMLUnmarshal) {
+		t.Errorf("got error %v, want %v", err, ErrNoYAMLUnmarshal)
+	}
+	for _, tc := range []struct {
+		val interface{}
+		err string
+	}{
+		{
+			map[interface{}]interface{}{"a": []interface{}{map[interface{}]interface{}{1: "x"}}},
+			`non-string key 1 of type int at "/a/0"`,
+		},
+		{
+			map[string]interface{}{"a/b": struct{}{}},
+			`unsupported value of type struct {} at "/a~1b"`,
+		},
+	} {
+		unmarshal := UnmarshalFunc(func(_ []byte, v any) error {
+			*(v.(*interface{})) = tc.val
+			return nil
+		})
+		_, err := CompareYAML([]byte(src), []byte(tgt), unmarshal)
+		if err == nil || !strings.Contains(err.Error(), tc.err) || !strings.Contains(err.Error(), "source document") {
+			t.Errorf("got error %v, want %q", err, tc.err)
+		}
+	}
+}